@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+// commandUsage describes the subcommands of anythingsrv.
+const commandUsage = `usage:
+  anythingsrv                           run the server
+  anythingsrv export-entries <format>   write the ENTRIES catalog to stdout
+  anythingsrv import-entries <format>   convert a catalog read from stdin to
+                                        JSON for ENTRIES
+formats: json, csv, yaml`
+
+// runCommand runs the anythingsrv subcommand in args, reading its input from
+// stdin and writing its output to stdout:
+//   - "export-entries" writes the entries in the ENTRIES environment variable
+//     as a catalog in the given format;
+//   - "import-entries" validates a catalog in the given format and writes it
+//     in JSON, to be used as the value of ENTRIES.
+func runCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 2 {
+		return errors.New(commandUsage)
+	}
+	format := app.CatalogFormat(args[1])
+	switch format {
+	case app.CatalogJSON, app.CatalogCSV, app.CatalogYAML:
+	default:
+		return fmt.Errorf("unknown format %q\n%s", args[1], commandUsage)
+	}
+
+	switch args[0] {
+	case "export-entries":
+		entries, parents, err := Entries()
+		if err != nil {
+			return err
+		}
+		return app.WriteCatalog(stdout, format, entries, parents)
+	case "import-entries":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("cannot read catalog: %w", err)
+		}
+		entries, parents, err := app.ParseCatalog(format, "stdin", data)
+		if err != nil {
+			return err
+		}
+		return app.WriteCatalog(stdout, app.CatalogJSON, entries, parents)
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], commandUsage)
+}
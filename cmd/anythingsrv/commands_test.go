@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	const entries = `{"Downtown":{"Chinatown":{"Noodle House":{"cost":1}},"Pizza Place":{"cost":2,"open":{"mon":["lunch"]}}}}`
+
+	var tests = []struct {
+		desc    string
+		args    []string
+		env     string
+		stdin   string
+		want    string
+		wantErr string
+	}{{
+		desc: "export to CSV",
+		args: []string{"export-entries", "csv"},
+		env:  entries,
+		want: "group,parent,name,cost,schedule,hours,exceptions,tags,place,notes,url,address,phone,archived,snoozed_until\n" +
+			"Chinatown,Downtown,Noodle House,1,,,,,,,,,,false,\n" +
+			"Downtown,,Pizza Place,2,mon:lunch,,,,,,,,,false,\n",
+	}, {
+		desc: "export to YAML",
+		args: []string{"export-entries", "yaml"},
+		env:  entries,
+		want: "Downtown:\n" +
+			"  Chinatown:\n" +
+			"    Noodle House:\n" +
+			"      cost: 1\n" +
+			"  Pizza Place:\n" +
+			"    cost: 2\n" +
+			"    open:\n" +
+			"      mon: [lunch]\n",
+	}, {
+		desc:    "export invalid entries",
+		args:    []string{"export-entries", "csv"},
+		env:     `{"G|1":{"A":{"cost":1}}}`,
+		wantErr: "ENTRIES: group name \"G|1\" contains invalid character '|'",
+	}, {
+		desc:  "import from CSV",
+		args:  []string{"import-entries", "csv"},
+		stdin: "group,parent,name,cost,schedule\nChinatown,Downtown,Noodle House,1,\nDowntown,,Pizza Place,2,mon:lunch\n",
+		want:  entries + "\n",
+	}, {
+		desc:  "import from YAML",
+		args:  []string{"import-entries", "yaml"},
+		stdin: "Downtown:\n  Pizza Place:\n    cost: 2\n    open:\n      mon: [lunch]\n  Chinatown:\n    Noodle House:\n      cost: 1\n",
+		want:  entries + "\n",
+	}, {
+		desc:    "import invalid entries",
+		args:    []string{"import-entries", "yaml"},
+		stdin:   "Downtown:\n  Pizza|Place:\n    cost: 2\n",
+		wantErr: "stdin: entry \"Pizza|Place\" contains invalid character '|'",
+	}, {
+		desc:    "unknown command",
+		args:    []string{"serve", "csv"},
+		wantErr: `unknown command "serve"`,
+	}, {
+		desc:    "unknown format",
+		args:    []string{"export-entries", "xml"},
+		wantErr: `unknown format "xml"`,
+	}, {
+		desc:    "missing format",
+		args:    []string{"export-entries"},
+		wantErr: "usage:",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("ENTRIES", test.env)
+			var stdout strings.Builder
+			err := runCommand(test.args, strings.NewReader(test.stdin), &stdout)
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("runCommand() err = %v, wantErr = %q", err, test.wantErr)
+			}
+			if stdout.String() != test.want {
+				t.Errorf("runCommand() output =\n%s\nwant\n%s", stdout.String(), test.want)
+			}
+		})
+	}
+}
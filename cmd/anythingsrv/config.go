@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alnvdl/anything/internal/app"
+	"github.com/alnvdl/anything/internal/oidc"
 )
 
 const (
@@ -16,6 +18,8 @@ const (
 	defaultPort                = 8080
 	defaultPersistInterval     = 5 * time.Minute
 	defaultHealthCheckInterval = 3 * time.Minute
+	defaultSessionDuration     = 30 * 24 * time.Hour
+	defaultClosingSoonWindow   = 30 * time.Minute
 )
 
 // DBPath reads the DB_PATH environment variable. If not set, it defaults to
@@ -48,6 +52,147 @@ func HealthCheckInterval() time.Duration {
 	return defaultHealthCheckInterval
 }
 
+// AuthMaxFailures reads and validates the AUTH_MAX_FAILURES environment
+// variable. If not set or invalid, it defaults to 5.
+func AuthMaxFailures() int {
+	s := os.Getenv("AUTH_MAX_FAILURES")
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return n
+	}
+	return app.DefaultAuthMaxFailures
+}
+
+// AuthBlockDuration reads and validates the AUTH_BLOCK_DURATION environment
+// variable. If not set or invalid, it defaults to 1 minute.
+func AuthBlockDuration() time.Duration {
+	s := os.Getenv("AUTH_BLOCK_DURATION")
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return app.DefaultAuthBlockDuration
+}
+
+// AuthMaxBlockDuration reads and validates the AUTH_MAX_BLOCK_DURATION
+// environment variable. If not set or invalid, it defaults to 1 hour.
+func AuthMaxBlockDuration() time.Duration {
+	s := os.Getenv("AUTH_MAX_BLOCK_DURATION")
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return app.DefaultAuthMaxBlockDuration
+}
+
+// TrustForwardedFor reads the TRUST_FORWARDED_FOR environment variable. It is
+// false unless set to a true boolean value (e.g., "true" or "1").
+func TrustForwardedFor() bool {
+	b, _ := strconv.ParseBool(os.Getenv("TRUST_FORWARDED_FOR"))
+	return b
+}
+
+// CSRFKey reads the CSRF_KEY environment variable. If not set, it returns
+// nil, and a random key is generated when the application starts.
+func CSRFKey() []byte {
+	s := os.Getenv("CSRF_KEY")
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
+
+// SecurityHeaders reads and validates the SECURITY_HEADERS environment
+// variable, which overrides the default security headers set on every
+// response. If not set, it returns nil and the defaults are used.
+func SecurityHeaders() (map[string]string, error) {
+	s := os.Getenv("SECURITY_HEADERS")
+	if s == "" {
+		return nil, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(s), &headers); err != nil {
+		return nil, fmt.Errorf("SECURITY_HEADERS is not valid JSON: %w", err)
+	}
+	return headers, nil
+}
+
+// OIDCConfig reads and validates the OIDC_ISSUER, OIDC_CLIENT_ID and
+// OIDC_CLIENT_SECRET environment variables. If OIDC_ISSUER is not set, it
+// returns nil and OIDC login is disabled. Enabling OIDC also requires
+// PUBLIC_URL to be set.
+func OIDCConfig() (*oidc.Config, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID must be set when OIDC_ISSUER is set")
+	}
+	if PublicURL() == "" {
+		return nil, fmt.Errorf("PUBLIC_URL must be set when OIDC_ISSUER is set")
+	}
+	return &oidc.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+	}, nil
+}
+
+// Identities reads and validates the OIDC_IDENTITIES environment variable. If
+// not set, it returns nil and no OIDC identity can log in.
+func Identities() (map[string]string, error) {
+	return parseIdentities("OIDC_IDENTITIES", os.Getenv("OIDC_IDENTITIES"))
+}
+
+// parseIdentities parses the JSON object in s mapping OIDC subjects or email
+// addresses to people. The name is used to identify the source of the
+// configuration in error messages.
+func parseIdentities(name, s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var identities map[string]string
+	if err := json.Unmarshal([]byte(s), &identities); err != nil {
+		return nil, fmt.Errorf("%s is not valid JSON: %w", name, err)
+	}
+	return identities, nil
+}
+
+// PublicURL reads the PUBLIC_URL environment variable, without any trailing
+// slash.
+func PublicURL() string {
+	return strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+}
+
+// SessionKey reads the SESSION_KEY environment variable. If not set, it
+// returns nil, and a random key is generated when the application starts.
+func SessionKey() []byte {
+	s := os.Getenv("SESSION_KEY")
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
+
+// SessionDuration reads and validates the SESSION_DURATION environment
+// variable. If not set or invalid, it defaults to 30 days.
+func SessionDuration() time.Duration {
+	s := os.Getenv("SESSION_DURATION")
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return defaultSessionDuration
+}
+
+// ClosingSoonWindow reads and validates the CLOSING_SOON_WINDOW environment
+// variable. If not set or invalid, it defaults to 30 minutes.
+func ClosingSoonWindow() time.Duration {
+	s := os.Getenv("CLOSING_SOON_WINDOW")
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return defaultClosingSoonWindow
+}
+
 // Port reads and validates the PORT environment variable. If not set, it
 // defaults to 8080.
 func Port() (int, error) {
@@ -65,69 +210,75 @@ func Port() (int, error) {
 	return port, nil
 }
 
-// entryConfig holds the JSON-serializable configuration for an entry.
-type entryConfig struct {
-	Cost int                 `json:"cost"`
-	Open map[string][]string `json:"open"`
+// Entries reads and validates entries and the parents of nested groups from
+// the the ENTRIES environment variable. This is only used for an initial
+// import, and may be missing; entries are persisted in the database after
+// the initial import.
+func Entries() ([]app.Entry, map[string]string, error) {
+	return parseEntries("ENTRIES", os.Getenv("ENTRIES"))
 }
 
-// entriesConfig maps group names to entry names to entry configurations.
-type entriesConfig map[string]map[string]entryConfig
-
-// Entries reads and validates entries from the the ENTRIES environment
-// variable. This is only used for an initial import, and may be missing;
-// entries are persisted in the database after the initial import.
-func Entries() ([]app.Entry, error) {
-	s := os.Getenv("ENTRIES")
+// parseEntries parses and validates entries from the JSON in s, returning
+// them with a map of nested groups to their parents. The name is used to
+// identify the source of the configuration in error messages.
+func parseEntries(name, s string) ([]app.Entry, map[string]string, error) {
 	if s == "" {
-		return nil, nil
-	}
-	var config entriesConfig
-	if err := json.Unmarshal([]byte(s), &config); err != nil {
-		return nil, fmt.Errorf("ENTRIES is not valid JSON: %w", err)
+		return nil, nil, nil
 	}
-	var entries []app.Entry
-	for group, groupEntries := range config {
-		if strings.Contains(group, "|") {
-			return nil, fmt.Errorf("ENTRIES: group name %q contains invalid character '|'", group)
-		}
-		for name, cfg := range groupEntries {
-			if strings.Contains(name, "|") {
-				return nil, fmt.Errorf("ENTRIES: entry name %q contains invalid character '|'", name)
-			}
-			entries = append(entries, app.Entry{
-				Name:  name,
-				Group: group,
-				Cost:  cfg.Cost,
-				Open:  cfg.Open,
-			})
-		}
-	}
-	return entries, nil
+	return app.ParseCatalog(app.CatalogJSON, name, []byte(s))
 }
 
 // People reads and validates the PEOPLE environment variable.
 func People() (map[string]string, error) {
-	s := os.Getenv("PEOPLE")
+	return parsePeople("PEOPLE", os.Getenv("PEOPLE"))
+}
+
+// parsePeople parses and validates the people JSON in s. The name is used to
+// identify the source of the configuration in error messages.
+func parsePeople(name, s string) (map[string]string, error) {
 	if s == "" {
-		return nil, fmt.Errorf("PEOPLE is not set")
+		return nil, fmt.Errorf("%s is not set", name)
 	}
 	var people map[string]string
 	if err := json.Unmarshal([]byte(s), &people); err != nil {
-		return nil, fmt.Errorf("PEOPLE is not valid JSON: %w", err)
+		return nil, fmt.Errorf("%s is not valid JSON: %w", name, err)
 	}
 	return people, nil
 }
 
+// Editors reads and validates the EDITORS environment variable. If not set, it
+// returns nil and everyone is an editor.
+func Editors() ([]string, error) {
+	return parseEditors("EDITORS", os.Getenv("EDITORS"))
+}
+
+// parseEditors parses the JSON array of people in s. The name is used to
+// identify the source of the configuration in error messages.
+func parseEditors(name, s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var editors []string
+	if err := json.Unmarshal([]byte(s), &editors); err != nil {
+		return nil, fmt.Errorf("%s is not valid JSON: %w", name, err)
+	}
+	return editors, nil
+}
+
 // Timezone reads and validates the TIMEZONE environment variable.
 func Timezone() (*time.Location, error) {
-	s := os.Getenv("TIMEZONE")
+	return parseTimezone("TIMEZONE", os.Getenv("TIMEZONE"))
+}
+
+// parseTimezone parses and validates the IANA timezone in s. The name is used
+// to identify the source of the configuration in error messages.
+func parseTimezone(name, s string) (*time.Location, error) {
 	if s == "" {
-		return nil, fmt.Errorf("TIMEZONE is not set")
+		return nil, fmt.Errorf("%s is not set", name)
 	}
 	loc, err := time.LoadLocation(s)
 	if err != nil {
-		return nil, fmt.Errorf("TIMEZONE is not valid: %w", err)
+		return nil, fmt.Errorf("%s is not valid: %w", name, err)
 	}
 	return loc, nil
 }
@@ -135,49 +286,119 @@ func Timezone() (*time.Location, error) {
 // Periods reads and validates the PERIODS environment variable.
 // It validates that period hours do not overlap.
 func Periods() (app.Periods, error) {
-	s := os.Getenv("PERIODS")
+	return parsePeriods("PERIODS", os.Getenv("PERIODS"))
+}
+
+// parsePeriods parses and validates the periods JSON in s. The name is used to
+// identify the source of the configuration in error messages.
+func parsePeriods(name, s string) (app.Periods, error) {
 	if s == "" {
-		return nil, fmt.Errorf("PERIODS is not set")
+		return nil, fmt.Errorf("%s is not set", name)
 	}
 	var raw map[string][2]int
 	if err := json.Unmarshal([]byte(s), &raw); err != nil {
-		return nil, fmt.Errorf("PERIODS is not valid JSON: %w", err)
+		return nil, fmt.Errorf("%s is not valid JSON: %w", name, err)
 	}
 
-	// Validate no overlapping hours.
-	seen := make(map[int]string)
-	for name, bounds := range raw {
-		start, end := bounds[0], bounds[1]
-		if start == end {
-			return nil, fmt.Errorf("PERIODS: period %q has equal start and end hour", name)
-		}
-		hours := hoursForPeriod(start, end)
-		for _, h := range hours {
-			if other, ok := seen[h]; ok {
-				return nil, fmt.Errorf("PERIODS: hour %d overlaps between %q and %q", h, other, name)
-			}
-			seen[h] = name
-		}
+	periods := app.Periods(raw)
+	if err := periods.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
 	}
+	return periods, nil
+}
+
+// spaceConfig holds the JSON-serializable configuration for a space. Each
+// field follows the format of the environment variable with the same name.
+type spaceConfig struct {
+	People   json.RawMessage `json:"people"`
+	Periods  json.RawMessage `json:"periods"`
+	Timezone string          `json:"timezone"`
+	Entries  json.RawMessage `json:"entries"`
 
-	return app.Periods(raw), nil
+	Identities json.RawMessage `json:"identities"`
+	Editors    json.RawMessage `json:"editors"`
 }
 
-// hoursForPeriod returns the list of hours covered by a period [start, end).
-func hoursForPeriod(start, end int) []int {
-	var hours []int
-	if start < end {
-		for h := start; h < end; h++ {
-			hours = append(hours, h)
+// Spaces reads and validates the SPACES environment variable, returning the
+// parameters for each space keyed by space name. It returns nil if SPACES is
+// not set, in which case a single space is configured by the other variables.
+// The AutoSaveParams of the returned parameters are left for the caller.
+func Spaces() (map[string]app.Params, error) {
+	s := os.Getenv("SPACES")
+	if s == "" {
+		return nil, nil
+	}
+	var config map[string]spaceConfig
+	if err := json.Unmarshal([]byte(s), &config); err != nil {
+		return nil, fmt.Errorf("SPACES is not valid JSON: %w", err)
+	}
+	if len(config) == 0 {
+		return nil, fmt.Errorf("SPACES must define at least one space")
+	}
+
+	params := make(map[string]app.Params, len(config))
+	for space, cfg := range config {
+		if !validSpaceName(space) {
+			return nil, fmt.Errorf("SPACES: space name %q must only contain lowercase letters, digits, '-' and '_'", space)
+		}
+		prefix := fmt.Sprintf("SPACES[%q]", space)
+
+		people, err := parsePeople(prefix+".people", string(cfg.People))
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		// Wraps around midnight.
-		for h := start; h < 24; h++ {
-			hours = append(hours, h)
+		periods, err := parsePeriods(prefix+".periods", string(cfg.Periods))
+		if err != nil {
+			return nil, err
 		}
-		for h := range end {
-			hours = append(hours, h)
+		tz, err := parseTimezone(prefix+".timezone", cfg.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		entries, parents, err := parseEntries(prefix+".entries", string(cfg.Entries))
+		if err != nil {
+			return nil, err
+		}
+		identities, err := parseIdentities(prefix+".identities", string(cfg.Identities))
+		if err != nil {
+			return nil, err
+		}
+		editors, err := parseEditors(prefix+".editors", string(cfg.Editors))
+		if err != nil {
+			return nil, err
+		}
+
+		params[space] = app.Params{
+			Entries:      entries,
+			GroupParents: parents,
+			People:       people,
+			Timezone:     tz,
+			Periods:      periods,
+			Identities:   identities,
+			Editors:      editors,
+		}
+	}
+	return params, nil
+}
+
+// validSpaceName reports whether name can be used as a space name in URLs
+// and database file names.
+func validSpaceName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
 		}
 	}
-	return hours
+	return true
+}
+
+// SpaceDBPath returns the database path for a space, derived from dbPath by
+// appending the space name to the file name (e.g., "db.json" becomes
+// "db-family.json" for the "family" space).
+func SpaceDBPath(dbPath, space string) string {
+	ext := filepath.Ext(dbPath)
+	return strings.TrimSuffix(dbPath, ext) + "-" + space + ext
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -71,10 +72,11 @@ func TestPort(t *testing.T) {
 
 func TestEntries(t *testing.T) {
 	var tests = []struct {
-		desc      string
-		env       string
-		wantCount int
-		wantErr   string
+		desc        string
+		env         string
+		wantCount   int
+		wantParents map[string]string
+		wantErr     string
 	}{{
 		desc:      "valid entries",
 		env:       `{"G1":{"A":{"open":{"mon":["lunch"]},"cost":2}}}`,
@@ -99,18 +101,90 @@ func TestEntries(t *testing.T) {
 		desc:    "entry name contains pipe",
 		env:     `{"G1":{"A|B":{"open":{},"cost":1}}}`,
 		wantErr: "contains invalid character '|'",
+	}, {
+		desc:    "entry without a cost",
+		env:     `{"G1":{"A":{"open":{"mon":["lunch"]}}}}`,
+		wantErr: `entry "A" has an invalid cost 0`,
+	}, {
+		desc:    "entry with an empty name",
+		env:     `{"G1":{" ":{"cost":1}}}`,
+		wantErr: `entry " " has no name`,
+	}, {
+		desc:      "entry with opening hours",
+		env:       `{"G1":{"A":{"hours":{"fri":["11:30-14:30","18:00-02:00"]},"cost":1}}}`,
+		wantCount: 1,
+	}, {
+		desc:    "entry with invalid opening hours",
+		env:     `{"G1":{"A":{"hours":{"fri":["11:30-25:00"]},"cost":1}}}`,
+		wantErr: `entry "A" has invalid hours`,
+	}, {
+		desc:      "entry with exceptions",
+		env:       `{"G1":{"A":{"open":{"mon":["lunch"],"hol":[]},"exceptions":[{"from":"2024-12-20","to":"2025-01-05"}],"cost":1}}}`,
+		wantCount: 1,
+	}, {
+		desc:    "entry with invalid exception",
+		env:     `{"G1":{"A":{"exceptions":[{"from":"2024-12-20","to":"2024-12-01"}],"cost":1}}}`,
+		wantErr: `entry "A" has an invalid exception`,
+	}, {
+		desc:      "entry with tags",
+		env:       `{"G1":{"A":{"open":{"mon":["lunch"]},"tags":["delivery","vegetarian friendly"],"cost":1}}}`,
+		wantCount: 1,
+	}, {
+		desc:    "entry with invalid tags",
+		env:     `{"G1":{"A":{"tags":["a,b"],"cost":1}}}`,
+		wantErr: `entry "A" has invalid tags`,
+	}, {
+		desc:      "entry with details",
+		env:       `{"G1":{"A":{"notes":"Cash only","url":"https://example.com","address":"1 Main St","phone":"+1 555 123","cost":1}}}`,
+		wantCount: 1,
+	}, {
+		desc:    "entry with invalid URL",
+		env:     `{"G1":{"A":{"url":"javascript:alert(1)","cost":1}}}`,
+		wantErr: `entry "A" has invalid details`,
+	}, {
+		desc:      "locations of a place",
+		env:       `{"G1":{"A":{"place":"Chain","cost":1}},"G2":{"B":{"place":"Chain","cost":2}}}`,
+		wantCount: 2,
+	}, {
+		desc:      "archived and snoozed entries",
+		env:       `{"G1":{"A":{"archived":true,"cost":1},"B":{"snoozed_until":"2025-03-01","cost":1}}}`,
+		wantCount: 2,
+	}, {
+		desc:    "entry with invalid snooze date",
+		env:     `{"G1":{"A":{"snoozed_until":"next week","cost":1}}}`,
+		wantErr: `entry "A" has an invalid snoozed_until date`,
+	}, {
+		desc:        "nested groups",
+		env:         `{"Downtown":{"A":{"cost":1},"Chinatown":{"B":{"cost":2},"North":{"C":{"cost":3}}},"Harbor":{"D":{"open":{"mon":["lunch"]},"cost":4}}},"Uptown":{"E":{"cost":1}}}`,
+		wantCount:   5,
+		wantParents: map[string]string{"Chinatown": "Downtown", "North": "Chinatown", "Harbor": "Downtown"},
+	}, {
+		desc:    "nested group name used twice",
+		env:     `{"Downtown":{"Center":{"A":{"cost":1}}},"Uptown":{"Center":{"B":{"cost":1}}}}`,
+		wantErr: `group name "Center" is used more than once`,
+	}, {
+		desc:    "nested group with invalid entry",
+		env:     `{"Downtown":{"Chinatown":{"A":{"url":"javascript:alert(1)","cost":1}}}}`,
+		wantErr: `entry "A" has invalid details`,
+	}, {
+		desc:    "invalid entry",
+		env:     `{"G1":{"A":{"cost":"cheap"}}}`,
+		wantErr: `entry "A" is not valid`,
 	}}
 
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 			t.Setenv("ENTRIES", test.env)
-			got, err := Entries()
+			got, parents, err := Entries()
 			if !errorContains(err, test.wantErr) {
 				t.Fatalf("Entries() err = %v, wantErr = %q", err, test.wantErr)
 			}
 			if len(got) != test.wantCount {
 				t.Errorf("Entries() returned %d entries, want %d", len(got), test.wantCount)
 			}
+			if test.wantParents != nil && !reflect.DeepEqual(parents, test.wantParents) {
+				t.Errorf("Entries() returned parents %v, want %v", parents, test.wantParents)
+			}
 		})
 	}
 }
@@ -231,49 +305,6 @@ func TestPeriods(t *testing.T) {
 	}
 }
 
-func TestHoursForPeriod(t *testing.T) {
-	var tests = []struct {
-		desc  string
-		start int
-		end   int
-		want  []int
-	}{{
-		desc:  "simple range",
-		start: 0,
-		end:   3,
-		want:  []int{0, 1, 2},
-	}, {
-		desc:  "wrap around midnight",
-		start: 22,
-		end:   2,
-		want:  []int{22, 23, 0, 1},
-	}, {
-		desc:  "single hour",
-		start: 5,
-		end:   6,
-		want:  []int{5},
-	}, {
-		desc:  "large range",
-		start: 0,
-		end:   10,
-		want:  []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
-	}}
-
-	for _, test := range tests {
-		t.Run(test.desc, func(t *testing.T) {
-			got := hoursForPeriod(test.start, test.end)
-			if len(got) != len(test.want) {
-				t.Fatalf("hoursForPeriod(%d, %d) = %v, want %v", test.start, test.end, got, test.want)
-			}
-			for i, h := range got {
-				if h != test.want[i] {
-					t.Errorf("hoursForPeriod(%d, %d)[%d] = %d, want %d", test.start, test.end, i, h, test.want[i])
-				}
-			}
-		})
-	}
-}
-
 func TestDBPath(t *testing.T) {
 	var tests = []struct {
 		desc string
@@ -359,3 +390,482 @@ func TestHealthCheckInterval(t *testing.T) {
 		})
 	}
 }
+
+func TestSpaces(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		env        string
+		wantSpaces []string
+		wantErr    string
+	}{{
+		desc: "not set",
+		env:  "",
+	}, {
+		desc: "valid spaces",
+		env: `{
+			"family":{"people":{"alice":"a"},"periods":{"lunch":[10,15]},"timezone":"UTC","entries":{"G":{"A":{"open":{},"cost":1}}}},
+			"friends":{"people":{"bob":"b"},"periods":{"dinner":[18,23]},"timezone":"America/Sao_Paulo"}
+		}`,
+		wantSpaces: []string{"family", "friends"},
+	}, {
+		desc:    "invalid JSON",
+		env:     `{bad}`,
+		wantErr: "SPACES is not valid JSON",
+	}, {
+		desc:    "no spaces",
+		env:     `{}`,
+		wantErr: "SPACES must define at least one space",
+	}, {
+		desc:    "invalid space name",
+		env:     `{"My Space":{"people":{"alice":"a"},"periods":{"lunch":[10,15]},"timezone":"UTC"}}`,
+		wantErr: "space name \"My Space\" must only contain",
+	}, {
+		desc:    "missing people",
+		env:     `{"family":{"periods":{"lunch":[10,15]},"timezone":"UTC"}}`,
+		wantErr: `SPACES["family"].people is not set`,
+	}, {
+		desc:    "overlapping periods",
+		env:     `{"family":{"people":{"alice":"a"},"periods":{"a":[0,12],"b":[10,15]},"timezone":"UTC"}}`,
+		wantErr: `SPACES["family"].periods: hour`,
+	}, {
+		desc:    "invalid timezone",
+		env:     `{"family":{"people":{"alice":"a"},"periods":{"lunch":[10,15]},"timezone":"Not/A/Timezone"}}`,
+		wantErr: `SPACES["family"].timezone is not valid`,
+	}, {
+		desc:    "invalid entry name",
+		env:     `{"family":{"people":{"alice":"a"},"periods":{"lunch":[10,15]},"timezone":"UTC","entries":{"G":{"A|B":{"open":{},"cost":1}}}}}`,
+		wantErr: "contains invalid character '|'",
+	}, {
+		desc:    "invalid editors",
+		env:     `{"family":{"people":{"alice":"a"},"periods":{"lunch":[10,15]},"timezone":"UTC","editors":"alice"}}`,
+		wantErr: `SPACES["family"].editors is not valid JSON`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("SPACES", test.env)
+			got, err := Spaces()
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("Spaces() err = %v, wantErr = %q", err, test.wantErr)
+			}
+			if len(got) != len(test.wantSpaces) {
+				t.Fatalf("Spaces() returned %d spaces, want %d", len(got), len(test.wantSpaces))
+			}
+			for _, space := range test.wantSpaces {
+				params, ok := got[space]
+				if !ok {
+					t.Errorf("Spaces() is missing space %q", space)
+					continue
+				}
+				if params.People == nil || params.Periods == nil || params.Timezone == nil {
+					t.Errorf("Spaces() returned incomplete params for %q: %+v", space, params)
+				}
+			}
+		})
+	}
+}
+
+func TestSpaceDBPath(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		dbPath string
+		space  string
+		want   string
+	}{{
+		desc:   "default path",
+		dbPath: "db.json",
+		space:  "family",
+		want:   "db-family.json",
+	}, {
+		desc:   "absolute path",
+		dbPath: "/home/db.json",
+		space:  "friends",
+		want:   "/home/db-friends.json",
+	}, {
+		desc:   "no extension",
+		dbPath: "/data/db",
+		space:  "family",
+		want:   "/data/db-family",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := SpaceDBPath(test.dbPath, test.space)
+			if got != test.want {
+				t.Errorf("SpaceDBPath(%q, %q) = %q, want %q", test.dbPath, test.space, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthMaxFailures(t *testing.T) {
+	var tests = []struct {
+		desc string
+		env  string
+		want int
+	}{{
+		desc: "default when not set",
+		env:  "",
+		want: 5,
+	}, {
+		desc: "custom value",
+		env:  "10",
+		want: 10,
+	}, {
+		desc: "invalid falls back to default",
+		env:  "many",
+		want: 5,
+	}, {
+		desc: "zero falls back to default",
+		env:  "0",
+		want: 5,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("AUTH_MAX_FAILURES", test.env)
+			got := AuthMaxFailures()
+			if got != test.want {
+				t.Errorf("AuthMaxFailures() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthBlockDuration(t *testing.T) {
+	var tests = []struct {
+		desc string
+		env  string
+		want time.Duration
+	}{{
+		desc: "default when not set",
+		env:  "",
+		want: time.Minute,
+	}, {
+		desc: "custom duration",
+		env:  "30s",
+		want: 30 * time.Second,
+	}, {
+		desc: "invalid falls back to default",
+		env:  "notaduration",
+		want: time.Minute,
+	}, {
+		desc: "negative falls back to default",
+		env:  "-1m",
+		want: time.Minute,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("AUTH_BLOCK_DURATION", test.env)
+			got := AuthBlockDuration()
+			if got != test.want {
+				t.Errorf("AuthBlockDuration() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthMaxBlockDuration(t *testing.T) {
+	var tests = []struct {
+		desc string
+		env  string
+		want time.Duration
+	}{{
+		desc: "default when not set",
+		env:  "",
+		want: time.Hour,
+	}, {
+		desc: "custom duration",
+		env:  "24h",
+		want: 24 * time.Hour,
+	}, {
+		desc: "invalid falls back to default",
+		env:  "notaduration",
+		want: time.Hour,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("AUTH_MAX_BLOCK_DURATION", test.env)
+			got := AuthMaxBlockDuration()
+			if got != test.want {
+				t.Errorf("AuthMaxBlockDuration() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTrustForwardedFor(t *testing.T) {
+	var tests = []struct {
+		desc string
+		env  string
+		want bool
+	}{{
+		desc: "default when not set",
+		env:  "",
+		want: false,
+	}, {
+		desc: "true",
+		env:  "true",
+		want: true,
+	}, {
+		desc: "invalid is false",
+		env:  "yes please",
+		want: false,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("TRUST_FORWARDED_FOR", test.env)
+			got := TrustForwardedFor()
+			if got != test.want {
+				t.Errorf("TrustForwardedFor() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCSRFKey(t *testing.T) {
+	var tests = []struct {
+		desc string
+		env  string
+		want []byte
+	}{{
+		desc: "nil when not set",
+		env:  "",
+		want: nil,
+	}, {
+		desc: "custom key",
+		env:  "secret",
+		want: []byte("secret"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("CSRF_KEY", test.env)
+			got := CSRFKey()
+			if string(got) != string(test.want) || (got == nil) != (test.want == nil) {
+				t.Errorf("CSRFKey() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	var tests = []struct {
+		desc      string
+		env       string
+		wantCount int
+		wantErr   string
+	}{{
+		desc: "not set",
+		env:  "",
+	}, {
+		desc:      "valid headers",
+		env:       `{"Strict-Transport-Security":"","Permissions-Policy":"camera=()"}`,
+		wantCount: 2,
+	}, {
+		desc:    "invalid JSON",
+		env:     `{bad}`,
+		wantErr: "SECURITY_HEADERS is not valid JSON",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("SECURITY_HEADERS", test.env)
+			got, err := SecurityHeaders()
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("SecurityHeaders() err = %v, wantErr = %q", err, test.wantErr)
+			}
+			if len(got) != test.wantCount {
+				t.Errorf("SecurityHeaders() returned %d headers, want %d", len(got), test.wantCount)
+			}
+		})
+	}
+}
+
+func TestOIDCConfig(t *testing.T) {
+	var tests = []struct {
+		desc      string
+		issuer    string
+		clientID  string
+		publicURL string
+		wantNil   bool
+		wantErr   string
+	}{{
+		desc:    "disabled when not set",
+		wantNil: true,
+	}, {
+		desc:      "valid",
+		issuer:    "https://idp.example.com",
+		clientID:  "anything",
+		publicURL: "https://anything.example.com",
+	}, {
+		desc:      "missing client ID",
+		issuer:    "https://idp.example.com",
+		publicURL: "https://anything.example.com",
+		wantErr:   "OIDC_CLIENT_ID must be set",
+	}, {
+		desc:     "missing public URL",
+		issuer:   "https://idp.example.com",
+		clientID: "anything",
+		wantErr:  "PUBLIC_URL must be set",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("OIDC_ISSUER", test.issuer)
+			t.Setenv("OIDC_CLIENT_ID", test.clientID)
+			t.Setenv("OIDC_CLIENT_SECRET", "secret")
+			t.Setenv("PUBLIC_URL", test.publicURL)
+			got, err := OIDCConfig()
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("OIDCConfig() err = %v, wantErr = %q", err, test.wantErr)
+			}
+			if test.wantErr != "" {
+				return
+			}
+			if (got == nil) != test.wantNil {
+				t.Fatalf("OIDCConfig() = %v, wantNil = %v", got, test.wantNil)
+			}
+			if got != nil && (got.Issuer != test.issuer || got.ClientID != test.clientID || got.ClientSecret != "secret") {
+				t.Errorf("OIDCConfig() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	var tests = []struct {
+		desc      string
+		env       string
+		wantCount int
+		wantErr   string
+	}{{
+		desc: "not set",
+		env:  "",
+	}, {
+		desc:      "valid identities",
+		env:       `{"alice@example.com":"alice","1234567890":"bob"}`,
+		wantCount: 2,
+	}, {
+		desc:    "invalid JSON",
+		env:     `{bad}`,
+		wantErr: "OIDC_IDENTITIES is not valid JSON",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("OIDC_IDENTITIES", test.env)
+			got, err := Identities()
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("Identities() err = %v, wantErr = %q", err, test.wantErr)
+			}
+			if len(got) != test.wantCount {
+				t.Errorf("Identities() returned %d identities, want %d", len(got), test.wantCount)
+			}
+		})
+	}
+}
+
+func TestEditors(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		env     string
+		want    []string
+		wantErr string
+	}{{
+		desc: "not set",
+		env:  "",
+	}, {
+		desc: "valid editors",
+		env:  `["alice","bob"]`,
+		want: []string{"alice", "bob"},
+	}, {
+		desc:    "invalid JSON",
+		env:     `{"alice":true}`,
+		wantErr: "EDITORS is not valid JSON",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("EDITORS", test.env)
+			got, err := Editors()
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("Editors() err = %v, wantErr = %q", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Editors() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSessionDuration(t *testing.T) {
+	var tests = []struct {
+		desc string
+		env  string
+		want time.Duration
+	}{{
+		desc: "default when not set",
+		env:  "",
+		want: 30 * 24 * time.Hour,
+	}, {
+		desc: "custom duration",
+		env:  "12h",
+		want: 12 * time.Hour,
+	}, {
+		desc: "invalid falls back to default",
+		env:  "forever",
+		want: 30 * 24 * time.Hour,
+	}, {
+		desc: "negative falls back to default",
+		env:  "-1h",
+		want: 30 * 24 * time.Hour,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("SESSION_DURATION", test.env)
+			got := SessionDuration()
+			if got != test.want {
+				t.Errorf("SessionDuration() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClosingSoonWindow(t *testing.T) {
+	var tests = []struct {
+		desc string
+		env  string
+		want time.Duration
+	}{{
+		desc: "default when not set",
+		env:  "",
+		want: 30 * time.Minute,
+	}, {
+		desc: "custom window",
+		env:  "45m",
+		want: 45 * time.Minute,
+	}, {
+		desc: "invalid falls back to default",
+		env:  "soon",
+		want: 30 * time.Minute,
+	}, {
+		desc: "zero falls back to default",
+		env:  "0s",
+		want: 30 * time.Minute,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Setenv("CLOSING_SOON_WINDOW", test.env)
+			got := ClosingSoonWindow()
+			if got != test.want {
+				t.Errorf("ClosingSoonWindow() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
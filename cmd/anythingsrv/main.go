@@ -13,6 +13,7 @@ import (
 	"github.com/alnvdl/autosave"
 
 	"github.com/alnvdl/anything/internal/app"
+	"github.com/alnvdl/anything/internal/oidc"
 )
 
 // serverHealthCheck periodically checks the server health by making a request
@@ -40,48 +41,138 @@ func serverHealthCheck(interval time.Duration, port int, close chan bool) {
 	}
 }
 
-func main() {
-	port, err := Port()
+// closingHandler is the HTTP handler serving either a single App or several
+// spaces.
+type closingHandler interface {
+	http.Handler
+	Close()
+}
+
+// newApplication creates the application from the environment. If SPACES is
+// set, one App is created for each space, with its own database derived from
+// DB_PATH. Otherwise, a single App is served at the root.
+func newApplication() (closingHandler, error) {
+	limiter := app.NewAuthLimiter(app.AuthLimiterParams{
+		MaxFailures:       AuthMaxFailures(),
+		BlockDuration:     AuthBlockDuration(),
+		MaxBlockDuration:  AuthMaxBlockDuration(),
+		TrustForwardedFor: TrustForwardedFor(),
+		Logger:            slog.Default(),
+	})
+
+	headers, err := SecurityHeaders()
 	if err != nil {
-		slog.Error("failed to read PORT", "error", err)
-		os.Exit(1)
+		return nil, err
 	}
 
-	entries, err := Entries()
+	oidcConfig, err := OIDCConfig()
 	if err != nil {
-		slog.Error("failed to read ENTRIES", "error", err)
-		os.Exit(1)
+		return nil, err
+	}
+	var provider *oidc.Provider
+	if oidcConfig != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		provider, err = oidc.NewProvider(ctx, *oidcConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spaces, err := Spaces()
+	if err != nil {
+		return nil, err
+	}
+	if spaces != nil {
+		for name, params := range spaces {
+			params.AuthLimiter = limiter
+			params.CSRFKey = CSRFKey()
+			params.SecurityHeaders = headers
+			params.OIDC = provider
+			params.PublicURL = PublicURL()
+			params.SessionKey = SessionKey()
+			params.SessionDuration = SessionDuration()
+			params.ClosingSoonWindow = ClosingSoonWindow()
+			params.AutoSaveParams = autosave.Params{
+				FilePath: SpaceDBPath(DBPath(), name),
+				Interval: PersistInterval(),
+				Logger:   slog.Default().With("space", name),
+			}
+			spaces[name] = params
+		}
+		return app.NewSpaces(spaces, headers)
+	}
+
+	entries, parents, err := Entries()
+	if err != nil {
+		return nil, err
 	}
 
 	people, err := People()
 	if err != nil {
-		slog.Error("failed to read PEOPLE", "error", err)
-		os.Exit(1)
+		return nil, err
 	}
 
 	tz, err := Timezone()
 	if err != nil {
-		slog.Error("failed to read TIMEZONE", "error", err)
-		os.Exit(1)
+		return nil, err
 	}
 
 	periods, err := Periods()
 	if err != nil {
-		slog.Error("failed to read PERIODS", "error", err)
-		os.Exit(1)
+		return nil, err
+	}
+
+	identities, err := Identities()
+	if err != nil {
+		return nil, err
 	}
 
-	application, err := app.New(app.Params{
-		Entries:  entries,
-		People:   people,
-		Timezone: tz,
-		Periods:  periods,
+	editors, err := Editors()
+	if err != nil {
+		return nil, err
+	}
+
+	return app.New(app.Params{
+		Entries:           entries,
+		GroupParents:      parents,
+		People:            people,
+		Editors:           editors,
+		Timezone:          tz,
+		Periods:           periods,
+		AuthLimiter:       limiter,
+		CSRFKey:           CSRFKey(),
+		SecurityHeaders:   headers,
+		OIDC:              provider,
+		Identities:        identities,
+		PublicURL:         PublicURL(),
+		SessionKey:        SessionKey(),
+		SessionDuration:   SessionDuration(),
+		ClosingSoonWindow: ClosingSoonWindow(),
 		AutoSaveParams: autosave.Params{
 			FilePath: DBPath(),
 			Interval: PersistInterval(),
 			Logger:   slog.Default(),
 		},
 	})
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	port, err := Port()
+	if err != nil {
+		slog.Error("failed to read PORT", "error", err)
+		os.Exit(1)
+	}
+
+	application, err := newApplication()
 	if err != nil {
 		slog.Error("failed to create app", "error", err)
 		os.Exit(1)
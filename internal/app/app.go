@@ -10,6 +10,7 @@ import (
 	"html/template"
 	"io"
 	"io/fs"
+	"maps"
 	"net/http"
 	"slices"
 	"strings"
@@ -18,6 +19,8 @@ import (
 	"time"
 
 	"github.com/alnvdl/autosave"
+
+	"github.com/alnvdl/anything/internal/oidc"
 )
 
 //go:embed templates/*.html templates/*.json
@@ -26,12 +29,73 @@ var templateFS embed.FS
 //go:embed static/*
 var staticFS embed.FS
 
-// Entry represents a voting entry with its name, group, cost and schedule.
+// Entry represents a voting entry with its name, group, cost, tags, details
+// and schedule.
 type Entry struct {
 	Name  string
 	Group string
-	Open  map[string][]string
-	Cost  int
+	// Place is shared by the locations of a place in different groups,
+	// which share its votes while keeping their own cost and schedule.
+	Place string `json:",omitempty"`
+	// Owner is set for private entries, which only that person can see.
+	Owner string `json:",omitempty"`
+	// Open lists the periods the entry serves on each weekday, and on
+	// holidays under the "hol" key.
+	Open map[string][]string
+	// Hours has the opening hours of each day, with the same keys as Open.
+	// If set, it takes precedence over Open for telling whether the entry
+	// is open.
+	Hours Hours `json:",omitempty"`
+	// Exceptions override Open and Hours on specific dates.
+	Exceptions []Exception `json:",omitempty"`
+	Tags       Tags        `json:",omitempty"`
+	Cost       int
+	Details
+
+	// Archived entries and entries snoozed until a later date are hidden
+	// from the vote and tally pages, but keep their votes.
+	Archived     bool   `json:",omitempty"`
+	SnoozedUntil string `json:",omitempty"` // Date in the "2006-01-02" format.
+}
+
+// Validate returns an error if e cannot be stored. Errors are phrased to
+// follow the name of the entry.
+func (e Entry) Validate() error {
+	if e.Group == "" {
+		return errors.New("has no group")
+	}
+	if strings.Contains(e.Group, "|") {
+		return fmt.Errorf("is in group %q, which contains invalid character '|'", e.Group)
+	}
+	if e.Name == "" {
+		return errors.New("has no name")
+	}
+	if strings.Contains(e.Name, "|") {
+		return errors.New("contains invalid character '|'")
+	}
+	if e.Cost < 1 || e.Cost > 4 {
+		return fmt.Errorf("has an invalid cost %d, which must be between 1 and 4", e.Cost)
+	}
+	if err := e.Hours.Validate(); err != nil {
+		return fmt.Errorf("has invalid hours: %w", err)
+	}
+	for _, ex := range e.Exceptions {
+		if err := ex.Validate(); err != nil {
+			return fmt.Errorf("has an invalid exception: %w", err)
+		}
+	}
+	if err := e.Tags.Validate(); err != nil {
+		return fmt.Errorf("has invalid tags: %w", err)
+	}
+	if err := e.Details.Validate(); err != nil {
+		return fmt.Errorf("has invalid details: %w", err)
+	}
+	if e.SnoozedUntil != "" {
+		if _, err := time.Parse(time.DateOnly, e.SnoozedUntil); err != nil {
+			return fmt.Errorf("has an invalid snoozed_until date: %w", err)
+		}
+	}
+	return nil
 }
 
 // Periods maps period names to [start_hour, end_hour).
@@ -74,21 +138,22 @@ var weekdays = map[time.Weekday]weekdayInfo{
 // db holds all persistent data for the application in memory, and it can be
 // persisted to disk in JSON format by the auto-save mechanism.
 type db struct {
+	Version    int                   `json:"version,omitempty"`
 	Entries    []Entry               `json:"entries"`
 	Votes      map[string]PersonVote `json:"votes"`
 	GroupOrder []string              `json:"groupOrder"`
-}
-
-// entryGroups returns a map from entry names to their set of groups.
-func (d *db) entryGroups() map[string]map[string]bool {
-	result := make(map[string]map[string]bool)
-	for _, e := range d.Entries {
-		if result[e.Name] == nil {
-			result[e.Name] = make(map[string]bool)
-		}
-		result[e.Name][e.Group] = true
-	}
-	return result
+	// GroupParents maps groups nested in other groups to their parent.
+	GroupParents map[string]string `json:"groupParents,omitempty"`
+	Holidays     []Holiday         `json:"holidays"`
+	Periods      Periods           `json:"periods,omitempty"`
+	Plans        []Plan            `json:"plans,omitempty"`
+	// PrivateVoters lists the people who hide their votes from other people.
+	PrivateVoters []string `json:"privateVoters,omitempty"`
+	// Suggestions lists the entries suggested by people, pending approval.
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+	// RevokedSessions maps the IDs of sessions ended by logging out to the
+	// Unix time after which their cookies expire anyway.
+	RevokedSessions map[string]int64 `json:"revokedSessions,omitempty"`
 }
 
 // Params contains all parameters needed to create an App.
@@ -98,6 +163,66 @@ type Params struct {
 	Timezone *time.Location
 	Periods  Periods
 
+	// GroupParents maps groups nested in other groups to their parent. Like
+	// Entries, it is only used if the database has no entries.
+	GroupParents map[string]string
+
+	// Editors lists the people who can change the entries directly and
+	// approve or reject the entries suggested by other people. If empty,
+	// everyone is an editor.
+	Editors []string
+
+	// BasePath is the URL path prefix under which the App is served, without
+	// a trailing slash (e.g., "/s/family"). It is empty when the App is
+	// served at the root. Requests are expected to reach the App with the
+	// prefix already stripped.
+	BasePath string
+
+	// AuthLimiter throttles clients that fail to authenticate too often. It
+	// may be shared by several Apps. If nil, a limiter with default
+	// parameters is created.
+	AuthLimiter *AuthLimiter
+
+	// CSRFKey is the key used to sign the CSRF tokens embedded in forms. If
+	// empty, a random key is generated, and forms loaded before a restart
+	// will have to be reloaded before being submitted.
+	CSRFKey []byte
+
+	// SecurityHeaders overrides the DefaultSecurityHeaders set on every
+	// response. A header with an empty value is not set at all. Header values
+	// may use the "{nonce}" placeholder for the per-request script nonce.
+	SecurityHeaders map[string]string
+
+	// OIDC enables logging in with an OpenID Connect provider as an
+	// alternative to tokens. If nil, only tokens are accepted.
+	OIDC *oidc.Provider
+
+	// Identities maps OIDC subjects or verified email addresses to people.
+	// Identities that are not mapped cannot log in.
+	Identities map[string]string
+
+	// PublicURL is the external URL of the server, without a trailing slash
+	// and without the BasePath (e.g., "https://anything.example.com"). It is
+	// used to build the OIDC redirect URI.
+	PublicURL string
+
+	// SessionKey is the key used to sign session cookies. If empty, a random
+	// key is generated, and everyone will have to log in again after a
+	// restart.
+	SessionKey []byte
+
+	// SessionDuration is how long a login session lasts. Defaults to 30 days.
+	SessionDuration time.Duration
+
+	// ClosingSoonWindow is how long before closing an entry is shown as
+	// closing soon in the tally of the current period. Defaults to 30
+	// minutes.
+	ClosingSoonWindow time.Duration
+
+	// Clock tells the current time, which determines the current date and
+	// period. If nil, the system clock is used.
+	Clock Clock
+
 	// AutoSaveParams is the configuration for auto-save. If FilePath is
 	// empty, auto-save will be disabled and votes will only be kept in
 	// memory. The LoaderSaver field will be set to the created App, so any
@@ -107,45 +232,92 @@ type Params struct {
 
 // pageData holds template data for rendering pages.
 type pageData struct {
-	Title            string
-	Token            string
-	Person           string
-	Period           string
-	Weekday          string
-	PrevWeekdayShort string
-	NextWeekdayShort string
-	Periods          []string
-	Weekdays         []weekdayInfo
-	Groups           []groupData
-}
-
-// groupData holds a group of entries for template rendering.
+	Title     string
+	BasePath  string
+	LoggedIn  bool
+	Token     string
+	CSRFToken string
+	Nonce     string
+	Person    string
+	Period    string
+	Weekday   string
+	Date      string
+	Day       string
+	PrevDate  string
+	NextDate  string
+	Periods   []string
+	Weekdays  []weekdayInfo
+	Groups    []groupData
+	Holidays  []Holiday
+
+	PeriodRows []periodRow
+	Message    string
+	Notice     string
+	Plan       *Plan
+	Planner    []plannerDay
+	Filter     tagFilter
+	TagOptions []tagOption
+
+	PrivateVotes bool
+
+	CanEdit     bool
+	Suggestions []suggestionData
+	Duplicates  []duplicateData
+}
+
+// groupData holds a group of entries for template rendering, with the groups
+// nested in it.
 type groupData struct {
 	Name    string
+	Parent  string
 	Entries []entryData
+	Groups  []groupData
 }
 
 // entryData holds a single entry for template rendering.
 type entryData struct {
-	Name        string
-	Group       string
-	CurrentVote string
-	Score       int
-	Cost        int
-	CostDisplay string
-	Open        map[string][]string
-	Closed      bool
-	StrongNo    bool
+	Name         string
+	Group        string
+	Place        string
+	Locations    []string
+	CurrentVote  string
+	Score        int
+	Cost         int
+	CostDisplay  string
+	Open         map[string][]string
+	Hours        Hours
+	Exceptions   []Exception
+	Tags         Tags
+	Closed       bool
+	StrongNo     bool
+	Status       string
+	StatusTime   string
+	SnoozedUntil string
+	Private      bool
+	Voters       []voterData
+	HiddenVotes  int
+	Details
 }
 
 // App is the core application struct.
 type App struct {
 	people     map[string]string
+	editors    []string
 	tokens     map[string]string
 	timezone   *time.Location
-	periods    Periods
 	periodList []string
-	nowFunc    func() time.Time
+	basePath   string
+	limiter    *AuthLimiter
+	csrfKey    []byte
+	clock      Clock
+
+	oidc            *oidc.Provider
+	identities      map[string]string
+	publicURL       string
+	sessionKey      []byte
+	sessionDuration time.Duration
+
+	closingSoonWindow time.Duration
 
 	mu sync.RWMutex
 	db db
@@ -153,48 +325,101 @@ type App struct {
 	autoSaver *autosave.AutoSaver
 
 	mux          *http.ServeMux
+	handler      http.Handler
 	voteTmpl     *template.Template
 	tallyTmpl    *template.Template
 	editTmpl     *template.Template
+	settingsTmpl *template.Template
+	plannerTmpl  *template.Template
+	archiveTmpl  *template.Template
+	suggestTmpl  *template.Template
 	manifestTmpl *text_template.Template
 }
 
 var tmplFuncs = template.FuncMap{
-	"title": func(s string) string {
-		if len(s) == 0 {
-			return s
-		}
-		return strings.ToUpper(s[:1]) + s[1:]
-	},
+	"title": title,
 	"contains": func(slice []string, item string) bool {
 		return slices.Contains(slice, item)
 	},
+	"join":         strings.Join,
+	"openingHours": formatOpeningHours,
+	"iconParams": func(nonce, size string) iconParams {
+		return iconParams{Nonce: nonce, Size: size}
+	},
+}
+
+// title returns s with its first letter in upper case.
+func title(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// iconParams holds the parameters for rendering the icons template.
+type iconParams struct {
+	Nonce string
+	Size  string
 }
 
 // New creates a new App with the given parameters.
 func New(params Params) (*App, error) {
 	a := &App{
 		people:   params.People,
+		editors:  params.Editors,
 		tokens:   make(map[string]string),
 		timezone: params.Timezone,
-		periods:  params.Periods,
+		basePath: params.BasePath,
+		limiter:  params.AuthLimiter,
+		csrfKey:  params.CSRFKey,
+
+		oidc:            params.OIDC,
+		identities:      params.Identities,
+		publicURL:       strings.TrimSuffix(params.PublicURL, "/"),
+		sessionKey:      params.SessionKey,
+		sessionDuration: params.SessionDuration,
+
+		closingSoonWindow: params.ClosingSoonWindow,
+
 		db: db{
 			Votes: make(map[string]PersonVote),
 		},
-		nowFunc: time.Now,
+		clock: params.Clock,
 	}
 
-	for person, token := range a.people {
-		a.tokens[token] = person
+	if a.clock == nil {
+		a.clock = ClockFunc(time.Now)
+	}
+	if a.limiter == nil {
+		a.limiter = NewAuthLimiter(AuthLimiterParams{})
+	}
+	if len(a.csrfKey) == 0 {
+		a.csrfKey = newSigningKey()
+	}
+	if len(a.sessionKey) == 0 {
+		a.sessionKey = newSigningKey()
+	}
+	if a.sessionDuration <= 0 {
+		a.sessionDuration = defaultSessionDuration
+	}
+	if a.closingSoonWindow <= 0 {
+		a.closingSoonWindow = defaultClosingSoonWindow
+	}
+	for identity, person := range a.identities {
+		if _, ok := a.people[person]; !ok {
+			return nil, fmt.Errorf("identity %q maps to unknown person %q", identity, person)
+		}
 	}
 
-	// Build period list sorted by start time for consistent display.
-	for name := range a.periods {
-		a.periodList = append(a.periodList, name)
+	for _, person := range a.editors {
+		if _, ok := a.people[person]; !ok {
+			return nil, fmt.Errorf("editor %q is an unknown person", person)
+		}
+	}
+
+	for person, token := range a.people {
+		a.tokens[token] = person
 	}
-	slices.SortFunc(a.periodList, func(a, b string) int {
-		return cmp.Compare(params.Periods[a][0], params.Periods[b][0])
-	})
 
 	var err error
 	a.voteTmpl, err = template.New("").Funcs(tmplFuncs).ParseFS(templateFS,
@@ -228,6 +453,42 @@ func New(params Params) (*App, error) {
 		return nil, fmt.Errorf("parsing edit templates: %w", err)
 	}
 
+	a.settingsTmpl, err = template.New("").Funcs(tmplFuncs).ParseFS(templateFS,
+		"templates/layout.html",
+		"templates/nav.html",
+		"templates/settings.html",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parsing settings templates: %w", err)
+	}
+
+	a.plannerTmpl, err = template.New("").Funcs(tmplFuncs).ParseFS(templateFS,
+		"templates/layout.html",
+		"templates/nav.html",
+		"templates/planner.html",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parsing planner templates: %w", err)
+	}
+
+	a.archiveTmpl, err = template.New("").Funcs(tmplFuncs).ParseFS(templateFS,
+		"templates/layout.html",
+		"templates/nav.html",
+		"templates/archive.html",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parsing archive templates: %w", err)
+	}
+
+	a.suggestTmpl, err = template.New("").Funcs(tmplFuncs).ParseFS(templateFS,
+		"templates/layout.html",
+		"templates/nav.html",
+		"templates/suggestions.html",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parsing suggestions templates: %w", err)
+	}
+
 	a.manifestTmpl, err = text_template.New("").ParseFS(templateFS,
 		"templates/manifest.json",
 	)
@@ -246,10 +507,15 @@ func New(params Params) (*App, error) {
 		}
 	}
 
-	// Import entries from config if none were loaded from file.
+	// Import entries and periods from config if none were loaded from file.
 	if len(a.db.Entries) == 0 {
 		a.db.Entries = params.Entries
+		a.db.GroupParents = validGroupParents(params.GroupParents)
 	}
+	if len(a.db.Periods) == 0 {
+		a.setPeriods(params.Periods)
+	}
+	a.migrate()
 
 	// Set up routes.
 	a.mux = http.NewServeMux()
@@ -265,9 +531,36 @@ func New(params Params) (*App, error) {
 	a.mux.HandleFunc("POST /votes", a.handleTallyPost)
 	a.mux.HandleFunc("GET /entries", a.handleEntriesGet)
 	a.mux.HandleFunc("POST /entries", a.handleEntriesPost)
+	a.mux.HandleFunc("POST /entries/bulk", a.handleBulkPost)
+	a.mux.HandleFunc("GET /opening-hours.json", a.handleOpeningHours)
+	a.mux.HandleFunc("GET /settings", a.handleSettingsGet)
+	a.mux.HandleFunc("POST /settings", a.handleSettingsPost)
+	a.mux.HandleFunc("GET /planner", a.handlePlannerGet)
+	a.mux.HandleFunc("POST /planner", a.handlePlannerPost)
+	a.mux.HandleFunc("GET /archive", a.handleArchiveGet)
+	a.mux.HandleFunc("POST /archive", a.handleArchivePost)
+	a.mux.HandleFunc("POST /merge", a.handleMergePost)
+	a.mux.HandleFunc("GET /suggestions", a.handleSuggestionsGet)
+	a.mux.HandleFunc("POST /suggestions", a.handleSuggestionsPost)
+	a.mux.HandleFunc("GET /plans.ics", a.handleCalendar)
 	a.mux.HandleFunc("GET /manifest.json", a.handleManifest)
 	a.mux.HandleFunc("GET /export.json", a.handleExport)
+	a.mux.HandleFunc("POST /import.json", a.handleImport)
+	a.mux.HandleFunc("GET /entries.csv", a.handleCatalogExport)
+	a.mux.HandleFunc("POST /entries.csv", a.handleCatalogImport)
+	a.mux.HandleFunc("GET /entries.yaml", a.handleCatalogExport)
+	a.mux.HandleFunc("POST /entries.yaml", a.handleCatalogImport)
 	a.mux.HandleFunc("GET /status", a.handleStatus)
+	a.mux.HandleFunc("POST /logout", a.handleLogout)
+	if a.oidc != nil {
+		a.mux.HandleFunc("GET /login", a.handleLogin)
+		a.mux.HandleFunc("GET /auth/callback", a.handleAuthCallback)
+	}
+
+	// Reject cross-origin browser requests with non-safe methods, as an
+	// additional layer of protection on top of the CSRF tokens in forms.
+	a.handler = withSecurityHeaders(securityHeaders(params.SecurityHeaders),
+		http.NewCrossOriginProtection().Handler(a.mux))
 
 	return a, nil
 }
@@ -300,6 +593,16 @@ func (a *App) Load(r io.Reader) error {
 	} else if err != nil {
 		return fmt.Errorf("cannot deserialize data: %w", err)
 	}
+	a.loadDB(data)
+	a.migrate()
+	return nil
+}
+
+// loadDB replaces the fields of the in-memory database that are set in data.
+// The caller must hold the write lock.
+func (a *App) loadDB(data db) {
+	// The version is always replaced, so that older databases are migrated.
+	a.db.Version = data.Version
 	if data.Votes != nil {
 		a.db.Votes = data.Votes
 	}
@@ -309,9 +612,84 @@ func (a *App) Load(r io.Reader) error {
 	if data.GroupOrder != nil {
 		a.db.GroupOrder = data.GroupOrder
 	}
+	if data.GroupParents != nil {
+		a.db.GroupParents = validGroupParents(data.GroupParents)
+	}
+	if data.Holidays != nil {
+		a.db.Holidays = data.Holidays
+	}
+	if data.Periods != nil {
+		a.setPeriods(data.Periods)
+	}
+	if data.Plans != nil {
+		a.db.Plans = data.Plans
+	}
+	if data.PrivateVoters != nil {
+		a.db.PrivateVoters = data.PrivateVoters
+	}
+	if data.Suggestions != nil {
+		a.db.Suggestions = data.Suggestions
+	}
+	if data.RevokedSessions != nil {
+		a.db.RevokedSessions = data.RevokedSessions
+	}
+}
+
+// importDB replaces the database with the JSON dump read from r, in the same
+// format produced by Save, on behalf of person. Unlike Load, it rejects
+// incomplete or invalid input, and it cleans the entry names even if the dump
+// is in the current version. What person cannot see is kept.
+func (a *App) importDB(person string, r io.Reader) error {
+	defer a.delayAutoSave()
+
+	var data db
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return fmt.Errorf("cannot deserialize data: %w", err)
+	}
+	if data.Periods != nil {
+		if err := data.Periods.Validate(); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old := a.db
+	a.loadDB(data)
+	// Revoked sessions are not part of the dumps, and logging out cannot
+	// be undone.
+	a.db.RevokedSessions = old.RevokedSessions
+	a.restoreHidden(person, old)
+	a.migrate()
+	a.cleanEntryNames()
+	// Entries are validated once migrated, so that dumps of older versions
+	// are accepted. Hidden entries were kept as they were.
+	if err := a.validateEntries(person); err != nil {
+		a.db = old
+		a.setPeriods(old.Periods)
+		return err
+	}
 	return nil
 }
 
+// validateEntries returns an error if any entry visible to person is invalid
+// or has the name of a private entry person cannot see, or if any two entries
+// are duplicates. The caller must hold the lock.
+func (a *App) validateEntries(person string) error {
+	for _, e := range a.db.Entries {
+		if !e.visibleTo(person) {
+			continue
+		}
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("entry %q in group %q %w", e.Name, e.Group, err)
+		}
+	}
+	if err := checkPrivateNames(person, a.db.Entries); err != nil {
+		return err
+	}
+	return checkDuplicates(a.db.Entries)
+}
+
 // Save serializes data to the given writer.
 func (a *App) Save(w io.Writer) error {
 	a.mu.RLock()
@@ -332,41 +710,98 @@ func (a *App) Close() {
 }
 
 // updateVotes saves votes for a person, cleaning invalid entries and vote values.
-// Form keys are expected in "Group|Entry" format.
-func (a *App) updateVotes(person string, votes map[string]string) {
+// Form keys are expected in "Group|Entry" format. Votes for entries not
+// selected by the filter, archived or snoozed are kept, as they were not shown
+// to the person. Votes for entries the person cannot see are discarded.
+func (a *App) updateVotes(person string, votes map[string]string, f tagFilter) {
 	defer a.delayAutoSave()
+	votable := a.votable(person, f)
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	entryGroup := a.db.entryGroups()
+	entries := make(map[string]Entry)
+	shown := make(map[[2]string]bool)
+	for _, e := range a.db.Entries {
+		entries[e.Group+"|"+e.Name] = e
+		if votable(e) {
+			group, name := e.voteKey()
+			shown[[2]string{group, name}] = true
+		}
+	}
+
 	pv := make(PersonVote)
-	for key, vote := range votes {
-		group, name, ok := strings.Cut(key, "|")
-		if !ok {
+	set := func(group, name string, vote EntryVote) {
+		if pv[group] == nil {
+			pv[group] = make(GroupVote)
+		}
+		pv[group][name] = vote
+	}
+	// Keys are sorted so that the first location of a place wins if its
+	// locations got different votes.
+	for _, key := range slices.Sorted(maps.Keys(votes)) {
+		e, ok := entries[key]
+		if !ok || !e.visibleTo(person) {
 			continue
 		}
-		groups, exists := entryGroup[name]
-		if !exists || !groups[group] {
+		vote := EntryVote(votes[key])
+		if _, ok := voteScores[vote]; !ok {
 			continue
 		}
-		if _, ok := voteScores[EntryVote(vote)]; !ok {
+		if group, name := e.voteKey(); pv[group][name] == "" {
+			set(group, name, vote)
+		}
+	}
+	for _, e := range a.db.Entries {
+		group, name := e.voteKey()
+		if shown[[2]string{group, name}] {
 			continue
 		}
-		if pv[group] == nil {
-			pv[group] = make(GroupVote)
+		if vote, ok := a.db.vote(person, e); ok {
+			set(group, name, vote)
 		}
-		pv[group][name] = EntryVote(vote)
 	}
 	a.db.Votes[person] = pv
 }
 
-// updateEntries replaces all entries.
-func (a *App) updateEntries(entries []Entry) {
+// updateEntries replaces all entries person can see, except for archived
+// entries that are not in entries. It returns an error if entries has
+// duplicates or an entry with the name of a private entry of someone else.
+func (a *App) updateEntries(person string, entries []Entry) error {
+	if err := checkDuplicates(entries); err != nil {
+		return err
+	}
+
 	defer a.delayAutoSave()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	entries = a.keepHidden(person, entries)
+	if err := checkPrivateNames(person, entries); err != nil {
+		return err
+	}
 	a.db.Entries = entries
+	return nil
+}
+
+// updateHolidays replaces the holiday calendar.
+func (a *App) updateHolidays(holidays []Holiday) {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.db.Holidays = holidays
+}
+
+// holidays returns a copy of the holiday calendar sorted by date.
+func (a *App) holidays() []Holiday {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	holidays := slices.Clone(a.db.Holidays)
+	slices.SortFunc(holidays, func(a, b Holiday) int {
+		return cmp.Compare(a.Date, b.Date)
+	})
+	return holidays
 }
 
 // updateGroupOrder replaces the group ordering.
@@ -378,84 +813,84 @@ func (a *App) updateGroupOrder(order []string) {
 	a.db.GroupOrder = order
 }
 
-// entriesData returns grouped entries for rendering templates (vote and edit).
-func (a *App) entriesData(person string) []groupData {
+// entriesData returns grouped entries for rendering templates (vote, edit and
+// archive), with only the entries for which show returns true.
+func (a *App) entriesData(person string, show func(Entry) bool) []groupData {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
 	// Group entries by group name.
 	groupMap := make(map[string][]Entry)
 	for _, e := range a.db.Entries {
+		if !show(e) {
+			continue
+		}
 		groupMap[e.Group] = append(groupMap[e.Group], e)
 	}
 
-	groupNames := make([]string, 0, len(groupMap))
-	for name := range groupMap {
-		groupNames = append(groupNames, name)
-	}
-	sortGroupNames(groupNames, a.db.GroupOrder)
-
-	personVotes := a.db.Votes[person]
-
-	var result []groupData
-	for _, gName := range groupNames {
-		entries := slices.Clone(groupMap[gName])
+	groups := make(map[string][]entryData)
+	for gName, entries := range groupMap {
+		entries := slices.Clone(entries)
 		slices.SortFunc(entries, func(a, b Entry) int {
 			return cmp.Compare(a.Name, b.Name)
 		})
 
 		var eds []entryData
 		for _, e := range entries {
-			vote := ""
-			if personVotes != nil {
-				if gv, ok := personVotes[e.Group]; ok {
-					vote = string(gv[e.Name])
-				}
-			}
+			vote, _ := a.db.vote(person, e)
 			eds = append(eds, entryData{
-				Name:        e.Name,
-				Group:       e.Group,
-				CurrentVote: vote,
-				Cost:        e.Cost,
-				Open:        e.Open,
+				Name:         e.Name,
+				Group:        e.Group,
+				Place:        e.Place,
+				Locations:    a.db.locations(person, e),
+				CurrentVote:  string(vote),
+				Cost:         e.Cost,
+				Open:         e.Open,
+				Hours:        e.Hours,
+				Exceptions:   e.Exceptions,
+				Tags:         e.Tags,
+				SnoozedUntil: e.SnoozedUntil,
+				Private:      e.Owner != "",
+				Details:      e.Details,
 			})
 		}
-
-		result = append(result, groupData{
-			Name:    gName,
-			Entries: eds,
-		})
+		groups[gName] = eds
 	}
 
-	return result
+	return a.db.nestGroups(groups)
 }
 
-// tallyData computes the tally for a given weekday and period.
-func (a *App) tallyData(weekday time.Weekday, period string) []groupData {
+// tallyData computes the tally of person for a given date and period, with
+// only the entries selected by the filter that are visible to person and not
+// hidden on date. If current is true, the tally is for the
+// ongoing period, and entries with opening hours are only considered open if
+// they are open for some of its remaining time.
+func (a *App) tallyData(person string, date time.Time, period string, current bool, f tagFilter) []groupData {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
 	type scored struct {
-		entry    Entry
-		score    int
-		closed   bool
-		strongNo bool
+		entry      Entry
+		score      int
+		closed     bool
+		strongNo   bool
+		status     string
+		statusTime string
 	}
 
 	var items []scored
 	for _, e := range a.db.Entries {
+		if !f.match(e.Tags) || e.hidden(date) || !e.visibleTo(person) {
+			continue
+		}
 		sum := 0
 		strongNo := false
-		for person := range a.people {
+		for voter := range a.people {
 			voteVal := 2 // Default: yes.
-			if personVotes, ok := a.db.Votes[person]; ok {
-				if gv, ok := personVotes[e.Group]; ok {
-					if v, ok := gv[e.Name]; ok {
-						voteVal = voteScores[v]
-						if v == "strong-no" {
-							strongNo = true
-						}
-					}
+			if v, ok := a.db.vote(voter, e); ok {
+				voteVal = voteScores[v]
+				if v == "strong-no" {
+					strongNo = true
 				}
 			}
 			sum += voteVal
@@ -463,15 +898,14 @@ func (a *App) tallyData(weekday time.Weekday, period string) []groupData {
 		nPeople := len(a.people)
 		score := sum*(nPeople+1) - (e.Cost * nPeople)
 
-		// Check if the entry is open for this weekday and period.
-		closed := true
-		if periods, ok := e.Open[weekdays[weekday].Short]; ok {
-			if slices.Contains(periods, period) {
-				closed = false
-			}
+		closed := !a.entryOpen(e, date, period, current)
+
+		var status, statusTime string
+		if current && !closed {
+			status, statusTime = a.entryStatus(e, date, period)
 		}
 
-		items = append(items, scored{e, score, closed, strongNo})
+		items = append(items, scored{e, score, closed, strongNo, status, statusTime})
 	}
 
 	// Group by group name.
@@ -480,13 +914,11 @@ func (a *App) tallyData(weekday time.Weekday, period string) []groupData {
 		groupMap[s.entry.Group] = append(groupMap[s.entry.Group], s)
 	}
 
-	groupNames := make([]string, 0, len(groupMap))
-	for name := range groupMap {
-		groupNames = append(groupNames, name)
-	}
-	sortGroupNames(groupNames, a.db.GroupOrder)
-
 	sortEntries := func(a, b scored) int {
+		// Open now first, then closing soon, then opening later.
+		if c := cmp.Compare(statusRank[a.status], statusRank[b.status]); c != 0 {
+			return c
+		}
 		// Score descending.
 		if c := cmp.Compare(b.score, a.score); c != 0 {
 			return c
@@ -499,10 +931,8 @@ func (a *App) tallyData(weekday time.Weekday, period string) []groupData {
 		return cmp.Compare(a.entry.Name, b.entry.Name)
 	}
 
-	var result []groupData
-	for _, gName := range groupNames {
-		entries := groupMap[gName]
-
+	groups := make(map[string][]entryData)
+	for gName, entries := range groupMap {
 		// Separate open and closed entries.
 		var open, closedEntries []scored
 		for _, s := range entries {
@@ -521,63 +951,91 @@ func (a *App) tallyData(weekday time.Weekday, period string) []groupData {
 
 		var eds []entryData
 		for _, s := range combined {
+			voters, hidden := a.voters(person, s.entry)
 			eds = append(eds, entryData{
 				Name:        s.entry.Name,
+				Group:       s.entry.Group,
+				Place:       s.entry.Place,
+				Locations:   a.db.locations(person, s.entry),
 				Score:       s.score,
 				CostDisplay: strings.Repeat("$", s.entry.Cost),
 				Closed:      s.closed,
 				StrongNo:    s.strongNo,
+				Status:      s.status,
+				StatusTime:  s.statusTime,
+				Tags:        s.entry.Tags,
+				Private:     s.entry.Owner != "",
+				Voters:      voters,
+				HiddenVotes: hidden,
+				Details:     s.entry.Details,
 			})
 		}
-
-		result = append(result, groupData{
-			Name:    gName,
-			Entries: eds,
-		})
+		groups[gName] = eds
 	}
 
-	return result
+	return a.db.nestGroups(groups)
 }
 
-// periodTallyWeekday returns the appropriate weekday for displaying a tally.
-// If the requested period has already passed for the current day, it returns
-// the next day's weekday.
-func (a *App) periodTallyWeekday(period string) time.Weekday {
-	now := a.nowFunc().In(a.timezone)
-	currentHour := now.Hour()
-	currentWeekday := now.Weekday()
-
-	currentPeriod := periodForHour(a.periods, currentHour)
-	if currentPeriod == period {
-		return currentWeekday
+// entryOpen reports whether the entry is open on date during period. Entries
+// with opening hours are checked against the time window of the period, which
+// starts at the current time instead if current is true. The caller must hold
+// the read lock.
+func (a *App) entryOpen(e Entry, date time.Time, period string, current bool) bool {
+	start, end := periodWindow(a.db.Periods[period])
+	if current {
+		date, start, end = a.currentWindow(date, period)
 	}
 
-	currentIdx := slices.Index(a.periodList, currentPeriod)
-	requestedIdx := slices.Index(a.periodList, period)
+	if s := a.entrySchedule(e, date); !s.byHours {
+		return slices.Contains(s.periods, period)
+	}
+	// Intervals of the previous day may run past midnight, and the period
+	// may extend into the next day.
+	for offset := -1; offset <= 1; offset++ {
+		s := a.entrySchedule(e, a.addDays(date, offset))
+		if s.byHours && overlaps(s.hours, offset*minutesPerDay, start, end) {
+			return true
+		}
+	}
+	return false
+}
 
-	if currentIdx >= 0 && requestedIdx >= 0 && requestedIdx < currentIdx {
-		return (currentWeekday + 1) % 7
+// currentWindow returns the window of minutes of period on date that is left
+// at the current time, along with the date on which the period started, which
+// is the day before date past midnight in periods that wrap around it. Minutes
+// are relative to the start of that date, and they follow the wall clock, like
+// opening hours. The caller must hold the read lock.
+func (a *App) currentWindow(date time.Time, period string) (time.Time, int, int) {
+	start, end := periodWindow(a.db.Periods[period])
+	now := a.clock.Now()
+	if periodStart, _ := a.periodInstants(a.db.Periods, date, period); now.Before(periodStart) {
+		// Past midnight in a period that started the day before.
+		date = a.addDays(date, -1)
+	}
+	wall := now.In(a.timezone)
+	minute := wall.Hour()*60 + wall.Minute()
+	if a.dateOf(now).After(date) {
+		minute += minutesPerDay
 	}
+	if start <= minute && minute < end {
+		start = minute
+	}
+	return date, start, end
+}
 
-	return currentWeekday
+// dateForWeekday returns the first date from today on that falls on weekday.
+func (a *App) dateForWeekday(weekday time.Weekday) time.Time {
+	today := a.today()
+	return a.addDays(today, int(weekday-today.Weekday()+7)%7)
 }
 
-// periodForHour returns the period name for a given hour.
-func periodForHour(periods Periods, hour int) string {
-	for name, bounds := range periods {
-		start, end := bounds[0], bounds[1]
-		if start < end {
-			if hour >= start && hour < end {
-				return name
-			}
-		} else if start > end {
-			// Wraps around midnight.
-			if hour >= start || hour < end {
-				return name
-			}
-		}
+// dateLabel returns the month and day of date for display, along with the
+// year if it is not the current one (e.g., "December 14").
+func (a *App) dateLabel(date time.Time) string {
+	if date.Year() != a.today().Year() {
+		return date.Format("January 2, 2006")
 	}
-	return ""
+	return date.Format("January 2")
 }
 
 // weekdayForShort returns the time.Weekday for a short weekday name.
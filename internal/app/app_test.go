@@ -28,6 +28,7 @@ func testEntries() []app.Entry {
 			"tue": {"lunch"},
 			"wed": {"lunch", "dinner"},
 		},
+		Tags: app.Tags{"delivery", "italian"},
 		Cost: 2,
 	}, {
 		Name:  "Burger Joint",
@@ -36,6 +37,7 @@ func testEntries() []app.Entry {
 			"mon": {"lunch", "dinner"},
 			"tue": {"lunch", "dinner"},
 		},
+		Tags: app.Tags{"delivery"},
 		Cost: 1,
 	}, {
 		Name:  "Sushi Bar",
@@ -44,6 +46,7 @@ func testEntries() []app.Entry {
 			"mon": {"dinner"},
 			"fri": {"lunch", "dinner"},
 		},
+		Tags: app.Tags{"japanese"},
 		Cost: 4,
 	}, {
 		Name:  "Taco Stand",
@@ -64,6 +67,9 @@ func testPeople() map[string]string {
 	}
 }
 
+// testMonday is a Monday used as the date of tallies in tests.
+var testMonday = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
 // testPeriods returns test periods config.
 func testPeriods() app.Periods {
 	return app.Periods{
@@ -73,8 +79,9 @@ func testPeriods() app.Periods {
 	}
 }
 
-// newTestApp creates an App for testing. If entries are provided, they are
-// used instead of the default testEntries().
+// newTestApp creates an App for testing, with its clock at noon on
+// testMonday. If entries are provided, they are used instead of the default
+// testEntries().
 func newTestApp(t *testing.T, entries ...app.Entry) *app.App {
 	t.Helper()
 	if len(entries) == 0 {
@@ -89,6 +96,7 @@ func newTestApp(t *testing.T, entries ...app.Entry) *app.App {
 	if err != nil {
 		t.Fatal(err)
 	}
+	a.SetNowFunc(func() time.Time { return testMonday.Add(12 * time.Hour) })
 	return a
 }
 
@@ -187,7 +195,7 @@ func TestUpdateVotes(t *testing.T) {
 		},
 		wantCount: 0,
 	}, {
-		desc: "accepts same entry name in different groups",
+		desc: "same entry name in different groups is migrated to a place",
 		entries: []app.Entry{{
 			Name:  "Shared Name",
 			Group: "GroupA",
@@ -204,7 +212,26 @@ func TestUpdateVotes(t *testing.T) {
 			"GroupA|Shared Name": "strong-yes",
 			"GroupB|Shared Name": "no",
 		},
-		wantCount: 2,
+		wantCount: 1,
+	}, {
+		desc: "locations of a place share one vote",
+		entries: []app.Entry{{
+			Name:  "Chain Downtown",
+			Group: "GroupA",
+			Place: "Chain",
+			Cost:  1,
+		}, {
+			Name:  "Chain Uptown",
+			Group: "GroupB",
+			Place: "Chain",
+			Cost:  2,
+		}},
+		person: "alice",
+		votes: map[string]string{
+			"GroupA|Chain Downtown": "strong-yes",
+			"GroupB|Chain Uptown":   "strong-yes",
+		},
+		wantCount: 1,
 	}}
 
 	for _, test := range tests {
@@ -408,7 +435,7 @@ func TestTallyDataWithGroupOrder(t *testing.T) {
 
 	a.UpdateGroupOrder([]string{"Uptown", "Downtown"})
 
-	groups := a.TallyData(time.Monday, "lunch")
+	groups := a.TallyData(testMonday, "lunch", false)
 
 	if len(groups) != 2 {
 		t.Fatalf("expected 2 groups, got %d", len(groups))
@@ -446,7 +473,7 @@ func TestTallyData(t *testing.T) {
 	// Sushi Bar: (0+2)*(2+1) - (4*2) = 6 - 8 = -2, closed (only dinner on mon).
 	// Taco Stand: (2+2)*(2+1) - (1*2) = 12 - 2 = 10, open.
 
-	groups := a.TallyData(time.Monday, "lunch")
+	groups := a.TallyData(testMonday, "lunch", false)
 
 	if len(groups) != 2 {
 		t.Fatalf("expected 2 groups, got %d", len(groups))
@@ -495,7 +522,7 @@ func TestTallyData(t *testing.T) {
 
 func TestTallyDataCostDisplay(t *testing.T) {
 	a := newTestApp(t)
-	groups := a.TallyData(time.Monday, "lunch")
+	groups := a.TallyData(testMonday, "lunch", false)
 
 	// Find Pizza Place (cost 2) and check display.
 	for _, g := range groups {
@@ -545,7 +572,7 @@ func TestTallyDataSortingTiebreakers(t *testing.T) {
 	// No votes = all default to yes (2). n_people=1.
 	// All entries have score: 2*(1+1) - cost*1.
 	// B Place: 4-2=2, A Place: 4-2=2, C Place: 4-1=3.
-	groups := a.TallyData(time.Monday, "lunch")
+	groups := a.TallyData(testMonday, "lunch", false)
 
 	if len(groups) != 1 || len(groups[0].Entries) != 3 {
 		t.Fatalf("expected 1 group with 3 entries")
@@ -587,7 +614,7 @@ func TestTallyDataClosedAtEnd(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	groups := a.TallyData(time.Monday, "lunch")
+	groups := a.TallyData(testMonday, "lunch", false)
 
 	// Open Low should come first despite lower score.
 	if groups[0].Entries[0].Name != "Open Low" {
@@ -622,74 +649,135 @@ func TestTallyDataDefaultVotes(t *testing.T) {
 
 	// No votes submitted. All 3 people default to yes (2). n_people=3.
 	// Score = (2+2+2)*(3+1) - (1*3) = 24 - 3 = 21.
-	groups := a.TallyData(time.Monday, "lunch")
+	groups := a.TallyData(testMonday, "lunch", false)
 
 	if groups[0].Entries[0].Score != 21 {
 		t.Errorf("score = %d, want 21", groups[0].Entries[0].Score)
 	}
 }
 
-func TestPeriodForHour(t *testing.T) {
-	periods := app.Periods{
-		"breakfast": {0, 10},
+func TestCurrentPeriod(t *testing.T) {
+	// Periods with gaps: no period covers hours 15-17 and 2-5.
+	gapPeriods := app.Periods{
+		"breakfast": {6, 10},
 		"lunch":     {10, 15},
-		"dinner":    {15, 0},
+		"dinner":    {18, 2},
 	}
 
 	var tests = []struct {
-		desc string
-		hour int
-		want string
+		desc       string
+		periods    app.Periods
+		hour       int
+		wantPeriod string
+		wantDate   string
 	}{{
-		desc: "midnight is breakfast",
-		hour: 0,
-		want: "breakfast",
+		desc:       "midnight is breakfast",
+		periods:    testPeriods(),
+		hour:       0,
+		wantPeriod: "breakfast",
+		wantDate:   "2024-01-02",
+	}, {
+		desc:       "9am is breakfast",
+		periods:    testPeriods(),
+		hour:       9,
+		wantPeriod: "breakfast",
+		wantDate:   "2024-01-02",
+	}, {
+		desc:       "10am is lunch",
+		periods:    testPeriods(),
+		hour:       10,
+		wantPeriod: "lunch",
+		wantDate:   "2024-01-02",
+	}, {
+		desc:       "3pm is dinner",
+		periods:    testPeriods(),
+		hour:       15,
+		wantPeriod: "dinner",
+		wantDate:   "2024-01-02",
 	}, {
-		desc: "9am is breakfast",
-		hour: 9,
-		want: "breakfast",
+		desc:       "dinner ending at midnight",
+		periods:    testPeriods(),
+		hour:       23,
+		wantPeriod: "dinner",
+		wantDate:   "2024-01-02",
 	}, {
-		desc: "10am is lunch",
-		hour: 10,
-		want: "lunch",
+		desc:       "start of breakfast",
+		periods:    gapPeriods,
+		hour:       6,
+		wantPeriod: "breakfast",
+		wantDate:   "2024-01-02",
 	}, {
-		desc: "2pm is lunch",
-		hour: 14,
-		want: "lunch",
+		desc:       "end of breakfast is lunch",
+		periods:    gapPeriods,
+		hour:       10,
+		wantPeriod: "lunch",
+		wantDate:   "2024-01-02",
 	}, {
-		desc: "3pm is dinner",
-		hour: 15,
-		want: "dinner",
+		desc:       "2pm is lunch",
+		periods:    gapPeriods,
+		hour:       14,
+		wantPeriod: "lunch",
+		wantDate:   "2024-01-02",
 	}, {
-		desc: "11pm is dinner",
-		hour: 23,
-		want: "dinner",
+		desc:       "end of lunch is a gap",
+		periods:    gapPeriods,
+		hour:       15,
+		wantPeriod: "",
+	}, {
+		desc:       "gap between periods",
+		periods:    gapPeriods,
+		hour:       16,
+		wantPeriod: "",
+	}, {
+		desc:       "11pm is dinner",
+		periods:    gapPeriods,
+		hour:       23,
+		wantPeriod: "dinner",
+		wantDate:   "2024-01-02",
+	}, {
+		desc:       "dinner past midnight started the day before",
+		periods:    gapPeriods,
+		hour:       1,
+		wantPeriod: "dinner",
+		wantDate:   "2024-01-01",
+	}, {
+		desc:       "end of dinner past midnight is a gap",
+		periods:    gapPeriods,
+		hour:       2,
+		wantPeriod: "",
+	}, {
+		desc:       "gap after midnight",
+		periods:    gapPeriods,
+		hour:       3,
+		wantPeriod: "",
 	}}
 
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
-			got := app.PeriodForHour(periods, test.hour)
-			if got != test.want {
-				t.Errorf("PeriodForHour(%d) = %q, want %q", test.hour, got, test.want)
+			a, err := app.New(app.Params{
+				Entries:  testEntries(),
+				People:   testPeople(),
+				Timezone: time.UTC,
+				Periods:  test.periods,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.SetNowFunc(func() time.Time {
+				return time.Date(2024, 1, 2, test.hour, 0, 0, 0, time.UTC)
+			})
+			period, date := a.CurrentPeriod()
+			if period != test.wantPeriod {
+				t.Errorf("CurrentPeriod() at %d:00 = %q, want %q", test.hour, period, test.wantPeriod)
+			}
+			if period != "" && date.Format(time.DateOnly) != test.wantDate {
+				t.Errorf("CurrentPeriod() at %d:00 started on %s, want %s", test.hour, date.Format(time.DateOnly), test.wantDate)
 			}
 		})
 	}
 }
 
-func TestPeriodForHourGap(t *testing.T) {
-	// Periods with a gap at hour 12-17.
-	periods := app.Periods{
-		"morning": {6, 12},
-		"evening": {18, 22},
-	}
-
-	got := app.PeriodForHour(periods, 14)
-	if got != "" {
-		t.Errorf("PeriodForHour(14) = %q, want empty (gap)", got)
-	}
-}
-
-func TestPeriodTallyWeekday(t *testing.T) {
+func TestPeriodTallyDate(t *testing.T) {
 	a := newTestApp(t)
 
 	// Reference: 2024-01-01 is a Monday in UTC.
@@ -759,17 +847,17 @@ func TestPeriodTallyWeekday(t *testing.T) {
 			a.SetNowFunc(func() time.Time {
 				return makeTime(test.currentWeekday, test.currentHour)
 			})
-			got := a.PeriodTallyWeekday(test.period)
+			got := a.PeriodTallyDate(test.period).Weekday()
 			if got != test.want {
-				t.Errorf("PeriodTallyWeekday(period=%q) with hour=%d, weekday=%v = %v, want %v",
+				t.Errorf("PeriodTallyDate(period=%q) with hour=%d, weekday=%v = %v, want %v",
 					test.period, test.currentHour, test.currentWeekday, got, test.want)
 			}
 		})
 	}
 }
 
-func TestPeriodTallyWeekdayWithGaps(t *testing.T) {
-	// Periods with a gap: no period covers hours 12-17.
+func TestPeriodTallyDateWithGaps(t *testing.T) {
+	// Periods with gaps: no period covers hours 12-17 and 22-5.
 	a, err := app.New(app.Params{
 		Entries:  testEntries(),
 		People:   testPeople(),
@@ -783,13 +871,117 @@ func TestPeriodTallyWeekdayWithGaps(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// In a gap, we can't determine the current period, so we return the same day.
-	a.SetNowFunc(func() time.Time {
-		return time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC) // Monday 14:00.
+	var tests = []struct {
+		desc   string
+		now    time.Time
+		period string
+		want   time.Weekday
+	}{{
+		desc:   "period that already started today returns next day",
+		now:    time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC), // Monday 14:00.
+		period: "morning",
+		want:   time.Tuesday,
+	}, {
+		desc:   "period that starts later today returns same day",
+		now:    time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC), // Monday 14:00.
+		period: "evening",
+		want:   time.Monday,
+	}, {
+		desc:   "late at night returns next day",
+		now:    time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC), // Monday 23:00.
+		period: "evening",
+		want:   time.Tuesday,
+	}, {
+		desc:   "early morning returns same day",
+		now:    time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), // Monday 03:00.
+		period: "morning",
+		want:   time.Monday,
+	}, {
+		desc:   "start of the period returns same day",
+		now:    time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC), // Monday 06:00.
+		period: "morning",
+		want:   time.Monday,
+	}, {
+		desc:   "end of the period returns next day",
+		now:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), // Monday 12:00.
+		period: "morning",
+		want:   time.Tuesday,
+	}, {
+		desc:   "end of the last period on saturday returns sunday",
+		now:    time.Date(2024, 1, 6, 22, 0, 0, 0, time.UTC), // Saturday 22:00.
+		period: "evening",
+		want:   time.Sunday,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a.SetNowFunc(func() time.Time { return test.now })
+			got := a.PeriodTallyDate(test.period).Weekday()
+			if got != test.want {
+				t.Errorf("PeriodTallyDate(%q) at %v = %v, want %v", test.period, test.now, got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpcomingPeriod(t *testing.T) {
+	a, err := app.New(app.Params{
+		Entries:  testEntries(),
+		People:   testPeople(),
+		Timezone: time.UTC,
+		Periods: app.Periods{
+			"morning": {6, 12},
+			"evening": {18, 22},
+			"night":   {23, 2},
+		},
 	})
-	got := a.PeriodTallyWeekday("morning")
-	if got != time.Monday {
-		t.Errorf("PeriodTallyWeekday in gap = %v, want Monday", got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		desc       string
+		hour       int
+		wantPeriod string
+		wantDate   string
+	}{{
+		desc:       "gap before a period on the same day",
+		hour:       14,
+		wantPeriod: "evening",
+		wantDate:   "2024-01-01",
+	}, {
+		desc:       "gap before a period wrapping around midnight",
+		hour:       22,
+		wantPeriod: "night",
+		wantDate:   "2024-01-01",
+	}, {
+		desc:       "gap after midnight",
+		hour:       3,
+		wantPeriod: "morning",
+		wantDate:   "2024-01-01",
+	}, {
+		desc:       "during a period",
+		hour:       19,
+		wantPeriod: "night",
+		wantDate:   "2024-01-01",
+	}, {
+		desc:       "last period of the day",
+		hour:       23,
+		wantPeriod: "morning",
+		wantDate:   "2024-01-02",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a.SetNowFunc(func() time.Time {
+				return time.Date(2024, 1, 1, test.hour, 0, 0, 0, time.UTC)
+			})
+			period, date := a.UpcomingPeriod()
+			if period != test.wantPeriod || date.Format(time.DateOnly) != test.wantDate {
+				t.Errorf("UpcomingPeriod() at %d:00 = (%q, %s), want (%q, %s)",
+					test.hour, period, date.Format(time.DateOnly), test.wantPeriod, test.wantDate)
+			}
+		})
 	}
 }
 
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// hidden reports whether the entry is hidden from the vote and tally pages on
+// date, either because it is archived or because it is snoozed until a later
+// date.
+func (e Entry) hidden(date time.Time) bool {
+	return e.Archived || e.SnoozedUntil > date.Format(time.DateOnly)
+}
+
+// votable returns a function reporting whether an entry is shown in the vote
+// page of person today with the filter.
+func (a *App) votable(person string, f tagFilter) func(Entry) bool {
+	today := a.today()
+	return func(e Entry) bool {
+		return f.match(e.Tags) && !e.hidden(today) && e.visibleTo(person)
+	}
+}
+
+// sameEntry returns a function reporting whether an entry has the same name
+// in the same group as e.
+func sameEntry(e Entry) func(Entry) bool {
+	return func(other Entry) bool {
+		return other.Group == e.Group && other.Name == e.Name
+	}
+}
+
+// keepHidden adds to entries the ones that the edit page does not show to
+// person: archived entries, unless entries has one with the same name in the
+// group, and private entries of other people. The caller must hold the read
+// lock.
+func (a *App) keepHidden(person string, entries []Entry) []Entry {
+	for _, e := range a.db.Entries {
+		switch {
+		case !e.visibleTo(person):
+			entries = append(entries, e)
+		case e.Archived && !slices.ContainsFunc(entries, sameEntry(e)):
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// restoreEntry unarchives the entry with name in group, reporting whether
+// there was such an archived entry visible to person.
+func (a *App) restoreEntry(person, group, name string) bool {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := slices.IndexFunc(a.db.Entries, func(e Entry) bool {
+		return e.Group == group && e.Name == name && e.Archived && e.visibleTo(person)
+	})
+	if i < 0 {
+		return false
+	}
+	entries := slices.Clone(a.db.Entries)
+	entries[i].Archived = false
+	a.db.Entries = entries
+	return true
+}
+
+// handleArchiveGet serves the page listing archived entries.
+func (a *App) handleArchiveGet(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	_, periodList := a.periodConfig()
+
+	data := pageData{
+		Title:     "Anything",
+		BasePath:  a.basePath,
+		LoggedIn:  token == "",
+		Token:     token,
+		CSRFToken: a.csrfToken(r, person),
+		Nonce:     requestNonce(r),
+		Periods:   periodList,
+		Groups: flattenGroups(a.entriesData("", func(e Entry) bool {
+			return e.Archived && e.visibleTo(person)
+		})),
+		CanEdit: a.canEdit(person),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := a.archiveTmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleArchivePost restores an archived entry, sent as "Group|Name", on
+// behalf of an editor.
+func (a *App) handleArchivePost(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !a.checkCSRF(w, r, person) || !a.checkEditor(w, person) {
+		return
+	}
+
+	group, name, _ := strings.Cut(r.PostForm.Get("entry"), "|")
+	if !a.restoreEntry(person, group, name) {
+		http.Error(w, "Bad Request: invalid entry", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, a.basePath+"/archive?token="+token, http.StatusSeeOther)
+}
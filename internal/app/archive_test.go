@@ -0,0 +1,188 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+// entryNames returns the names of the entries in groups.
+func entryNames(groups []app.GroupData) []string {
+	var names []string
+	for _, g := range groups {
+		for _, e := range g.Entries {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+func TestHiddenEntries(t *testing.T) {
+	var tests = []struct {
+		desc      string
+		now       time.Time
+		date      time.Time
+		wantVote  []string
+		wantTally []string
+	}{{
+		desc:      "snoozed",
+		now:       testMonday.Add(12 * time.Hour),
+		date:      testMonday,
+		wantVote:  []string{"Burger Joint", "Taco Stand"},
+		wantTally: []string{"Burger Joint", "Taco Stand"},
+	}, {
+		desc:      "last day snoozed",
+		now:       testMonday.AddDate(0, 0, 2).Add(-time.Minute),
+		date:      testMonday,
+		wantVote:  []string{"Burger Joint", "Taco Stand"},
+		wantTally: []string{"Burger Joint", "Taco Stand"},
+	}, {
+		desc:      "reappears at the date",
+		now:       testMonday.AddDate(0, 0, 2),
+		date:      testMonday,
+		wantVote:  []string{"Burger Joint", "Sushi Bar", "Taco Stand"},
+		wantTally: []string{"Burger Joint", "Taco Stand"},
+	}, {
+		// Tallies for dates after the snooze show the entry again, even if
+		// it is still snoozed today.
+		desc:      "tally after the date",
+		now:       testMonday.Add(12 * time.Hour),
+		date:      testMonday.AddDate(0, 0, 4),
+		wantVote:  []string{"Burger Joint", "Taco Stand"},
+		wantTally: []string{"Burger Joint", "Sushi Bar", "Taco Stand"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			a.SetNowFunc(func() time.Time { return test.now })
+			a.UpdateVotes("alice", map[string]string{
+				"Downtown|Pizza Place": "strong-no",
+				"Uptown|Sushi Bar":     "no",
+			})
+
+			// Archive Pizza Place and snooze Sushi Bar until Wednesday.
+			entries := testEntries()
+			entries[0].Archived = true
+			entries[2].SnoozedUntil = "2024-01-03"
+			a.UpdateEntries(entries)
+
+			if got := entryNames(a.VotePageData("alice")); !reflect.DeepEqual(got, test.wantVote) {
+				t.Errorf("vote page entries = %v, want %v", got, test.wantVote)
+			}
+			if got := entryNames(a.TallyData(test.date, "lunch", false)); !reflect.DeepEqual(got, test.wantTally) {
+				t.Errorf("tally entries = %v, want %v", got, test.wantTally)
+			}
+
+			// Votes for hidden entries are kept.
+			a.UpdateVotes("alice", map[string]string{
+				"Downtown|Burger Joint": "yes",
+				"Uptown|Sushi Bar":      "no",
+			})
+			want := app.PersonVote{
+				"Downtown": {"Pizza Place": "strong-no", "Burger Joint": "yes"},
+				"Uptown":   {"Sushi Bar": "no"},
+			}
+			if got := a.Votes()["alice"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("votes = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHiddenEntriesSnoozeTimezone(t *testing.T) {
+	loc := loadLocation(t, "America/New_York")
+	entries := testEntries()
+	entries[0].SnoozedUntil = "2024-01-02"
+	a, err := app.New(app.Params{
+		Entries:  entries,
+		People:   testPeople(),
+		Timezone: loc,
+		Periods:  testPeriods(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	// It is already January 2 in UTC, but not in New York.
+	a.SetNowFunc(func() time.Time { return time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC) })
+	if names := entryNames(a.VotePageData("alice")); len(names) != 3 {
+		t.Errorf("vote page entries = %v, want the snoozed entry to be hidden", names)
+	}
+
+	a.SetNowFunc(func() time.Time { return time.Date(2024, 1, 2, 5, 0, 0, 0, time.UTC) })
+	if names := entryNames(a.VotePageData("alice")); len(names) != 4 {
+		t.Errorf("vote page entries = %v, want the snoozed entry to be shown", names)
+	}
+}
+
+func TestArchivePage(t *testing.T) {
+	entries := testEntries()
+	entries[0].Archived = true
+	entries[2].SnoozedUntil = "2024-01-03"
+	a := newTestApp(t, entries...)
+
+	req := httptest.NewRequest("GET", "/archive?token=tokenA", nil)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `<input type="hidden" name="entry" value="Downtown|Pizza Place" />`) {
+		t.Errorf("body does not list the archived entry")
+	}
+	if strings.Contains(body, "Sushi Bar") {
+		t.Errorf("body lists the snoozed entry")
+	}
+
+	// Saving the edit page, which does not show archived entries, keeps them.
+	a.UpdateEntries(entries[1:])
+	if _, ok := findEntry(a.Entries(), "Downtown", "Pizza Place"); !ok {
+		t.Fatalf("archived entry was deleted")
+	}
+
+	var tests = []struct {
+		desc       string
+		entry      string
+		wantStatus int
+	}{{
+		desc:       "not archived",
+		entry:      "Uptown|Sushi Bar",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		desc:       "unknown",
+		entry:      "Downtown|Noodle House",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		desc:       "restore",
+		entry:      "Downtown|Pizza Place",
+		wantStatus: http.StatusSeeOther,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			form := url.Values{"_csrf": {a.CSRFToken("alice")}, "entry": {test.entry}}
+			req := httptest.NewRequest("POST", "/archive?token=tokenA", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+		})
+	}
+
+	if e, _ := findEntry(a.Entries(), "Downtown", "Pizza Place"); e.Archived {
+		t.Errorf("entry is still archived")
+	}
+}
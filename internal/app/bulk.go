@@ -0,0 +1,192 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// bulkChange returns the change to apply to the entries selected in the edit
+// page for the bulk action in form:
+//   - "cost" sets the cost to "cost";
+//   - "open" opens the entries on "day" for "period", or for all periods if
+//     "period" is empty, failing for entries with opening hours, which take
+//     precedence over the periods;
+//   - "close" closes the entries on "day" for "period", or for the whole day
+//     if "period" is empty, in which case the opening hours of that day are
+//     cleared as well;
+//   - "move" moves the entries to "group";
+//   - "archive" archives the entries.
+//
+// The caller must hold the read lock.
+func (a *App) bulkChange(form url.Values) (func(Entry) (Entry, error), error) {
+	periods, periodList := a.db.Periods, a.periodList
+
+	switch form.Get("action") {
+	case "cost":
+		cost, err := strconv.Atoi(form.Get("cost"))
+		if err != nil || cost < 1 || cost > 4 {
+			return nil, errors.New("invalid cost")
+		}
+		return func(e Entry) (Entry, error) {
+			e.Cost = cost
+			return e, nil
+		}, nil
+	case "open", "close":
+		day := form.Get("day")
+		if day != holidayKey && !slices.ContainsFunc(slices.Collect(maps.Values(weekdays)), func(wd weekdayInfo) bool {
+			return wd.Short == day
+		}) {
+			return nil, errors.New("invalid day")
+		}
+		names := periodList
+		if period := form.Get("period"); period != "" {
+			if _, ok := periods[period]; !ok {
+				return nil, errors.New("invalid period")
+			}
+			names = []string{period}
+		}
+		if form.Get("action") == "open" {
+			return func(e Entry) (Entry, error) {
+				if e.Hours != nil {
+					return e, fmt.Errorf("%q in %q has opening hours, which take precedence over the periods", e.Name, e.Group)
+				}
+				e.Open = maps.Clone(e.Open)
+				if e.Open == nil {
+					e.Open = make(map[string][]string)
+				}
+				open := slices.Clone(e.Open[day])
+				for _, name := range names {
+					if !slices.Contains(open, name) {
+						open = append(open, name)
+					}
+				}
+				e.Open[day] = open
+				return e, nil
+			}, nil
+		}
+		wholeDay := form.Get("period") == ""
+		return func(e Entry) (Entry, error) {
+			e.Open = maps.Clone(e.Open)
+			open := slices.DeleteFunc(slices.Clone(e.Open[day]), func(name string) bool {
+				return slices.Contains(names, name)
+			})
+			if len(open) > 0 {
+				e.Open[day] = open
+			} else {
+				delete(e.Open, day)
+			}
+			if wholeDay && e.Hours != nil {
+				e.Hours = maps.Clone(e.Hours)
+				delete(e.Hours, day)
+			}
+			return e, nil
+		}, nil
+	case "move":
+		group := cleanName(form.Get("group"))
+		if group == "" || strings.Contains(group, "|") {
+			return nil, errors.New("invalid group")
+		}
+		return func(e Entry) (Entry, error) {
+			e.Group = group
+			return e, nil
+		}, nil
+	case "archive":
+		return func(e Entry) (Entry, error) {
+			e.Archived = true
+			return e, nil
+		}, nil
+	}
+	return nil, errors.New("invalid action")
+}
+
+// bulkUpdate applies the bulk action in form to the entries sent as
+// "Group|Name" in its "entry" field on behalf of person, all at once. Nothing
+// is changed if any of the entries cannot be found or changed, or if moving
+// them would make them duplicates of other entries. The votes and plans of
+// moved entries go with them.
+func (a *App) bulkUpdate(person string, form url.Values) error {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	change, err := a.bulkChange(form)
+	if err != nil {
+		return err
+	}
+
+	var indices []int
+	for _, key := range form["entry"] {
+		group, name, _ := strings.Cut(key, "|")
+		i := slices.IndexFunc(a.db.Entries, func(e Entry) bool {
+			return e.Group == group && e.Name == name && e.visibleTo(person)
+		})
+		if i < 0 {
+			return fmt.Errorf("%q is not an entry", key)
+		}
+		if !slices.Contains(indices, i) {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		return errors.New("no entries selected")
+	}
+
+	old := a.db.Entries
+	entries := slices.Clone(old)
+	for _, i := range indices {
+		if entries[i], err = change(entries[i]); err != nil {
+			return err
+		}
+	}
+	for _, i := range indices {
+		if entries[i].Group == old[i].Group {
+			continue
+		}
+		isDuplicate := duplicateOf(entries[i])
+		for j, other := range entries {
+			if j != i && isDuplicate(other) {
+				return fmt.Errorf("%q and %q in %q are duplicates", entries[i].Name, other.Name, other.Group)
+			}
+		}
+	}
+
+	a.db.Entries = entries
+	for _, i := range indices {
+		if entries[i].Group != old[i].Group {
+			a.moveEntryData(old[i], entries[i])
+		}
+	}
+	return nil
+}
+
+// handleBulkPost applies a bulk action to the entries selected in the edit
+// page, sent as "Group|Name" in the "entry" field, on behalf of an editor.
+func (a *App) handleBulkPost(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !a.checkCSRF(w, r, person) || !a.checkEditor(w, person) {
+		return
+	}
+
+	if err := a.bulkUpdate(person, r.PostForm); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, a.basePath+"/entries?token="+token, http.StatusSeeOther)
+}
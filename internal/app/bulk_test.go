@@ -0,0 +1,197 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestBulkUpdate(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		form     url.Values
+		wantCode int
+		want     map[string]app.Entry
+	}{{
+		desc: "set cost",
+		form: url.Values{
+			"action": {"cost"},
+			"cost":   {"3"},
+			"entry":  {"Downtown|Pizza Place", "Uptown|Sushi Bar"},
+		},
+		wantCode: http.StatusSeeOther,
+		want: map[string]app.Entry{
+			"Downtown|Pizza Place": {Cost: 3, Open: map[string][]string{"mon": {"lunch", "dinner"}, "tue": {"lunch"}, "wed": {"lunch", "dinner"}}, Hours: app.Hours{"mon": {"11:00-23:00"}, "tue": {"11:00-15:00"}}},
+			"Uptown|Sushi Bar":     {Cost: 3, Open: map[string][]string{"mon": {"dinner"}, "fri": {"lunch", "dinner"}}},
+		},
+	}, {
+		desc: "close a whole day",
+		form: url.Values{
+			"action": {"close"},
+			"day":    {"mon"},
+			"entry":  {"Downtown|Pizza Place", "Downtown|Burger Joint"},
+		},
+		wantCode: http.StatusSeeOther,
+		want: map[string]app.Entry{
+			"Downtown|Pizza Place":  {Cost: 2, Open: map[string][]string{"tue": {"lunch"}, "wed": {"lunch", "dinner"}}, Hours: app.Hours{"tue": {"11:00-15:00"}}},
+			"Downtown|Burger Joint": {Cost: 1, Open: map[string][]string{"tue": {"lunch", "dinner"}}},
+		},
+	}, {
+		desc: "close a period",
+		form: url.Values{
+			"action": {"close"},
+			"day":    {"mon"},
+			"period": {"dinner"},
+			"entry":  {"Downtown|Pizza Place"},
+		},
+		wantCode: http.StatusSeeOther,
+		want: map[string]app.Entry{
+			"Downtown|Pizza Place": {Cost: 2, Open: map[string][]string{"mon": {"lunch"}, "tue": {"lunch"}, "wed": {"lunch", "dinner"}}, Hours: app.Hours{"mon": {"11:00-23:00"}, "tue": {"11:00-15:00"}}},
+		},
+	}, {
+		desc: "open a period",
+		form: url.Values{
+			"action": {"open"},
+			"day":    {"sun"},
+			"period": {"lunch"},
+			"entry":  {"Uptown|Sushi Bar"},
+		},
+		wantCode: http.StatusSeeOther,
+		want: map[string]app.Entry{
+			"Uptown|Sushi Bar": {Cost: 4, Open: map[string][]string{"mon": {"dinner"}, "fri": {"lunch", "dinner"}, "sun": {"lunch"}}},
+		},
+	}, {
+		desc: "open all periods",
+		form: url.Values{
+			"action": {"open"},
+			"day":    {"mon"},
+			"entry":  {"Uptown|Sushi Bar"},
+		},
+		wantCode: http.StatusSeeOther,
+		want: map[string]app.Entry{
+			"Uptown|Sushi Bar": {Cost: 4, Open: map[string][]string{"mon": {"dinner", "breakfast", "lunch"}, "fri": {"lunch", "dinner"}}},
+		},
+	}, {
+		desc: "open an entry with opening hours",
+		form: url.Values{
+			"action": {"open"},
+			"day":    {"sun"},
+			"entry":  {"Uptown|Sushi Bar", "Downtown|Pizza Place"},
+		},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc: "archive",
+		form: url.Values{
+			"action": {"archive"},
+			"entry":  {"Uptown|Sushi Bar"},
+		},
+		wantCode: http.StatusSeeOther,
+		want: map[string]app.Entry{
+			"Uptown|Sushi Bar": {Cost: 4, Open: map[string][]string{"mon": {"dinner"}, "fri": {"lunch", "dinner"}}, Archived: true},
+		},
+	}, {
+		desc: "unknown entry changes nothing",
+		form: url.Values{
+			"action": {"cost"},
+			"cost":   {"3"},
+			"entry":  {"Uptown|Sushi Bar", "Uptown|Nothing"},
+		},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "no entries",
+		form:     url.Values{"action": {"archive"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "invalid cost",
+		form:     url.Values{"action": {"cost"}, "cost": {"5"}, "entry": {"Uptown|Sushi Bar"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "invalid day",
+		form:     url.Values{"action": {"close"}, "day": {"someday"}, "entry": {"Uptown|Sushi Bar"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "invalid period",
+		form:     url.Values{"action": {"open"}, "day": {"mon"}, "period": {"brunch"}, "entry": {"Uptown|Sushi Bar"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "invalid action",
+		form:     url.Values{"action": {"delete"}, "entry": {"Uptown|Sushi Bar"}},
+		wantCode: http.StatusBadRequest,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			entries := testEntries()
+			entries[0].Hours = app.Hours{"mon": {"11:00-23:00"}, "tue": {"11:00-15:00"}}
+			a := newTestApp(t, entries...)
+
+			test.form.Set("_csrf", a.CSRFToken("alice"))
+			req := httptest.NewRequest("POST", "/entries/bulk?token=tokenA", strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if w.Code != test.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantCode)
+			}
+
+			for _, e := range a.Entries() {
+				key := e.Group + "|" + e.Name
+				i := slices.IndexFunc(entries, func(other app.Entry) bool { return other.Group == e.Group && other.Name == e.Name })
+				want, ok := test.want[key]
+				if !ok {
+					want = entries[i]
+				}
+				want.Name, want.Group, want.Tags = e.Name, e.Group, entries[i].Tags
+				if !entryMatches(e, want) {
+					t.Errorf("%s = %+v, want %+v", key, e, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkMove(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdateVotes("alice", map[string]string{"Downtown|Pizza Place": "yes", "Uptown|Sushi Bar": "no"})
+	a.UpdatePlan(app.Plan{Date: "2099-01-01", Period: "lunch", Group: "Downtown", Name: "Pizza Place"})
+
+	post := func(group string, keys ...string) int {
+		form := url.Values{
+			"_csrf":  {a.CSRFToken("alice")},
+			"action": {"move"},
+			"group":  {group},
+			"entry":  keys,
+		}
+		req := httptest.NewRequest("POST", "/entries/bulk?token=tokenA", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(" Midtown ", "Downtown|Pizza Place", "Uptown|Sushi Bar"); code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", code, http.StatusSeeOther)
+	}
+	wantVotes := map[string]app.PersonVote{"alice": {"Midtown": {"Pizza Place": "yes", "Sushi Bar": "no"}}}
+	if votes := a.Votes(); !reflect.DeepEqual(votes, wantVotes) {
+		t.Errorf("votes = %v, want %v", votes, wantVotes)
+	}
+	if plans := a.Plans(); len(plans) != 1 || plans[0].Group != "Midtown" {
+		t.Errorf("plans = %+v, want the plan moved to Midtown", plans)
+	}
+
+	// Moving entries into duplicates changes nothing.
+	a.UpdateEntries(append(a.Entries(), app.Entry{Name: "pizza place", Group: "Uptown", Cost: 1}))
+	if code := post("Uptown", "Downtown|Burger Joint", "Midtown|Pizza Place"); code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if _, ok := findEntry(a.Entries(), "Downtown", "Burger Joint"); !ok {
+		t.Errorf("Burger Joint was moved")
+	}
+}
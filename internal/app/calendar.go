@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// holidayKey is the schedule key used in Entry.Open and Entry.Hours for the
+// schedule of an entry on holidays. Entries without it follow their regular
+// weekday schedule on holidays.
+const holidayKey = "hol"
+
+// Holiday is a date in the global holiday calendar.
+type Holiday struct {
+	Date string // In "2006-01-02" format.
+	Name string
+}
+
+// Exception overrides the weekly schedule of an entry between two dates,
+// inclusive. Open lists the periods the entry serves on these dates, and Hours
+// its opening intervals, in the same format used by the Hours type. If Hours
+// is set, it takes precedence over Open, and if neither is set, the entry is
+// closed.
+type Exception struct {
+	From  string   // In "2006-01-02" format.
+	To    string   // In "2006-01-02" format. If empty, the same as From.
+	Open  []string `json:",omitempty"`
+	Hours []string `json:",omitempty"`
+}
+
+// Validate checks that the dates and opening intervals of the exception are
+// well-formed.
+func (ex Exception) Validate() error {
+	from, err := time.Parse(time.DateOnly, ex.From)
+	if err != nil {
+		return fmt.Errorf("invalid start date %q", ex.From)
+	}
+	if ex.To != "" {
+		to, err := time.Parse(time.DateOnly, ex.To)
+		if err != nil {
+			return fmt.Errorf("invalid end date %q", ex.To)
+		}
+		if to.Before(from) {
+			return fmt.Errorf("end date %q is before start date %q", ex.To, ex.From)
+		}
+	}
+	for _, interval := range ex.Hours {
+		if _, _, err := parseInterval(interval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// covers reports whether the exception applies to day, in "2006-01-02"
+// format. Dates in this format sort lexicographically.
+func (ex Exception) covers(day string) bool {
+	to := ex.To
+	if to == "" {
+		to = ex.From
+	}
+	return ex.From <= day && day <= to
+}
+
+// validHoliday reports whether h has a well-formed date.
+func validHoliday(h Holiday) bool {
+	_, err := time.Parse(time.DateOnly, h.Date)
+	return err == nil
+}
+
+// daySchedule is the effective schedule of an entry on a specific date.
+type daySchedule struct {
+	periods []string
+	hours   []string
+	byHours bool
+}
+
+// entrySchedule returns the schedule of the entry on date, taking its
+// exceptions and the holiday calendar into account. The caller must hold the
+// read lock.
+func (a *App) entrySchedule(e Entry, date time.Time) daySchedule {
+	day := date.Format(time.DateOnly)
+	for _, ex := range e.Exceptions {
+		if ex.covers(day) {
+			return daySchedule{periods: ex.Open, hours: ex.Hours, byHours: len(ex.Hours) > 0}
+		}
+	}
+
+	key := weekdays[date.Weekday()].Short
+	holiday := slices.ContainsFunc(a.db.Holidays, func(h Holiday) bool {
+		return h.Date == day
+	})
+	if len(e.Hours) > 0 {
+		if _, ok := e.Hours[holidayKey]; ok && holiday {
+			key = holidayKey
+		}
+		return daySchedule{hours: e.Hours[key], byHours: true}
+	}
+	if _, ok := e.Open[holidayKey]; ok && holiday {
+		key = holidayKey
+	}
+	return daySchedule{periods: e.Open[key]}
+}
@@ -0,0 +1,220 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestExceptionValidate(t *testing.T) {
+	var tests = []struct {
+		desc      string
+		exception app.Exception
+		wantErr   string
+	}{{
+		desc:      "single day",
+		exception: app.Exception{From: "2024-12-25"},
+	}, {
+		desc:      "date range with hours",
+		exception: app.Exception{From: "2024-12-20", To: "2025-01-05", Hours: []string{"10:00-14:00"}},
+	}, {
+		desc:      "invalid start date",
+		exception: app.Exception{From: "2024-13-01"},
+		wantErr:   `invalid start date "2024-13-01"`,
+	}, {
+		desc:      "invalid end date",
+		exception: app.Exception{From: "2024-12-20", To: "tomorrow"},
+		wantErr:   `invalid end date "tomorrow"`,
+	}, {
+		desc:      "end before start",
+		exception: app.Exception{From: "2025-01-05", To: "2024-12-20"},
+		wantErr:   "is before start date",
+	}, {
+		desc:      "invalid hours",
+		exception: app.Exception{From: "2024-12-25", Hours: []string{"10:00"}},
+		wantErr:   "not in HH:MM-HH:MM format",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.exception.Validate()
+			if !errorContains(err, test.wantErr) {
+				t.Errorf("Validate() err = %v, wantErr = %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTallyDataCalendar(t *testing.T) {
+	entries := []app.Entry{{
+		Name:  "Vacationer",
+		Group: "Group",
+		Open:  map[string][]string{"mon": {"lunch"}, "tue": {"lunch"}},
+		Exceptions: []app.Exception{{
+			From: "2023-12-30",
+			To:   "2024-01-01",
+		}},
+		Cost: 1,
+	}, {
+		Name:  "Holiday Opener",
+		Group: "Group",
+		Open:  map[string][]string{"tue": {"lunch"}, "hol": {"lunch", "dinner"}},
+		Cost:  1,
+	}, {
+		Name:  "Regular",
+		Group: "Group",
+		Open:  map[string][]string{"mon": {"lunch"}, "tue": {"lunch"}},
+		Cost:  1,
+	}, {
+		Name:  "Special Hours",
+		Group: "Group",
+		Hours: app.Hours{"mon": {"11:00-14:00"}, "tue": {"11:00-14:00"}},
+		Exceptions: []app.Exception{{
+			From:  "2024-01-02",
+			Hours: []string{"18:00-20:00"},
+		}, {
+			From: "2024-01-08",
+			Open: []string{"lunch"},
+		}},
+		Cost: 1,
+	}}
+
+	var tests = []struct {
+		desc       string
+		date       time.Time
+		period     string
+		wantClosed map[string]bool
+	}{{
+		desc:   "exception closes and holiday opens",
+		date:   testMonday, // 2024-01-01, a holiday.
+		period: "lunch",
+		wantClosed: map[string]bool{
+			"Vacationer":     true,
+			"Holiday Opener": false,
+			"Regular":        false,
+			"Special Hours":  false,
+		},
+	}, {
+		desc:   "regular schedule after the exception",
+		date:   testMonday.AddDate(0, 0, 1), // 2024-01-02.
+		period: "lunch",
+		wantClosed: map[string]bool{
+			"Vacationer":     false,
+			"Holiday Opener": false,
+			"Regular":        false,
+			"Special Hours":  true,
+		},
+	}, {
+		desc:   "exception hours",
+		date:   testMonday.AddDate(0, 0, 1), // 2024-01-02.
+		period: "dinner",
+		wantClosed: map[string]bool{
+			"Vacationer":     true,
+			"Holiday Opener": true,
+			"Regular":        true,
+			"Special Hours":  false,
+		},
+	}, {
+		desc:   "exception periods override opening hours",
+		date:   testMonday.AddDate(0, 0, 7), // 2024-01-08.
+		period: "lunch",
+		wantClosed: map[string]bool{
+			"Vacationer":     false,
+			"Holiday Opener": true,
+			"Regular":        false,
+			"Special Hours":  false,
+		},
+	}, {
+		desc:   "holiday schedule",
+		date:   testMonday,
+		period: "dinner",
+		wantClosed: map[string]bool{
+			"Vacationer":     true,
+			"Holiday Opener": false,
+			"Regular":        true,
+			"Special Hours":  true,
+		},
+	}}
+
+	a, err := app.New(app.Params{
+		Entries:  entries,
+		People:   map[string]string{"alice": "t1"},
+		Timezone: time.UTC,
+		Periods:  testPeriods(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.UpdateHolidays([]app.Holiday{{Date: "2024-01-01", Name: "New Year"}})
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			groups := a.TallyData(test.date, test.period, false)
+			for _, e := range groups[0].Entries {
+				if e.Closed != test.wantClosed[e.Name] {
+					t.Errorf("%s: Closed = %v, want %v", e.Name, e.Closed, test.wantClosed[e.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestDateForWeekday(t *testing.T) {
+	tz, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.New(app.Params{
+		People:   testPeople(),
+		Timezone: tz,
+		Periods:  testPeriods(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monday 01:00 UTC is still Sunday in Sao Paulo.
+	a.SetNowFunc(func() time.Time {
+		return time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	})
+
+	var tests = []struct {
+		desc    string
+		weekday time.Weekday
+		want    string
+	}{{
+		desc:    "today",
+		weekday: time.Sunday,
+		want:    "2023-12-31",
+	}, {
+		desc:    "tomorrow",
+		weekday: time.Monday,
+		want:    "2024-01-01",
+	}, {
+		desc:    "later this week",
+		weekday: time.Saturday,
+		want:    "2024-01-06",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := a.DateForWeekday(test.weekday)
+			if got.Format(time.DateOnly) != test.want || got.Location() != tz {
+				t.Errorf("DateForWeekday(%v) = %v, want %s in %v", test.weekday, got, test.want, tz)
+			}
+		})
+	}
+}
+
+func TestHolidays(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdateHolidays([]app.Holiday{
+		{Date: "2024-12-25", Name: "Christmas"},
+		{Date: "2024-01-01", Name: "New Year"},
+	})
+
+	got := a.Holidays()
+	if len(got) != 2 || got[0].Name != "New Year" || got[1].Name != "Christmas" {
+		t.Errorf("Holidays() = %v, want holidays sorted by date", got)
+	}
+}
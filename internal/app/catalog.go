@@ -0,0 +1,414 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// CatalogFormat is a format for the catalog of entries, which has the shared
+// entries and the parents of their groups, but no votes or other data.
+type CatalogFormat string
+
+// The supported catalog formats. JSON is the format of the ENTRIES
+// environment variable, with nested groups given as objects in place of
+// entries. YAML has the same structure. CSV has one row per entry, as
+// described in parseCatalogCSV.
+const (
+	CatalogJSON CatalogFormat = "json"
+	CatalogCSV  CatalogFormat = "csv"
+	CatalogYAML CatalogFormat = "yaml"
+)
+
+// catalogContentTypes maps the catalog formats served by the app to their
+// content types.
+var catalogContentTypes = map[CatalogFormat]string{
+	CatalogCSV:  "text/csv; charset=utf-8",
+	CatalogYAML: "application/yaml; charset=utf-8",
+}
+
+// catalogEntry holds the serializable configuration of an entry in the
+// catalog.
+type catalogEntry struct {
+	Place      string              `json:"place,omitempty"`
+	Cost       int                 `json:"cost"`
+	Open       map[string][]string `json:"open,omitempty"`
+	Hours      Hours               `json:"hours,omitempty"`
+	Exceptions []catalogException  `json:"exceptions,omitempty"`
+	Tags       Tags                `json:"tags,omitempty"`
+	Notes      string              `json:"notes,omitempty"`
+	URL        string              `json:"url,omitempty"`
+	Address    string              `json:"address,omitempty"`
+	Phone      string              `json:"phone,omitempty"`
+
+	Archived     bool   `json:"archived,omitempty"`
+	SnoozedUntil string `json:"snoozed_until,omitempty"`
+}
+
+// catalogException is an Exception with the field names used in the catalog.
+type catalogException struct {
+	From  string   `json:"from"`
+	To    string   `json:"to,omitempty"`
+	Open  []string `json:"open,omitempty"`
+	Hours []string `json:"hours,omitempty"`
+}
+
+// ParseCatalog parses and validates the entries in a catalog in format,
+// returning them with a map of nested groups to their parents. The name is
+// used to identify the source of the catalog in error messages.
+func ParseCatalog(format CatalogFormat, name string, data []byte) ([]Entry, map[string]string, error) {
+	var entries []Entry
+	var parents map[string]string
+	var err error
+	switch format {
+	case CatalogJSON:
+		entries, parents, err = parseCatalogJSON(name, data)
+	case CatalogCSV:
+		entries, parents, err = parseCatalogCSV(name, data)
+	case CatalogYAML:
+		j, yamlErr := yamlToJSON(data)
+		if yamlErr != nil {
+			return nil, nil, fmt.Errorf("%s is not valid YAML: %w", name, yamlErr)
+		}
+		entries, parents, err = parseCatalogJSON(name, j)
+	default:
+		return nil, nil, fmt.Errorf("unknown catalog format %q", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// Entry names are cleaned, so different names in the catalog may
+	// become duplicates.
+	if err := checkDuplicates(entries); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return entries, parents, nil
+}
+
+// WriteCatalog writes the entries and the parents of their groups to w as a
+// catalog in format. Parents of groups without entries or nested groups are
+// left out.
+func WriteCatalog(w io.Writer, format CatalogFormat, entries []Entry, parents map[string]string) error {
+	parents = catalogParents(entries, parents)
+	switch format {
+	case CatalogJSON, CatalogYAML:
+		tree, err := catalogTree(entries, parents)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(tree); err != nil {
+			return fmt.Errorf("cannot serialize catalog: %w", err)
+		}
+		if format == CatalogYAML {
+			y, err := jsonToYAML(buf.Bytes())
+			if err != nil {
+				return fmt.Errorf("cannot serialize catalog: %w", err)
+			}
+			buf.Reset()
+			buf.Write(y)
+		}
+		_, err = w.Write(buf.Bytes())
+		return err
+	case CatalogCSV:
+		return writeCatalogCSV(w, entries, parents)
+	}
+	return fmt.Errorf("unknown catalog format %q", format)
+}
+
+// catalogParents returns the parents of the groups of entries and of their
+// ancestors.
+func catalogParents(entries []Entry, parents map[string]string) map[string]string {
+	result := make(map[string]string)
+	for _, e := range entries {
+		for group := e.Group; parents[group] != "" && result[group] == ""; group = parents[group] {
+			result[group] = parents[group]
+		}
+	}
+	return result
+}
+
+// catalogTree returns the catalog of entries with the JSON structure of the
+// ENTRIES environment variable, with nested groups in their parents.
+func catalogTree(entries []Entry, parents map[string]string) (map[string]map[string]any, error) {
+	groups := make(map[string]map[string]any)
+	group := func(name string) map[string]any {
+		if groups[name] == nil {
+			groups[name] = make(map[string]any)
+		}
+		return groups[name]
+	}
+	for _, e := range entries {
+		group(e.Group)[e.Name] = catalogEntryOf(e)
+	}
+	for child, parent := range parents {
+		group(child)
+		group(parent)
+	}
+
+	tree := make(map[string]map[string]any)
+	for name, items := range groups {
+		parent, ok := parents[name]
+		if !ok {
+			tree[name] = items
+			continue
+		}
+		if _, ok := groups[parent][name]; ok {
+			return nil, fmt.Errorf("group %q has the same name as an entry in %q", name, parent)
+		}
+		groups[parent][name] = items
+	}
+	return tree, nil
+}
+
+// catalogEntryOf returns the catalog configuration of e.
+func catalogEntryOf(e Entry) catalogEntry {
+	cfg := catalogEntry{
+		Place:   e.Place,
+		Cost:    e.Cost,
+		Hours:   e.Hours,
+		Tags:    e.Tags,
+		Notes:   e.Notes,
+		URL:     e.URL,
+		Address: e.Address,
+		Phone:   e.Phone,
+
+		Archived:     e.Archived,
+		SnoozedUntil: e.SnoozedUntil,
+	}
+	if len(e.Open) > 0 {
+		cfg.Open = e.Open
+	}
+	for _, ex := range e.Exceptions {
+		cfg.Exceptions = append(cfg.Exceptions, catalogException(ex))
+	}
+	return cfg
+}
+
+// parseCatalogJSON parses and validates the entries in a catalog with the
+// JSON structure of the ENTRIES environment variable, where groups map the
+// names of their entries and nested groups to their configuration, which is
+// a catalogEntry for entries and a group for nested groups.
+func parseCatalogJSON(name string, data []byte) ([]Entry, map[string]string, error) {
+	var config map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, nil, fmt.Errorf("%s is not valid JSON: %w", name, err)
+	}
+
+	var entries []Entry
+	parents := make(map[string]string)
+	seen := make(map[string]bool)
+	var parseGroup func(group, parent string, items map[string]json.RawMessage) error
+	parseGroup = func(group, parent string, items map[string]json.RawMessage) error {
+		if err := checkCatalogGroup(name, group); err != nil {
+			return err
+		}
+		if seen[group] {
+			return fmt.Errorf("%s: group name %q is used more than once", name, group)
+		}
+		seen[group] = true
+		if parent != "" {
+			parents[group] = parent
+		}
+		for itemName, raw := range items {
+			if !isCatalogEntry(raw) {
+				var subgroup map[string]json.RawMessage
+				if err := json.Unmarshal(raw, &subgroup); err != nil {
+					return fmt.Errorf("%s: group %q is not valid: %w", name, itemName, err)
+				}
+				if err := parseGroup(itemName, group, subgroup); err != nil {
+					return err
+				}
+				continue
+			}
+			var cfg catalogEntry
+			if err := json.Unmarshal(raw, &cfg); err != nil {
+				return fmt.Errorf("%s: entry %q is not valid: %w", name, itemName, err)
+			}
+			entry, err := parseCatalogEntry(name, group, itemName, cfg)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	}
+	for group, items := range config {
+		if err := parseGroup(group, "", items); err != nil {
+			return nil, nil, err
+		}
+	}
+	return entries, parents, nil
+}
+
+// isCatalogEntry reports whether the JSON in raw configures an entry rather
+// than a nested group, which is the case unless it is an object without any
+// of the fields of catalogEntry.
+func isCatalogEntry(raw json.RawMessage) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || len(fields) == 0 {
+		return true
+	}
+	t := reflect.TypeFor[catalogEntry]()
+	for i := range t.NumField() {
+		field, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if _, ok := fields[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCatalogGroup returns an error if group cannot be used as a group name.
+// The name is used to identify the source of the catalog in error messages.
+func checkCatalogGroup(name, group string) error {
+	if group == "" {
+		return fmt.Errorf("%s: a group has no name", name)
+	}
+	if strings.Contains(group, "|") {
+		return fmt.Errorf("%s: group name %q contains invalid character '|'", name, group)
+	}
+	return nil
+}
+
+// parseCatalogEntry returns the entry named entryName in group with the
+// configuration in cfg, cleaning its name and validating it with
+// Entry.Validate like every catalog format does. The name is used to identify
+// the source of the entry in error messages.
+func parseCatalogEntry(name, group, entryName string, cfg catalogEntry) (Entry, error) {
+	var exceptions []Exception
+	for _, cex := range cfg.Exceptions {
+		exceptions = append(exceptions, Exception(cex))
+	}
+	e := Entry{
+		Name:       cleanName(entryName),
+		Group:      group,
+		Place:      cfg.Place,
+		Cost:       cfg.Cost,
+		Open:       cfg.Open,
+		Hours:      cfg.Hours,
+		Exceptions: exceptions,
+		Tags:       cfg.Tags,
+		Details:    Details{Notes: cfg.Notes, URL: cfg.URL, Address: cfg.Address, Phone: cfg.Phone},
+
+		Archived:     cfg.Archived,
+		SnoozedUntil: cfg.SnoozedUntil,
+	}
+	if err := e.Validate(); err != nil {
+		return Entry{}, fmt.Errorf("%s: entry %q %w", name, entryName, err)
+	}
+	e.Tags = e.Tags.Normalize()
+	return e, nil
+}
+
+// importCatalog replaces the shared entries with entries, and the parents of
+// their groups with parents. Private entries are not part of the catalog, so
+// they are kept, and so are the parents of groups that are not in the
+// catalog. It returns an error if an entry has the name of a private entry.
+func (a *App) importCatalog(entries []Entry, parents map[string]string) error {
+	if err := checkDuplicates(entries); err != nil {
+		return err
+	}
+
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries = slices.Clone(entries)
+	for _, e := range a.db.Entries {
+		if e.Owner != "" {
+			entries = append(entries, e)
+		}
+	}
+	if err := checkPrivateNames("", entries); err != nil {
+		return err
+	}
+
+	groups := make(map[string]bool)
+	for _, e := range entries {
+		groups[e.Group] = true
+	}
+	for child, parent := range parents {
+		groups[child], groups[parent] = true, true
+	}
+	parents = catalogParents(entries, parents)
+	for child, parent := range a.db.GroupParents {
+		if !groups[child] {
+			parents[child] = parent
+		}
+	}
+
+	a.db.Entries = entries
+	a.db.GroupParents = validGroupParents(parents)
+	return nil
+}
+
+// catalogFormat returns the catalog format given by the extension of the
+// path of r.
+func catalogFormat(r *http.Request) CatalogFormat {
+	return CatalogFormat(strings.TrimPrefix(path.Ext(r.URL.Path), "."))
+}
+
+// handleCatalogExport serves the catalog of shared entries in the format
+// given by the extension of the path. Private entries are left out, as the
+// catalog has no owners.
+func (a *App) handleCatalogExport(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticate(w, r); !ok {
+		return
+	}
+
+	a.mu.RLock()
+	entries := slices.DeleteFunc(slices.Clone(a.db.Entries), func(e Entry) bool {
+		return e.Owner != ""
+	})
+	parents := a.db.GroupParents
+	a.mu.RUnlock()
+
+	format := catalogFormat(r)
+	var buf bytes.Buffer
+	if err := WriteCatalog(&buf, format, entries, parents); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", catalogContentTypes[format])
+	w.Write(buf.Bytes())
+}
+
+// handleCatalogImport replaces the shared entries with an uploaded catalog in
+// the format given by the extension of the path, as done by importCatalog.
+// Only editors can import.
+func (a *App) handleCatalogImport(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if !a.checkEditor(w, person) {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	entries, parents, err := ParseCatalog(catalogFormat(r), path.Base(r.URL.Path), data)
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.importCatalog(entries, parents); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,584 @@
+package app_test
+
+import (
+	"bytes"
+	"cmp"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+// sortEntries sorts entries by group and name.
+func sortEntries(entries []app.Entry) {
+	slices.SortFunc(entries, func(a, b app.Entry) int {
+		return cmp.Or(cmp.Compare(a.Group, b.Group), cmp.Compare(a.Name, b.Name))
+	})
+}
+
+func TestCatalogRoundTrip(t *testing.T) {
+	parents := map[string]string{"Chinatown": "Downtown", "North": "Chinatown", "Unused": "Downtown"}
+	wantParents := map[string]string{"Chinatown": "Downtown", "North": "Chinatown"}
+
+	// Entries using all the fields of the catalog.
+	entries := []app.Entry{{
+		Name:  "Joe's Pizza",
+		Group: "Downtown",
+		Open:  map[string][]string{"mon": {"lunch", "dinner"}, "hol": {}},
+		Hours: app.Hours{"fri": {"11:30-14:30", "18:00-02:00"}, "hol": {}},
+		Exceptions: []app.Exception{
+			{From: "2024-12-20", To: "2025-01-05"},
+			{From: "2025-04-21", Open: []string{"lunch"}, Hours: []string{"11:00-15:00"}},
+		},
+		Tags:    app.Tags{"delivery", "vegetarian friendly"},
+		Cost:    2,
+		Details: app.Details{Notes: "Burger #1: yes, \"really\"", URL: "https://example.com/menu?a=1#b", Phone: "+1 555 123"},
+	}, {
+		Name:         "Noodle House",
+		Group:        "Chinatown",
+		Place:        "Noodles",
+		Open:         map[string][]string{"tue": {"dinner"}},
+		Cost:         1,
+		Archived:     true,
+		SnoozedUntil: "2025-03-01",
+	}, {
+		Name:    "yes",
+		Group:   "North",
+		Place:   "Noodles",
+		Open:    map[string][]string{"sat": {"lunch"}},
+		Cost:    3,
+		Details: app.Details{Address: "1 Main St"},
+	}}
+	sortEntries(entries)
+
+	for _, format := range []app.CatalogFormat{app.CatalogJSON, app.CatalogCSV, app.CatalogYAML} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := app.WriteCatalog(&buf, format, entries, parents); err != nil {
+				t.Fatal(err)
+			}
+			got, gotParents, err := app.ParseCatalog(format, "test", buf.Bytes())
+			if err != nil {
+				t.Fatalf("ParseCatalog() err = %v, catalog:\n%s", err, buf.String())
+			}
+			sortEntries(got)
+			if !reflect.DeepEqual(got, entries) {
+				t.Errorf("ParseCatalog() = %+v, want %+v", got, entries)
+			}
+			if !reflect.DeepEqual(gotParents, wantParents) {
+				t.Errorf("ParseCatalog() parents = %v, want %v", gotParents, wantParents)
+			}
+		})
+	}
+}
+
+func TestWriteCatalog(t *testing.T) {
+	entries := []app.Entry{{
+		Name:  "Pizza Place",
+		Group: "Downtown",
+		Open:  map[string][]string{"wed": {"lunch"}, "mon": {"lunch", "dinner"}},
+		Hours: app.Hours{"mon": {"11:00-15:00"}, "tue": {"11:00-15:00"}},
+		Tags:  app.Tags{"delivery"},
+		Cost:  2,
+	}, {
+		Name:  "Sushi Bar",
+		Group: "Harbor",
+		Exceptions: []app.Exception{
+			{From: "2025-01-01"},
+		},
+		Cost: 4,
+	}}
+	parents := map[string]string{"Harbor": "Waterfront"}
+
+	var tests = []struct {
+		format app.CatalogFormat
+		want   string
+	}{{
+		format: app.CatalogCSV,
+		want: `group,parent,name,cost,schedule,hours,exceptions,tags,place,notes,url,address,phone,archived,snoozed_until
+Downtown,,Pizza Place,2,"mon:lunch,dinner;wed:lunch","Mo,Tu 11:00-15:00",,delivery,,,,,,false,
+Harbor,Waterfront,Sushi Bar,4,,,2025-01-01=,,,,,,,false,
+`,
+	}, {
+		format: app.CatalogYAML,
+		want: `Downtown:
+  Pizza Place:
+    cost: 2
+    open:
+      mon: [lunch, dinner]
+      wed: [lunch]
+    hours:
+      mon: ["11:00-15:00"]
+      tue: ["11:00-15:00"]
+    tags: [delivery]
+Waterfront:
+  Harbor:
+    Sushi Bar:
+      cost: 4
+      exceptions:
+        - from: "2025-01-01"
+`,
+	}, {
+		format: app.CatalogJSON,
+		want:   `{"Downtown":{"Pizza Place":{"cost":2,"open":{"mon":["lunch","dinner"],"wed":["lunch"]},"hours":{"mon":["11:00-15:00"],"tue":["11:00-15:00"]},"tags":["delivery"]}},"Waterfront":{"Harbor":{"Sushi Bar":{"cost":4,"exceptions":[{"from":"2025-01-01"}]}}}}` + "\n",
+	}}
+
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := app.WriteCatalog(&buf, test.format, entries, parents); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.want {
+				t.Errorf("WriteCatalog() =\n%s\nwant\n%s", buf.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestParseCatalogCSV(t *testing.T) {
+	const header = "group,name,cost,schedule\n"
+	var tests = []struct {
+		desc        string
+		csv         string
+		want        []app.Entry
+		wantParents map[string]string
+		wantErr     string
+	}{{
+		desc: "columns in any order",
+		csv:  "Name, Group ,Cost,Tags\nPizza Place,Downtown,2,\"Delivery, vegan\"\n",
+		want: []app.Entry{{Name: "Pizza Place", Group: "Downtown", Cost: 2, Tags: app.Tags{"delivery", "vegan"}}},
+	}, {
+		desc: "schedule",
+		csv:  header + "Downtown,Pizza Place,1,\"mon:lunch, dinner;hol:\"\n",
+		want: []app.Entry{{
+			Name:  "Pizza Place",
+			Group: "Downtown",
+			Cost:  1,
+			Open:  map[string][]string{"mon": {"lunch", "dinner"}, "hol": {}},
+		}},
+	}, {
+		desc:        "rows without a name",
+		csv:         "group,parent,name,cost\nChinatown,Downtown,,\nNorth,Chinatown,Noodle House,1\n",
+		want:        []app.Entry{{Name: "Noodle House", Group: "North", Cost: 1}},
+		wantParents: map[string]string{"Chinatown": "Downtown", "North": "Chinatown"},
+	}, {
+		desc: "names are cleaned",
+		csv:  header + "Downtown,Pizza   Place ,1,\n",
+		want: []app.Entry{{Name: "Pizza Place", Group: "Downtown", Cost: 1}},
+	}, {
+		desc:    "row without a name but with values",
+		csv:     header + "Downtown,,2,\n",
+		wantErr: `test: line 2 has no name, but has a value for "cost"`,
+	}, {
+		desc:    "names that become duplicates",
+		csv:     header + "Downtown,Pizza Place,2,\nDowntown,Pizza  Place,2,\n",
+		wantErr: "are duplicates",
+	}, {
+		desc:    "unknown column",
+		csv:     "group,name,price\nDowntown,Pizza Place,2\n",
+		wantErr: `test: unknown column "price"`,
+	}, {
+		desc:    "missing name column",
+		csv:     "group,cost\nDowntown,2\n",
+		wantErr: `test: missing column "name"`,
+	}, {
+		desc:    "row without a group",
+		csv:     header + ",Pizza Place,2,\n",
+		wantErr: "test: line 2 has no group",
+	}, {
+		desc:    "wrong number of fields",
+		csv:     header + "Downtown,Pizza Place\n",
+		wantErr: "test is not valid CSV",
+	}, {
+		desc:    "entry name contains pipe",
+		csv:     header + "Downtown,Pizza Place,2,\nDowntown,Pizza|Place,2,\n",
+		wantErr: `test: line 3: entry "Pizza|Place" contains invalid character '|'`,
+	}, {
+		desc:    "group name contains pipe",
+		csv:     header + "Down|town,Pizza Place,2,\n",
+		wantErr: "contains invalid character '|'",
+	}, {
+		desc:    "entry listed twice",
+		csv:     header + "Downtown,Pizza Place,2,\nDowntown,Pizza Place,3,\n",
+		wantErr: `entry "Pizza Place" in group "Downtown" is used more than once`,
+	}, {
+		desc:    "group with two parents",
+		csv:     "group,parent,name,cost\nNorth,Downtown,A,1\nNorth,Uptown,B,1\n",
+		wantErr: `group "North" has more than one parent`,
+	}, {
+		desc:    "invalid cost",
+		csv:     header + "Downtown,Pizza Place,cheap,\n",
+		wantErr: `entry "Pizza Place" has an invalid cost "cheap"`,
+	}, {
+		desc:    "cost out of range",
+		csv:     header + "Downtown,Pizza Place,5,\n",
+		wantErr: `test: line 2: entry "Pizza Place" has an invalid cost 5`,
+	}, {
+		desc:    "invalid schedule",
+		csv:     header + "Downtown,Pizza Place,2,lunch\n",
+		wantErr: `entry "Pizza Place" has an invalid schedule "lunch"`,
+	}, {
+		desc:    "invalid hours",
+		csv:     "group,name,hours\nDowntown,Pizza Place,Mo 25:00-26:00\n",
+		wantErr: `entry "Pizza Place" has invalid hours`,
+	}, {
+		desc:    "invalid exception",
+		csv:     "group,name,cost,exceptions\nDowntown,Pizza Place,1,2025-02-01/2025-01-01=\n",
+		wantErr: `entry "Pizza Place" has an invalid exception`,
+	}, {
+		desc:    "invalid URL",
+		csv:     "group,name,cost,url\nDowntown,Pizza Place,1,javascript:alert(1)\n",
+		wantErr: `entry "Pizza Place" has invalid details`,
+	}, {
+		desc:    "invalid archived value",
+		csv:     "group,name,archived\nDowntown,Pizza Place,maybe\n",
+		wantErr: `entry "Pizza Place" has an invalid archived value "maybe"`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, parents, err := app.ParseCatalog(app.CatalogCSV, "test", []byte(test.csv))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ParseCatalog() err = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseCatalog() = %+v, want %+v", got, test.want)
+			}
+			if test.wantParents == nil {
+				test.wantParents = map[string]string{}
+			}
+			if !reflect.DeepEqual(parents, test.wantParents) {
+				t.Errorf("ParseCatalog() parents = %v, want %v", parents, test.wantParents)
+			}
+		})
+	}
+}
+
+func TestParseCatalogYAML(t *testing.T) {
+	var tests = []struct {
+		desc        string
+		yaml        string
+		want        []app.Entry
+		wantParents map[string]string
+		wantErr     string
+	}{{
+		desc: "block and flow collections",
+		yaml: `# The catalog.
+Downtown:
+  Pizza Place:   # Best in town.
+    cost: 2
+    open:
+      mon: [lunch, dinner]
+      "tue": ["lunch"]
+    tags:
+      - delivery
+      -   Vegan
+    exceptions:
+      - from: 2025-01-01
+      -   from: "2025-02-01"
+          hours: ["11:00-15:00"]
+    notes: "It's #1"
+    url: null
+  Burger Joint:
+    cost: 1
+    open: {}
+`,
+		want: []app.Entry{{
+			Name:  "Burger Joint",
+			Group: "Downtown",
+			Cost:  1,
+			Open:  map[string][]string{},
+		}, {
+			Name:  "Pizza Place",
+			Group: "Downtown",
+			Cost:  2,
+			Open:  map[string][]string{"mon": {"lunch", "dinner"}, "tue": {"lunch"}},
+			Tags:  app.Tags{"delivery", "vegan"},
+			Exceptions: []app.Exception{
+				{From: "2025-01-01"},
+				{From: "2025-02-01", Hours: []string{"11:00-15:00"}},
+			},
+			Details: app.Details{Notes: "It's #1"},
+		}},
+	}, {
+		desc: "nested groups",
+		yaml: `Downtown:
+  "Chinatown":
+    Noodle  House:
+      cost: 1
+    North:
+      Rock 'n Roll Diner:
+        cost: 3
+        archived: true
+`,
+		want: []app.Entry{
+			{Name: "Noodle House", Group: "Chinatown", Cost: 1},
+			{Name: "Rock 'n Roll Diner", Group: "North", Cost: 3, Archived: true},
+		},
+		wantParents: map[string]string{"Chinatown": "Downtown", "North": "Chinatown"},
+	}, {
+		desc:        "empty",
+		yaml:        "# Nothing yet.\n",
+		wantParents: map[string]string{},
+	}, {
+		desc:    "entry name contains pipe",
+		yaml:    "Downtown:\n  Pizza|Place:\n    cost: 1\n",
+		wantErr: `test: entry "Pizza|Place" contains invalid character '|'`,
+	}, {
+		desc:    "empty entry name",
+		yaml:    "Downtown:\n  \" \":\n    cost: 1\n",
+		wantErr: `test: entry " " has no name`,
+	}, {
+		desc:    "empty group name",
+		yaml:    "\"\":\n  Pizza Place:\n    cost: 1\n",
+		wantErr: "test: a group has no name",
+	}, {
+		desc:    "missing cost",
+		yaml:    "Downtown:\n  Pizza Place:\n    open:\n      mon: [lunch]\n",
+		wantErr: `test: entry "Pizza Place" has an invalid cost 0`,
+	}, {
+		desc:    "invalid entry",
+		yaml:    "Downtown:\n  Pizza Place:\n    cost: cheap\n",
+		wantErr: `entry "Pizza Place" is not valid`,
+	}, {
+		desc:    "invalid hours",
+		yaml:    "Downtown:\n  Pizza Place:\n    cost: 1\n    hours:\n      fri: [\"11:30-25:00\"]\n",
+		wantErr: `entry "Pizza Place" has invalid hours`,
+	}, {
+		desc:    "unexpected indentation",
+		yaml:    "Downtown:\n  Pizza Place:\n      cost: 1\n    open: {}\n",
+		wantErr: "test is not valid YAML: line 4: unexpected indentation",
+	}, {
+		desc:    "duplicate key",
+		yaml:    "Downtown:\n  Pizza Place:\n    cost: 1\n  Pizza Place:\n    cost: 2\n",
+		wantErr: `line 4: key "Pizza Place" is used more than once`,
+	}, {
+		desc:    "missing key",
+		yaml:    "Downtown:\n  Pizza Place\n",
+		wantErr: `line 2: expected "key: value"`,
+	}, {
+		desc:    "sequence at the indentation of its key",
+		yaml:    "Downtown:\n  Pizza Place:\n    cost: 1\n    tags:\n    - vegan\n",
+		wantErr: `line 5: expected "key: value"`,
+	}, {
+		desc:    "empty sequence item",
+		yaml:    "Downtown:\n  Pizza Place:\n    cost: 1\n    tags:\n      -\n        vegan\n",
+		wantErr: `line 5: expected a value after "-"`,
+	}, {
+		desc:    "unterminated flow sequence",
+		yaml:    "Downtown:\n  Pizza Place:\n    tags: [a, b\n",
+		wantErr: `line 3: expected "]"`,
+	}, {
+		desc:    "nested flow sequence",
+		yaml:    "Downtown:\n  Pizza Place:\n    tags: [a, [b]]\n",
+		wantErr: "line 3: unsupported YAML syntax",
+	}, {
+		desc:    "flow mapping",
+		yaml:    "Downtown:\n  Pizza Place: {cost: 1}\n",
+		wantErr: "line 2: unsupported YAML syntax",
+	}, {
+		desc:    "single-quoted scalar",
+		yaml:    "Downtown:\n  Pizza Place:\n    notes: 'Cash only'\n",
+		wantErr: "line 3: unsupported YAML syntax",
+	}, {
+		desc:    "unterminated quoted scalar",
+		yaml:    "Downtown:\n  Pizza Place:\n    notes: \"Cash only\n",
+		wantErr: "line 3: unterminated quoted scalar",
+	}, {
+		desc:    "text after quoted scalar",
+		yaml:    "Downtown:\n  Pizza Place:\n    notes: \"Cash\" only\n",
+		wantErr: `line 3: unexpected " only"`,
+	}, {
+		desc:    "mapping in plain scalar",
+		yaml:    "Downtown:\n  Pizza Place:\n    notes: Cash: only\n",
+		wantErr: "line 3: unsupported YAML syntax",
+	}, {
+		desc:    "anchors",
+		yaml:    "Downtown:\n  Pizza Place: &pizza\n    cost: 1\n",
+		wantErr: "unsupported YAML syntax",
+	}, {
+		desc:    "block scalars",
+		yaml:    "Downtown:\n  Pizza Place:\n    notes: |\n      Cash only\n",
+		wantErr: "unsupported YAML syntax",
+	}, {
+		desc:    "tabs",
+		yaml:    "Downtown:\n\tPizza Place:\n    cost: 1\n",
+		wantErr: "line 2: tabs cannot be used for indentation",
+	}, {
+		desc:    "document markers",
+		yaml:    "---\nDowntown: {}\n",
+		wantErr: "line 1: document markers and directives are not supported",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, parents, err := app.ParseCatalog(app.CatalogYAML, "test", []byte(test.yaml))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ParseCatalog() err = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortEntries(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseCatalog() = %+v, want %+v", got, test.want)
+			}
+			if test.wantParents == nil {
+				test.wantParents = map[string]string{}
+			}
+			if !reflect.DeepEqual(parents, test.wantParents) {
+				t.Errorf("ParseCatalog() parents = %v, want %v", parents, test.wantParents)
+			}
+		})
+	}
+}
+
+func TestCatalogExport(t *testing.T) {
+	var tests = []struct {
+		path            string
+		wantContentType string
+		wantBody        []string
+	}{{
+		path:            "/entries.csv?token=tokenA",
+		wantContentType: "text/csv; charset=utf-8",
+		wantBody:        []string{"group,parent,name,cost,schedule", `Downtown,,Pizza Place,2,"mon:lunch,dinner;tue:lunch;wed:lunch,dinner"`},
+	}, {
+		path:            "/entries.yaml?token=tokenB",
+		wantContentType: "application/yaml; charset=utf-8",
+		wantBody:        []string{"Downtown:\n  Burger Joint:\n    cost: 1\n", "Uptown:\n"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			a := newTestApp(t, append(testEntries(), app.Entry{Name: "Home Pasta", Group: "Downtown", Owner: "alice", Open: map[string][]string{"mon": {"lunch"}}, Cost: 1})...)
+			req := httptest.NewRequest("GET", test.path, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != test.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, test.wantContentType)
+			}
+			for _, want := range test.wantBody {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body does not contain %q:\n%s", want, w.Body.String())
+				}
+			}
+			// Private entries are not part of the catalog, even for their
+			// owner.
+			if strings.Contains(w.Body.String(), "Home Pasta") {
+				t.Errorf("body has a private entry:\n%s", w.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/entries.csv", nil)
+	w := httptest.NewRecorder()
+	newTestApp(t).ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status without token = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestCatalogImport(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantNames  []string
+	}{{
+		desc:       "csv",
+		path:       "/entries.csv?token=tokenA",
+		body:       "group,parent,name,cost,schedule\nDowntown,,Pizza Place,3,mon:lunch\nHarbor,Waterfront,Fish Shack,2,fri:dinner\n",
+		wantStatus: http.StatusNoContent,
+		wantNames:  []string{"Fish Shack", "Home Pasta", "Pizza Place"},
+	}, {
+		desc:       "yaml",
+		path:       "/entries.yaml?token=tokenA",
+		body:       "Downtown:\n  Pizza Place:\n    cost: 3\n    open:\n      mon: [lunch]\nWaterfront:\n  Harbor:\n    Fish Shack:\n      cost: 2\n",
+		wantStatus: http.StatusNoContent,
+		wantNames:  []string{"Fish Shack", "Home Pasta", "Pizza Place"},
+	}, {
+		desc:       "invalid entry",
+		path:       "/entries.yaml?token=tokenA",
+		body:       "Downtown:\n  Pizza|Place:\n    cost: 3\n",
+		wantStatus: http.StatusBadRequest,
+		wantBody:   `entries.yaml: entry "Pizza|Place" contains invalid character '|'`,
+		wantNames:  []string{"Burger Joint", "Home Pasta", "Pizza Place", "Sushi Bar", "Taco Stand"},
+	}, {
+		desc:       "duplicates",
+		path:       "/entries.csv?token=tokenA",
+		body:       "group,name,cost\nDowntown,Pizza Place,1\nDowntown,pizza place!,1\n",
+		wantStatus: http.StatusBadRequest,
+		wantBody:   "are duplicates",
+		wantNames:  []string{"Burger Joint", "Home Pasta", "Pizza Place", "Sushi Bar", "Taco Stand"},
+	}, {
+		desc:       "private name",
+		path:       "/entries.csv?token=tokenA",
+		body:       "group,name,cost\nDowntown,Pizza Place,1\nDowntown,Home Pasta,2\n",
+		wantStatus: http.StatusBadRequest,
+		wantBody:   `"Home Pasta" in "Downtown" has the name of a private entry`,
+		wantNames:  []string{"Burger Joint", "Home Pasta", "Pizza Place", "Sushi Bar", "Taco Stand"},
+	}, {
+		desc:       "too large",
+		path:       "/entries.csv?token=tokenA",
+		body:       "group,name,cost\n" + strings.Repeat("Downtown,Pizza Place,1\n", 1<<19),
+		wantStatus: http.StatusBadRequest,
+		wantNames:  []string{"Burger Joint", "Home Pasta", "Pizza Place", "Sushi Bar", "Taco Stand"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t, append(testEntries(), app.Entry{Name: "Home Pasta", Group: "Downtown", Owner: "alice", Open: map[string][]string{"mon": {"lunch"}}, Cost: 1})...)
+			a.UpdateVotes("bob", map[string]string{"Downtown|Pizza Place": "yes", "Uptown|Sushi Bar": "no"})
+
+			req := httptest.NewRequest("POST", test.path, strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), test.wantBody) {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.wantBody)
+			}
+
+			var names []string
+			for _, e := range a.Entries() {
+				names = append(names, e.Name)
+			}
+			slices.Sort(names)
+			if !reflect.DeepEqual(names, test.wantNames) {
+				t.Errorf("entries = %v, want %v", names, test.wantNames)
+			}
+			if test.wantStatus != http.StatusNoContent {
+				return
+			}
+			if e, _ := findEntry(a.Entries(), "Downtown", "Home Pasta"); e.Owner != "alice" {
+				t.Errorf("private entry owner = %q, want %q", e.Owner, "alice")
+			}
+			if want := map[string]string{"Harbor": "Waterfront"}; !reflect.DeepEqual(a.GroupParents(), want) {
+				t.Errorf("group parents = %v, want %v", a.GroupParents(), want)
+			}
+			if v := a.Votes()["bob"]["Downtown"]["Pizza Place"]; v != "yes" {
+				t.Errorf("vote for a kept entry = %q, want %q", v, "yes")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"time"
+)
+
+// Clock tells the current time. The App reads the time only through its
+// Clock, so that tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now implements Clock.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// wallTime returns the first instant at which the wall clock in loc shows
+// the given minutes after midnight on the date given by year, month and day.
+// Minutes past the end of the day refer to the following days. If the wall
+// clock skips that time in a daylight saving transition, it returns the
+// instant of the transition, and if the wall clock shows that time twice, it
+// returns the first one. Unlike time.Date, which does not guarantee which
+// instant is used in these cases, the result is the same for every timezone.
+func wallTime(loc *time.Location, year int, month time.Month, day, minute int) time.Time {
+	target := time.Date(year, month, day, 0, minute, 0, 0, time.UTC)
+	wall := func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	}
+
+	// Transitions can only happen between the offsets in effect a while
+	// before and after the target, so the instant is one of these.
+	_, before := target.Add(-36 * time.Hour).In(loc).Zone()
+	_, after := target.Add(36 * time.Hour).In(loc).Zone()
+	first := target.Add(-time.Duration(max(before, after)) * time.Second)
+	last := target.Add(-time.Duration(min(before, after)) * time.Second)
+	for _, t := range []time.Time{first, last} {
+		if wall(t).Equal(target) {
+			return t.In(loc)
+		}
+	}
+
+	// The wall clock skips the target: search for the transition, which is
+	// the first instant showing a later time.
+	for last.Sub(first) > time.Second {
+		mid := first.Add(last.Sub(first) / 2)
+		if wall(mid).Before(target) {
+			first = mid
+		} else {
+			last = mid
+		}
+	}
+	return last.In(loc)
+}
+
+// now returns the current time in the App's timezone.
+func (a *App) now() time.Time {
+	return a.clock.Now().In(a.timezone)
+}
+
+// date returns the start of a date in the App's timezone, which is midnight
+// unless the wall clock skips it in a daylight saving transition. Days past
+// the end of the month refer to the following months.
+func (a *App) date(year int, month time.Month, day int) time.Time {
+	return wallTime(a.timezone, year, month, day, 0)
+}
+
+// dateOf returns the start of the date of t in the App's timezone.
+func (a *App) dateOf(t time.Time) time.Time {
+	year, month, day := t.In(a.timezone).Date()
+	return a.date(year, month, day)
+}
+
+// addDays returns the start of the date days after date. Unlike
+// time.Time.AddDate, the result is always on the expected date, even if the
+// wall clock skips midnight on it.
+func (a *App) addDays(date time.Time, days int) time.Time {
+	year, month, day := date.In(a.timezone).Date()
+	return a.date(year, month, day+days)
+}
+
+// parseDate parses a date in "2006-01-02" format, returning its start in the
+// App's timezone.
+func (a *App) parseDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return a.date(t.Date()), nil
+}
+
+// today returns the start of the current date.
+func (a *App) today() time.Time {
+	return a.dateOf(a.clock.Now())
+}
+
+// periodInstants returns the instants at which period starts and ends when
+// it starts on date. Periods last longer or shorter than their hours suggest
+// on the days of daylight saving transitions.
+func (a *App) periodInstants(periods Periods, date time.Time, period string) (time.Time, time.Time) {
+	start, end := periodWindow(periods[period])
+	year, month, day := date.In(a.timezone).Date()
+	return wallTime(a.timezone, year, month, day, start), wallTime(a.timezone, year, month, day, end)
+}
+
+// currentPeriod returns the period in effect at the current time and the date
+// on which it started, which is the day before today past midnight in periods
+// that wrap around it. It returns an empty period between periods.
+func (a *App) currentPeriod() (string, time.Time) {
+	periods, periodList := a.periodConfig()
+	now := a.clock.Now()
+	today := a.today()
+	for _, date := range []time.Time{a.addDays(today, -1), today} {
+		for _, period := range periodList {
+			start, end := a.periodInstants(periods, date, period)
+			if !now.Before(start) && now.Before(end) {
+				return period, date
+			}
+		}
+	}
+	return "", time.Time{}
+}
+
+// nextPeriodStart returns the date of the next start of period after the
+// current time.
+func (a *App) nextPeriodStart(periods Periods, period string) time.Time {
+	now := a.clock.Now()
+	date := a.today()
+	for {
+		// Periods entirely skipped by a daylight saving transition do not
+		// start at all on that date.
+		if start, end := a.periodInstants(periods, date, period); start.After(now) && end.After(start) {
+			return date
+		}
+		date = a.addDays(date, 1)
+	}
+}
+
+// upcomingPeriod returns the next period to start after the current time and
+// the date on which it starts. It returns an empty period if there are no
+// periods.
+func (a *App) upcomingPeriod() (string, time.Time) {
+	periods, periodList := a.periodConfig()
+	var next string
+	var nextDate, nextStart time.Time
+	for _, period := range periodList {
+		date := a.nextPeriodStart(periods, period)
+		start, _ := a.periodInstants(periods, date, period)
+		if next == "" || start.Before(nextStart) {
+			next, nextDate, nextStart = period, date, start
+		}
+	}
+	return next, nextDate
+}
+
+// periodTallyDate returns the date for displaying the tally of period: the
+// date on which it started if it is the current period, or the date of its
+// next start otherwise.
+func (a *App) periodTallyDate(period string) time.Time {
+	if current, date := a.currentPeriod(); current == period {
+		return date
+	}
+	periods, _ := a.periodConfig()
+	return a.nextPeriodStart(periods, period)
+}
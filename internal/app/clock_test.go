@@ -0,0 +1,295 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestWallTime(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		zone   string
+		year   int
+		month  time.Month
+		day    int
+		minute int
+		want   time.Time
+	}{{
+		desc:   "regular time",
+		zone:   "America/New_York",
+		year:   2024,
+		month:  time.January,
+		day:    1,
+		minute: 12 * 60,
+		want:   time.Date(2024, 1, 1, 17, 0, 0, 0, time.UTC),
+	}, {
+		desc:   "minutes past the end of the day",
+		zone:   "UTC",
+		year:   2024,
+		month:  time.December,
+		day:    31,
+		minute: 25 * 60,
+		want:   time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC),
+	}, {
+		desc:   "spring forward in New York skips to the transition",
+		zone:   "America/New_York",
+		year:   2024,
+		month:  time.March,
+		day:    10,
+		minute: 2*60 + 30,
+		want:   time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC),
+	}, {
+		desc:   "fall back in New York uses the first occurrence",
+		zone:   "America/New_York",
+		year:   2024,
+		month:  time.November,
+		day:    3,
+		minute: 1*60 + 30,
+		want:   time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC),
+	}, {
+		desc:   "spring forward in Berlin skips to the transition",
+		zone:   "Europe/Berlin",
+		year:   2024,
+		month:  time.March,
+		day:    31,
+		minute: 2*60 + 30,
+		want:   time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC),
+	}, {
+		desc:   "fall back in Berlin uses the first occurrence",
+		zone:   "Europe/Berlin",
+		year:   2024,
+		month:  time.October,
+		day:    27,
+		minute: 2*60 + 30,
+		want:   time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC),
+	}, {
+		desc:   "skipped midnight in Sao Paulo",
+		zone:   "America/Sao_Paulo",
+		year:   2018,
+		month:  time.November,
+		day:    4,
+		minute: 0,
+		want:   time.Date(2018, 11, 4, 3, 0, 0, 0, time.UTC),
+	}, {
+		desc:   "repeated hour before midnight in Sao Paulo",
+		zone:   "America/Sao_Paulo",
+		year:   2019,
+		month:  time.February,
+		day:    16,
+		minute: 23 * 60,
+		want:   time.Date(2019, 2, 17, 1, 0, 0, 0, time.UTC),
+	}, {
+		desc:   "half hour spring forward in Lord Howe",
+		zone:   "Australia/Lord_Howe",
+		year:   2024,
+		month:  time.October,
+		day:    6,
+		minute: 2*60 + 15,
+		want:   time.Date(2024, 10, 5, 15, 30, 0, 0, time.UTC),
+	}, {
+		desc:   "half hour fall back in Lord Howe",
+		zone:   "Australia/Lord_Howe",
+		year:   2024,
+		month:  time.April,
+		day:    7,
+		minute: 1*60 + 45,
+		want:   time.Date(2024, 4, 6, 14, 45, 0, 0, time.UTC),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			loc := loadLocation(t, test.zone)
+			got := app.WallTime(loc, test.year, test.month, test.day, test.minute)
+			if !got.Equal(test.want) || got.Location() != loc {
+				t.Errorf("WallTime(%s, %d-%02d-%02d, %d) = %v, want %v in %s",
+					test.zone, test.year, test.month, test.day, test.minute, got, test.want.In(loc), test.zone)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	var tests = []struct {
+		desc string
+		zone string
+		date string
+		want time.Time
+	}{{
+		desc: "midnight",
+		zone: "America/Sao_Paulo",
+		date: "2024-01-01",
+		want: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC),
+	}, {
+		desc: "skipped midnight starts at the transition",
+		zone: "America/Sao_Paulo",
+		date: "2018-11-04",
+		want: time.Date(2018, 11, 4, 3, 0, 0, 0, time.UTC),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a, err := app.New(app.Params{
+				People:   testPeople(),
+				Timezone: loadLocation(t, test.zone),
+				Periods:  testPeriods(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := a.ParseDate(test.date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(test.want) || got.Format(time.DateOnly) != test.date {
+				t.Errorf("ParseDate(%q) = %v, want %v", test.date, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCurrentPeriodDST(t *testing.T) {
+	periods := app.Periods{
+		"breakfast": {0, 2},
+		"night":     {2, 3},
+		"morning":   {3, 12},
+		"dinner":    {18, 0},
+	}
+
+	var tests = []struct {
+		desc       string
+		zone       string
+		now        time.Time
+		wantPeriod string
+		wantDate   string
+	}{{
+		desc:       "skipped period in New York is never current",
+		zone:       "America/New_York",
+		now:        time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), // 03:00 EDT.
+		wantPeriod: "morning",
+		wantDate:   "2024-03-10",
+	}, {
+		desc:       "first occurrence of a repeated hour in New York",
+		zone:       "America/New_York",
+		now:        time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), // 01:30 EDT.
+		wantPeriod: "breakfast",
+		wantDate:   "2024-11-03",
+	}, {
+		desc:       "second occurrence of a repeated hour in New York",
+		zone:       "America/New_York",
+		now:        time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC), // 01:30 EST.
+		wantPeriod: "breakfast",
+		wantDate:   "2024-11-03",
+	}, {
+		desc:       "period shortened by spring forward in Berlin",
+		zone:       "Europe/Berlin",
+		now:        time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC), // 01:30 CET.
+		wantPeriod: "breakfast",
+		wantDate:   "2024-03-31",
+	}, {
+		desc:       "period lengthened by fall back in Berlin",
+		zone:       "Europe/Berlin",
+		now:        time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC), // 02:30 CET.
+		wantPeriod: "night",
+		wantDate:   "2024-10-27",
+	}, {
+		desc:       "skipped midnight in Sao Paulo",
+		zone:       "America/Sao_Paulo",
+		now:        time.Date(2018, 11, 4, 3, 30, 0, 0, time.UTC), // 01:30 -02.
+		wantPeriod: "breakfast",
+		wantDate:   "2018-11-04",
+	}, {
+		desc:       "repeated hour before midnight in Sao Paulo",
+		zone:       "America/Sao_Paulo",
+		now:        time.Date(2019, 2, 17, 2, 30, 0, 0, time.UTC), // 23:30 -03.
+		wantPeriod: "dinner",
+		wantDate:   "2019-02-16",
+	}, {
+		desc:       "half hour skipped in Lord Howe",
+		zone:       "Australia/Lord_Howe",
+		now:        time.Date(2024, 10, 5, 15, 30, 0, 0, time.UTC), // 02:30 +11.
+		wantPeriod: "night",
+		wantDate:   "2024-10-06",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a, err := app.New(app.Params{
+				People:   testPeople(),
+				Timezone: loadLocation(t, test.zone),
+				Periods:  periods,
+				Clock:    app.ClockFunc(func() time.Time { return test.now }),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			period, date := a.CurrentPeriod()
+			if period != test.wantPeriod || date.Format(time.DateOnly) != test.wantDate {
+				t.Errorf("CurrentPeriod() at %v = (%q, %s), want (%q, %s)",
+					test.now, period, date.Format(time.DateOnly), test.wantPeriod, test.wantDate)
+			}
+		})
+	}
+}
+
+func TestUpcomingPeriodDST(t *testing.T) {
+	periods := app.Periods{
+		"night":   {2, 3},
+		"morning": {8, 12},
+	}
+
+	var tests = []struct {
+		desc       string
+		zone       string
+		now        time.Time
+		wantPeriod string
+		wantDate   string
+	}{{
+		desc:       "period after a repeated hour in New York",
+		zone:       "America/New_York",
+		now:        time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC), // 01:00 EDT.
+		wantPeriod: "night",
+		wantDate:   "2024-11-03",
+	}, {
+		desc:       "period skipped in Berlin does not start",
+		zone:       "Europe/Berlin",
+		now:        time.Date(2024, 3, 30, 23, 30, 0, 0, time.UTC), // 00:30 CET.
+		wantPeriod: "morning",
+		wantDate:   "2024-03-31",
+	}, {
+		desc:       "period on the day after a skipped midnight in Sao Paulo",
+		zone:       "America/Sao_Paulo",
+		now:        time.Date(2018, 11, 3, 23, 0, 0, 0, time.UTC), // 20:00 -03.
+		wantPeriod: "night",
+		wantDate:   "2018-11-04",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a, err := app.New(app.Params{
+				People:   testPeople(),
+				Timezone: loadLocation(t, test.zone),
+				Periods:  periods,
+				Clock:    app.ClockFunc(func() time.Time { return test.now }),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			period, date := a.UpcomingPeriod()
+			if period != test.wantPeriod || date.Format(time.DateOnly) != test.wantDate {
+				t.Errorf("UpcomingPeriod() at %v = (%q, %s), want (%q, %s)",
+					test.now, period, date.Format(time.DateOnly), test.wantPeriod, test.wantDate)
+			}
+		})
+	}
+}
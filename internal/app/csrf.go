@@ -0,0 +1,55 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+)
+
+// csrfField is the name of the form field carrying the CSRF token.
+const csrfField = "_csrf"
+
+// newSigningKey returns a random key for signing CSRF tokens or cookies.
+func newSigningKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// csrfToken returns the token that forms posted by person with the
+// credentials of r must carry. It is bound to the person, the space the App
+// serves and, for requests authenticated with a session cookie, the session,
+// so that it stops working when the session ends.
+//
+// Requests authenticated with a token in the URL get the same token for as
+// long as the CSRF key does not change. This is safe because a forged request
+// would need the secret URL token anyway, as browsers do not send it on their
+// own like they do with cookies.
+func (a *App) csrfToken(r *http.Request, person string) string {
+	var session string
+	if r.URL.Query().Get("token") == "" {
+		_, session, _ = a.session(r)
+	}
+	return a.signCSRF(person, session)
+}
+
+// signCSRF returns the CSRF token for person in the session with the given ID,
+// which is empty for requests authenticated with a token.
+func (a *App) signCSRF(person, session string) string {
+	mac := hmac.New(sha256.New, a.csrfKey)
+	mac.Write([]byte(a.basePath + "|" + person + "|" + session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkCSRF verifies that the parsed form of r carries the CSRF token for
+// person. If it does not, an error response is written and false is returned.
+func (a *App) checkCSRF(w http.ResponseWriter, r *http.Request, person string) bool {
+	got := r.PostForm.Get(csrfField)
+	if !hmac.Equal([]byte(got), []byte(a.csrfToken(r, person))) {
+		http.Error(w, "Forbidden: invalid or expired form, please reload the page", http.StatusForbidden)
+		return false
+	}
+	return true
+}
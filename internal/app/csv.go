@@ -0,0 +1,271 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// catalogColumns are the columns of a catalog in CSV, in the order they are
+// written.
+var catalogColumns = []string{
+	"group", "parent", "name", "cost", "schedule", "hours", "exceptions",
+	"tags", "place", "notes", "url", "address", "phone", "archived",
+	"snoozed_until",
+}
+
+// parseCatalogCSV parses and validates the entries in a catalog in CSV. The
+// first row names the columns, which may be any of catalogColumns in any
+// order, and must include "group" and "name". Each other row has an entry,
+// with:
+//   - "schedule" listing the open periods as "day:period,period" pairs
+//     separated by ";" (e.g., "mon:lunch,dinner;sat:lunch");
+//   - "hours" in the opening_hours syntax (e.g., "Mo-Fr 11:30-14:30");
+//   - "exceptions" listing exceptions as "from/to=spec" separated by ";",
+//     where spec lists periods and opening intervals as in the edit page
+//     (e.g., "2024-12-24/2024-12-26=dinner;2025-01-01=");
+//   - "tags" separated by ",";
+//   - "archived" as "true" or "false".
+//
+// The "parent" column sets the parent of the group of the row. Rows without
+// a name only set the parent of their group, and must not have other values.
+// Errors name the line of the row.
+func parseCatalogCSV(name string, data []byte) ([]Entry, map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not valid CSV: %w", name, err)
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(catalogColumns, column) {
+			return nil, nil, fmt.Errorf("%s: unknown column %q", name, column)
+		}
+		if _, ok := columns[column]; ok {
+			return nil, nil, fmt.Errorf("%s: column %q is used more than once", name, column)
+		}
+		columns[column] = i
+	}
+	for _, column := range []string{"group", "name"} {
+		if _, ok := columns[column]; !ok {
+			return nil, nil, fmt.Errorf("%s: missing column %q", name, column)
+		}
+	}
+
+	var entries []Entry
+	parents := make(map[string]string)
+	seen := make(map[[2]string]bool)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("%s is not valid CSV: %w", name, err)
+		}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		line, _ := r.FieldPos(0)
+		lineName := fmt.Sprintf("%s: line %d", name, line)
+
+		group := get("group")
+		if group == "" {
+			return nil, nil, fmt.Errorf("%s has no group", lineName)
+		}
+		if err := checkCatalogGroup(lineName, group); err != nil {
+			return nil, nil, err
+		}
+		if parent := get("parent"); parent != "" {
+			if err := checkCatalogGroup(lineName, parent); err != nil {
+				return nil, nil, err
+			}
+			if p, ok := parents[group]; ok && p != parent {
+				return nil, nil, fmt.Errorf("%s: group %q has more than one parent", lineName, group)
+			}
+			parents[group] = parent
+		}
+
+		entryName := get("name")
+		if entryName == "" {
+			for _, column := range catalogColumns {
+				if column != "group" && column != "parent" && get(column) != "" {
+					return nil, nil, fmt.Errorf("%s has no name, but has a value for %q", lineName, column)
+				}
+			}
+			continue
+		}
+		if seen[[2]string{group, entryName}] {
+			return nil, nil, fmt.Errorf("%s: entry %q in group %q is used more than once", lineName, entryName, group)
+		}
+		seen[[2]string{group, entryName}] = true
+		cfg, err := catalogEntryFromCSV(get)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: entry %q %w", lineName, entryName, err)
+		}
+		entry, err := parseCatalogEntry(lineName, group, entryName, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, parents, nil
+}
+
+// catalogEntryFromCSV returns the configuration of an entry from the values
+// of its row in a catalog in CSV, which get returns by column. Only the
+// syntax of the values is checked here, and the configuration is validated
+// by parseCatalogEntry. Errors are phrased to follow the name of the entry.
+func catalogEntryFromCSV(get func(column string) string) (catalogEntry, error) {
+	cfg := catalogEntry{
+		Place:        get("place"),
+		Notes:        get("notes"),
+		URL:          get("url"),
+		Address:      get("address"),
+		Phone:        get("phone"),
+		SnoozedUntil: get("snoozed_until"),
+	}
+	if s := get("cost"); s != "" {
+		cost, err := strconv.Atoi(s)
+		if err != nil {
+			return catalogEntry{}, fmt.Errorf("has an invalid cost %q", s)
+		}
+		cfg.Cost = cost
+	}
+	if s := get("schedule"); s != "" {
+		cfg.Open = make(map[string][]string)
+		for _, part := range strings.Split(s, ";") {
+			day, periods, ok := strings.Cut(part, ":")
+			day = strings.TrimSpace(day)
+			if !ok || day == "" {
+				return catalogEntry{}, fmt.Errorf("has an invalid schedule %q", s)
+			}
+			// Days without periods are kept, as "hol:" closes the
+			// entry on holidays.
+			open := []string{}
+			for _, period := range strings.Split(periods, ",") {
+				if period = strings.TrimSpace(period); period != "" {
+					open = append(open, period)
+				}
+			}
+			cfg.Open[day] = open
+		}
+	}
+	if s := get("hours"); s != "" {
+		hours, err := parseOpeningHours(s)
+		if err != nil {
+			return catalogEntry{}, fmt.Errorf("has invalid hours: %w", err)
+		}
+		cfg.Hours = hours
+	}
+	if s := get("exceptions"); s != "" {
+		for _, part := range strings.Split(s, ";") {
+			// Invalid exceptions are reported by parseCatalogEntry.
+			ex, _ := parseException(strings.ReplaceAll(part, " ", ""))
+			cfg.Exceptions = append(cfg.Exceptions, catalogException(ex))
+		}
+	}
+	if s := get("tags"); s != "" {
+		cfg.Tags = strings.Split(s, ",")
+	}
+	if s := get("archived"); s != "" {
+		archived, err := strconv.ParseBool(s)
+		if err != nil {
+			return catalogEntry{}, fmt.Errorf("has an invalid archived value %q", s)
+		}
+		cfg.Archived = archived
+	}
+	return cfg, nil
+}
+
+// writeCatalogCSV writes the entries and the parents of their groups to w as
+// a catalog in CSV, as described in parseCatalogCSV. Groups with a parent but
+// without entries get a row without a name.
+func writeCatalogCSV(w io.Writer, entries []Entry, parents map[string]string) error {
+	entries = slices.Clone(entries)
+	for _, group := range slices.Sorted(maps.Keys(parents)) {
+		if !slices.ContainsFunc(entries, func(e Entry) bool { return e.Group == group }) {
+			entries = append(entries, Entry{Group: group})
+		}
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Or(cmp.Compare(a.Group, b.Group), cmp.Compare(a.Name, b.Name))
+	})
+
+	cw := csv.NewWriter(w)
+	cw.Write(catalogColumns)
+	for _, e := range entries {
+		var exceptions []string
+		for _, ex := range e.Exceptions {
+			dates := ex.From
+			if ex.To != "" {
+				dates += "/" + ex.To
+			}
+			exceptions = append(exceptions, dates+"="+strings.Join(slices.Concat(ex.Open, ex.Hours), ","))
+		}
+		var cost, archived string
+		if e.Name != "" {
+			cost = strconv.Itoa(e.Cost)
+			archived = strconv.FormatBool(e.Archived)
+		}
+		row := map[string]string{
+			"group":         e.Group,
+			"parent":        parents[e.Group],
+			"name":          e.Name,
+			"cost":          cost,
+			"schedule":      formatSchedule(e.Open),
+			"hours":         formatOpeningHours(e.Hours),
+			"exceptions":    strings.Join(exceptions, ";"),
+			"tags":          strings.Join(e.Tags, ","),
+			"place":         e.Place,
+			"notes":         e.Notes,
+			"url":           e.URL,
+			"address":       e.Address,
+			"phone":         e.Phone,
+			"archived":      archived,
+			"snoozed_until": e.SnoozedUntil,
+		}
+		record := make([]string, len(catalogColumns))
+		for i, column := range catalogColumns {
+			record[i] = row[column]
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatSchedule formats the open periods of an entry as "day:period,period"
+// pairs separated by ";", in the order of the weekdays followed by the
+// holidays and any other days.
+func formatSchedule(open map[string][]string) string {
+	var days []string
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		days = append(days, weekdays[wd].Short)
+	}
+	days = append(days, holidayKey)
+	for _, day := range slices.Sorted(maps.Keys(open)) {
+		if !slices.Contains(days, day) {
+			days = append(days, day)
+		}
+	}
+
+	var parts []string
+	for _, day := range days {
+		if periods, ok := open[day]; ok {
+			parts = append(parts, day+":"+strings.Join(periods, ","))
+		}
+	}
+	return strings.Join(parts, ";")
+}
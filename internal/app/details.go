@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Details holds optional information about an entry, shown in an expandable
+// panel in the vote and tally pages.
+type Details struct {
+	Notes   string `json:",omitempty"`
+	URL     string `json:",omitempty"` // Website or menu, with an http or https scheme.
+	Address string `json:",omitempty"`
+	Phone   string `json:",omitempty"`
+}
+
+// phoneChars are the characters allowed in phone numbers besides digits.
+const phoneChars = " +-().#*"
+
+// Validate checks that the URL is an absolute http or https URL and that the
+// phone number only has digits and the usual separators.
+func (d Details) Validate() error {
+	if d.URL != "" && !validURL(d.URL) {
+		return fmt.Errorf("URL %q must be an absolute http or https URL", d.URL)
+	}
+	if d.Phone != "" && !validPhone(d.Phone) {
+		return fmt.Errorf("phone %q must only have digits and any of %q", d.Phone, phoneChars)
+	}
+	return nil
+}
+
+// validURL reports whether s is an absolute http or https URL. Other schemes,
+// such as javascript:, are rejected so that links are safe to follow.
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validPhone reports whether s has only digits and the characters in
+// phoneChars, with at least one digit.
+func validPhone(s string) bool {
+	digits := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case !strings.ContainsRune(phoneChars, r):
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// parseDetail sets the field of d for a detail sent by the edit page, which
+// is one of "n:notes", "u:url", "a:address" or "p:phone", URL-encoded. It
+// returns false if part is not a detail, and an error if the detail is
+// malformed or is an invalid URL or phone number.
+func (d *Details) parseDetail(part string) (bool, error) {
+	fields := map[string]*string{
+		"n:": &d.Notes,
+		"u:": &d.URL,
+		"a:": &d.Address,
+		"p:": &d.Phone,
+	}
+	field, ok := fields[part[:min(len(part), 2)]]
+	if !ok {
+		return false, nil
+	}
+	value, err := url.PathUnescape(part[2:])
+	if err != nil {
+		return true, fmt.Errorf("detail %q is malformed", part)
+	}
+	*field = strings.TrimSpace(value)
+	return true, d.Validate()
+}
@@ -0,0 +1,96 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestDetailsValidate(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		details app.Details
+		wantErr string
+	}{{
+		desc: "no details",
+	}, {
+		desc: "valid details",
+		details: app.Details{
+			Notes:   "Great pizza.\nCash only.",
+			URL:     "https://pizza.example.com/menu",
+			Address: "1 Main St",
+			Phone:   "+1 (555) 123-4567",
+		},
+	}, {
+		desc:    "javascript URL",
+		details: app.Details{URL: "javascript:alert(1)"},
+		wantErr: "must be an absolute http or https URL",
+	}, {
+		desc:    "relative URL",
+		details: app.Details{URL: "/menu"},
+		wantErr: "must be an absolute http or https URL",
+	}, {
+		desc:    "phone with letters",
+		details: app.Details{Phone: "call me"},
+		wantErr: `phone "call me" must only have digits`,
+	}, {
+		desc:    "phone without digits",
+		details: app.Details{Phone: "+()"},
+		wantErr: `phone "+()" must only have digits`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := test.details.Validate(); !errorContains(err, test.wantErr) {
+				t.Errorf("Validate() = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetailsPanel(t *testing.T) {
+	entries := testEntries()
+	entries[0].Details = app.Details{
+		Notes:   "Ask for the <special>.",
+		URL:     "https://pizza.example.com/menu?lang=en&size=large",
+		Address: "1 Main St",
+		Phone:   "+1 555 123",
+	}
+	// URLs that bypassed validation, such as in an imported database, are
+	// still rendered safely.
+	entries[1].Details = app.Details{URL: "javascript:alert(1)"}
+	a := newTestApp(t, entries...)
+
+	wantBody := []string{
+		`<summary>Details</summary>`,
+		`<p class="entry-notes">Ask for the &lt;special&gt;.</p>`,
+		`<a href="https://pizza.example.com/menu?lang=en&amp;size=large" target="_blank" rel="noopener noreferrer">Website</a>`,
+		`<a href="https://www.openstreetmap.org/search?query=1%20Main%20St" target="_blank" rel="noopener noreferrer">1 Main St</a>`,
+		`<a href="tel:&#43;1%20555%20123">&#43;1 555 123</a>`,
+		`<a href="#ZgotmplZ" target="_blank" rel="noopener noreferrer">Website</a>`,
+	}
+
+	for _, url := range []string{"/?token=tokenA", "/votes?period=lunch&token=tokenA"} {
+		t.Run(url, func(t *testing.T) {
+			req := httptest.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, s := range wantBody {
+				if !strings.Contains(body, s) {
+					t.Errorf("body does not contain %q", s)
+				}
+			}
+			if n := strings.Count(body, `<details class="entry-details">`); n != 2 {
+				t.Errorf("body has %d detail panels, want 2", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// cleanName returns name without leading and trailing spaces, and with other
+// runs of spaces replaced by a single space.
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// normalizeName returns the form of name used for detecting duplicates: in
+// lower case, with only its letters and digits, in words separated by single
+// spaces.
+func normalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// editDistance returns the Levenshtein distance between a and b, counted in
+// runes.
+func editDistance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	row := make([]int, len(y)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := range x {
+		prev := row[0]
+		row[0] = i + 1
+		for j := range y {
+			cur := row[j+1]
+			cost := 1
+			if x[i] == y[j] {
+				cost = 0
+			}
+			row[j+1] = min(row[j+1]+1, row[j]+1, prev+cost)
+			prev = cur
+		}
+	}
+	return row[len(y)]
+}
+
+// similarNames reports whether a and b are likely to be names of the same
+// entry: their normalized forms are the same, or differ by at most one edit
+// for every 6 letters of the shorter one.
+func similarNames(a, b string) bool {
+	x, y := normalizeName(a), normalizeName(b)
+	if x == y {
+		return true
+	}
+	n := min(utf8.RuneCountInString(x), utf8.RuneCountInString(y))
+	return editDistance(x, y) <= n/6
+}
+
+// duplicateOf returns a function reporting whether an entry is in the same
+// group as e with the same normalized name.
+func duplicateOf(e Entry) func(Entry) bool {
+	name := normalizeName(e.Name)
+	return func(other Entry) bool {
+		return other.Group == e.Group && normalizeName(other.Name) == name
+	}
+}
+
+// checkDuplicates returns an error if any two entries are duplicates in the
+// same group.
+func checkDuplicates(entries []Entry) error {
+	for i, e := range entries {
+		if j := slices.IndexFunc(entries[i+1:], duplicateOf(e)); j >= 0 {
+			return fmt.Errorf("%q and %q in %q are duplicates", e.Name, entries[i+1+j].Name, e.Group)
+		}
+	}
+	return nil
+}
+
+// duplicateData holds two entries with similar names for template rendering.
+type duplicateData struct {
+	Name       string
+	Group      string
+	OtherName  string
+	OtherGroup string
+	Exact      bool
+}
+
+// duplicates returns the pairs of unarchived entries visible to person with
+// similar names and the same owner, within and across groups. Locations of
+// the same place are not duplicates.
+func (a *App) duplicates(person string) []duplicateData {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries := slices.DeleteFunc(slices.Clone(a.db.Entries), func(e Entry) bool {
+		return e.Archived || !e.visibleTo(person)
+	})
+	var result []duplicateData
+	for i, e := range entries {
+		for _, other := range entries[i+1:] {
+			if e.Owner != other.Owner || e.Place != "" && e.Place == other.Place || !similarNames(e.Name, other.Name) {
+				continue
+			}
+			result = append(result, duplicateData{
+				Name:       e.Name,
+				Group:      e.Group,
+				OtherName:  other.Name,
+				OtherGroup: other.Group,
+				Exact:      normalizeName(e.Name) == normalizeName(other.Name),
+			})
+		}
+	}
+	return result
+}
+
+// mergeEntry returns into with the open periods, opening hours, exceptions
+// and tags of from added to its own, and with the place and details of from
+// where into has none.
+func mergeEntry(into, from Entry) Entry {
+	merged := into
+	merged.Open = make(map[string][]string)
+	for _, open := range []map[string][]string{into.Open, from.Open} {
+		for day, periods := range open {
+			for _, period := range periods {
+				if !slices.Contains(merged.Open[day], period) {
+					merged.Open[day] = append(merged.Open[day], period)
+				}
+			}
+		}
+	}
+	if into.Hours != nil || from.Hours != nil {
+		merged.Hours = make(Hours)
+		for _, hours := range []Hours{into.Hours, from.Hours} {
+			for day, intervals := range hours {
+				for _, interval := range intervals {
+					if !slices.Contains(merged.Hours[day], interval) {
+						merged.Hours[day] = append(merged.Hours[day], interval)
+					}
+				}
+			}
+		}
+	}
+	merged.Exceptions = slices.Clone(into.Exceptions)
+	for _, ex := range from.Exceptions {
+		if !slices.ContainsFunc(merged.Exceptions, func(other Exception) bool {
+			return reflect.DeepEqual(ex, other)
+		}) {
+			merged.Exceptions = append(merged.Exceptions, ex)
+		}
+	}
+	merged.Tags = slices.Clone(into.Tags)
+	for _, tag := range from.Tags {
+		if !slices.Contains(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+	if merged.Place == "" {
+		merged.Place = from.Place
+	}
+	for _, detail := range []struct{ into, from *string }{
+		{&merged.Notes, &from.Notes},
+		{&merged.URL, &from.URL},
+		{&merged.Address, &from.Address},
+		{&merged.Phone, &from.Phone},
+	} {
+		if *detail.into == "" {
+			*detail.into = *detail.from
+		}
+	}
+	return merged
+}
+
+// mergeEntries merges the entry from into the entry into, both given as
+// "Group|Name", on behalf of person. The merged entry gets the schedule, tags,
+// place and details of both entries as done by mergeEntry, and people who
+// only voted for one of them keep their vote. Plans for from are moved to
+// into. Only entries with the same owner can be merged.
+func (a *App) mergeEntries(person, from, into string) error {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	find := func(key string) int {
+		group, name, _ := strings.Cut(key, "|")
+		return slices.IndexFunc(a.db.Entries, func(e Entry) bool {
+			return e.Group == group && e.Name == name && e.visibleTo(person)
+		})
+	}
+	i, j := find(from), find(into)
+	if i < 0 || j < 0 || i == j || a.db.Entries[i].Owner != a.db.Entries[j].Owner {
+		return fmt.Errorf("cannot merge %q into %q", from, into)
+	}
+	a.mergeEntryAt(i, j)
+	return nil
+}
+
+// mergeEntryAt merges the entry at index i into the one at index j, as
+// described in mergeEntries. The caller must hold the write lock.
+func (a *App) mergeEntryAt(i, j int) {
+	from, into := a.db.Entries[i], a.db.Entries[j]
+	merged := mergeEntry(into, from)
+
+	entries := slices.Clone(a.db.Entries)
+	entries[j] = merged
+	entries = slices.Delete(entries, i, i+1)
+	a.db.Entries = entries
+
+	// People keep their vote for the merged entry, or else their vote for
+	// into, or else their vote for from.
+	key := func(e Entry) [2]string {
+		group, name := e.voteKey()
+		return [2]string{group, name}
+	}
+	keys := [][2]string{key(merged), key(into), key(from)}
+	for _, pv := range a.db.Votes {
+		for _, k := range keys {
+			if v, ok := pv[k[0]][k[1]]; ok {
+				if pv[keys[0][0]] == nil {
+					pv[keys[0][0]] = make(GroupVote)
+				}
+				pv[keys[0][0]][keys[0][1]] = v
+				break
+			}
+		}
+	}
+	// Votes are only removed from keys that no other entry uses.
+	for _, k := range keys[1:] {
+		if k == keys[0] || slices.ContainsFunc(entries, func(e Entry) bool { return key(e) == k }) {
+			continue
+		}
+		for _, pv := range a.db.Votes {
+			delete(pv[k[0]], k[1])
+			if len(pv[k[0]]) == 0 {
+				delete(pv, k[0])
+			}
+		}
+	}
+
+	plans := slices.Clone(a.db.Plans)
+	for p, plan := range plans {
+		if plan.Group == from.Group && plan.Name == from.Name {
+			plans[p].Group, plans[p].Name = into.Group, into.Name
+		}
+	}
+	a.db.Plans = plans
+}
+
+// cleanEntryNames cleans the entry names with cleanName, merging entries whose
+// names become the same as another entry of the same owner in their group.
+// The caller must hold the write lock.
+func (a *App) cleanEntryNames() {
+	for i := 0; i < len(a.db.Entries); i++ {
+		e := a.db.Entries[i]
+		name := cleanName(e.Name)
+		if name == e.Name {
+			continue
+		}
+		if j := slices.IndexFunc(a.db.Entries, func(other Entry) bool {
+			return other.Group == e.Group && other.Name == name && other.Owner == e.Owner
+		}); j >= 0 {
+			a.mergeEntryAt(i, j)
+			i--
+			continue
+		}
+		a.renameEntry(i, name)
+	}
+}
+
+// renameEntry renames the entry at index i, moving its votes and plans. The
+// caller must hold the write lock.
+func (a *App) renameEntry(i int, name string) {
+	e := a.db.Entries[i]
+	entries := slices.Clone(a.db.Entries)
+	entries[i].Name = name
+	a.db.Entries = entries
+	a.moveEntryData(e, entries[i])
+}
+
+// moveEntryData moves the votes and plans of the entry from to the entry to,
+// which is the same entry with a different group or name. Votes for places
+// are not moved. The caller must hold the write lock.
+func (a *App) moveEntryData(from, to Entry) {
+	if from.Place == "" {
+		for _, pv := range a.db.Votes {
+			v, ok := pv[from.Group][from.Name]
+			if !ok {
+				continue
+			}
+			delete(pv[from.Group], from.Name)
+			if len(pv[from.Group]) == 0 {
+				delete(pv, from.Group)
+			}
+			if pv[to.Group] == nil {
+				pv[to.Group] = make(GroupVote)
+			}
+			pv[to.Group][to.Name] = v
+		}
+	}
+
+	plans := slices.Clone(a.db.Plans)
+	for p, plan := range plans {
+		if plan.Group == from.Group && plan.Name == from.Name {
+			plans[p].Group, plans[p].Name = to.Group, to.Name
+		}
+	}
+	a.db.Plans = plans
+}
+
+// handleMergePost merges two entries, sent as "Group|Name" in the "entry"
+// field, into the one sent in the "into" field, on behalf of an editor.
+func (a *App) handleMergePost(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !a.checkCSRF(w, r, person) || !a.checkEditor(w, person) {
+		return
+	}
+
+	into := r.PostForm.Get("into")
+	entries := r.PostForm["entry"]
+	if len(entries) != 2 || !slices.Contains(entries, into) {
+		http.Error(w, "Bad Request: invalid entries", http.StatusBadRequest)
+		return
+	}
+	from := entries[0]
+	if from == into {
+		from = entries[1]
+	}
+	if err := a.mergeEntries(person, from, into); err != nil {
+		http.Error(w, "Bad Request: invalid entries", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, a.basePath+"/entries?token="+token, http.StatusSeeOther)
+}
@@ -0,0 +1,242 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestSimilarNames(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		want bool
+	}{
+		{"Pizza Corner", "Pizza corner ", true},
+		{"Pizza Corner", "pizza-corner", true},
+		{"Pizza Corner", "Pizza Cornr", true},
+		{"Café Central", "cafe central", true},
+		{"Pizza Place", "Pasta Place", false},
+		{"Bar", "Car", false},
+		{"Sushi Bar", "Burger Joint", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+"|"+test.b, func(t *testing.T) {
+			if got := app.SimilarNames(test.a, test.b); got != test.want {
+				t.Errorf("SimilarNames(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	// Entries with duplicates within and across groups.
+	entries := []app.Entry{
+		{Name: "Pizza Corner", Group: "Downtown", Open: map[string][]string{"mon": {"lunch"}}, Tags: app.Tags{"pizza"}, Cost: 2},
+		{Name: "pizza corner!", Group: "Downtown", Open: map[string][]string{"mon": {"dinner"}, "tue": {"lunch"}}, Tags: app.Tags{"delivery"}, Cost: 1, Details: app.Details{Phone: "555-0100"}},
+		{Name: "Pizza Cornr", Group: "Uptown", Open: map[string][]string{}, Cost: 1},
+		{Name: "Noodle House", Group: "Downtown", Place: "Noodle House", Open: map[string][]string{}, Cost: 1},
+		{Name: "Noodle House", Group: "Uptown", Place: "Noodle House", Open: map[string][]string{}, Cost: 1},
+		{Name: "Pizza Korner", Group: "Uptown", Owner: "bob", Open: map[string][]string{}, Cost: 1},
+		{Name: "Sushi Bar", Group: "Uptown", Open: map[string][]string{}, Cost: 3, Archived: true},
+		{Name: "Sushi Bar", Group: "Downtown", Open: map[string][]string{}, Cost: 3},
+	}
+
+	// Locations of a place, archived entries and the private entries of
+	// other people are not listed.
+	a := newTestApp(t, entries...)
+	req := httptest.NewRequest("GET", "/entries?token=tokenA", nil)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	body := w.Body.String()
+	for _, want := range []string{
+		`"Pizza Corner" in Downtown is a duplicate of "pizza corner!" in Downtown.`,
+		`"Pizza Corner" in Downtown looks like "Pizza Cornr" in Uptown.`,
+		`"pizza corner!" in Downtown looks like "Pizza Cornr" in Uptown.`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("edit page does not contain %q", want)
+		}
+	}
+	if n := strings.Count(body, `class="duplicate"`); n != 3 {
+		t.Errorf("edit page has %d duplicates, want 3", n)
+	}
+
+	var tests = []struct {
+		desc     string
+		person   string
+		entries  []string
+		into     string
+		wantCode int
+	}{{
+		desc:     "merge within group",
+		person:   "alice",
+		entries:  []string{"Downtown|pizza corner!", "Downtown|Pizza Corner"},
+		into:     "Downtown|Pizza Corner",
+		wantCode: http.StatusSeeOther,
+	}, {
+		desc:     "same entry",
+		person:   "alice",
+		entries:  []string{"Downtown|Pizza Corner", "Downtown|Pizza Corner"},
+		into:     "Downtown|Pizza Corner",
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "into is not one of the entries",
+		person:   "alice",
+		entries:  []string{"Downtown|pizza corner!", "Downtown|Pizza Corner"},
+		into:     "Uptown|Pizza Cornr",
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "private entry of someone else",
+		person:   "alice",
+		entries:  []string{"Uptown|Pizza Korner", "Downtown|Pizza Corner"},
+		into:     "Downtown|Pizza Corner",
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "private entry with a public one",
+		person:   "bob",
+		entries:  []string{"Uptown|Pizza Korner", "Downtown|Pizza Corner"},
+		into:     "Downtown|Pizza Corner",
+		wantCode: http.StatusBadRequest,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t, entries...)
+			a.UpdateVotes("alice", map[string]string{"Downtown|pizza corner!": "strong-no"})
+			a.UpdateVotes("bob", map[string]string{"Downtown|Pizza Corner": "yes", "Downtown|pizza corner!": "no"})
+			a.UpdatePlan(app.Plan{Date: "2099-01-01", Period: "lunch", Group: "Downtown", Name: "pizza corner!"})
+
+			form := url.Values{
+				"_csrf": {a.CSRFToken(test.person)},
+				"entry": test.entries,
+				"into":  {test.into},
+			}
+			req := httptest.NewRequest("POST", "/merge?token="+testPeople()[test.person], strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if w.Code != test.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantCode)
+			}
+			if test.wantCode != http.StatusSeeOther {
+				if len(a.Entries()) != len(entries) {
+					t.Errorf("got %d entries, want %d", len(a.Entries()), len(entries))
+				}
+				return
+			}
+
+			merged := a.Entries()
+			if len(merged) != len(entries)-1 {
+				t.Errorf("got %d entries, want %d", len(merged), len(entries)-1)
+			}
+			want := app.Entry{
+				Name:    "Pizza Corner",
+				Group:   "Downtown",
+				Open:    map[string][]string{"mon": {"lunch", "dinner"}, "tue": {"lunch"}},
+				Tags:    app.Tags{"pizza", "delivery"},
+				Cost:    2,
+				Details: app.Details{Phone: "555-0100"},
+			}
+			if e, ok := findEntry(merged, "Downtown", "Pizza Corner"); !ok || !entryMatches(e, want) {
+				t.Errorf("merged entry = %+v, want %+v", e, want)
+			}
+			wantVotes := map[string]app.PersonVote{
+				"alice": {"Downtown": {"Pizza Corner": "strong-no"}},
+				"bob":   {"Downtown": {"Pizza Corner": "yes"}},
+			}
+			if votes := a.Votes(); !reflect.DeepEqual(votes, wantVotes) {
+				t.Errorf("votes = %v, want %v", votes, wantVotes)
+			}
+			if plans := a.Plans(); len(plans) != 1 || plans[0].Name != "Pizza Corner" {
+				t.Errorf("plans = %+v, want the merged entry pinned", plans)
+			}
+		})
+	}
+}
+
+func TestUpdateEntriesDuplicates(t *testing.T) {
+	a := newTestApp(t)
+
+	form := url.Values{
+		"_csrf":                  {a.CSRFToken("alice")},
+		"Downtown|Pizza Place":   {"2"},
+		"Downtown|pizza  place ": {"1"},
+	}
+	req := httptest.NewRequest("POST", "/entries?token=tokenA", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if len(a.Entries()) != len(testEntries()) {
+		t.Errorf("got %d entries, want %d", len(a.Entries()), len(testEntries()))
+	}
+
+	if err := a.UpdateEntries([]app.Entry{{Name: "Pizza Corner", Group: "A"}, {Name: "Pizza Corner", Group: "B"}}); err != nil {
+		t.Errorf("UpdateEntries() with entries in different groups err = %v", err)
+	}
+}
+
+func TestCleanEntryNames(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		input string
+		load  func(*app.App, string) error
+	}{{
+		desc:  "migration",
+		input: `{"version":1,`,
+		load: func(a *app.App, s string) error {
+			return a.Load(strings.NewReader(s))
+		},
+	}, {
+		desc:  "import of current version",
+		input: `{"version":2,`,
+		load: func(a *app.App, s string) error {
+			req := httptest.NewRequest("POST", "/import.json?token=tokenA", strings.NewReader(s))
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if w.Code != http.StatusNoContent {
+				t.Errorf("import status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+			return nil
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			input := test.input + `"entries":[` +
+				`{"Name":"Pizza Corner","Group":"A","Open":{"mon":["lunch"]},"Cost":1},` +
+				`{"Name":"Pizza Corner ","Group":"A","Open":{"tue":["lunch"]},"Cost":2},` +
+				`{"Name":" Sushi  Bar","Group":"A","Open":{},"Cost":3}],` +
+				`"votes":{` +
+				`"alice":{"A":{"Pizza Corner ":"yes"," Sushi  Bar":"no"}},` +
+				`"bob":{"A":{"Pizza Corner":"strong-no","Pizza Corner ":"yes"}}}}`
+			if err := test.load(a, input); err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, e := range a.Entries() {
+				names = append(names, e.Group+"|"+e.Name)
+			}
+			if want := []string{"A|Pizza Corner", "A|Sushi Bar"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("entries = %v, want %v", names, want)
+			}
+			wantVotes := map[string]app.PersonVote{
+				"alice": {"A": {"Pizza Corner": "yes", "Sushi Bar": "no"}},
+				"bob":   {"A": {"Pizza Corner": "strong-no"}},
+			}
+			if votes := a.Votes(); !reflect.DeepEqual(votes, wantVotes) {
+				t.Errorf("votes = %v, want %v", votes, wantVotes)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package app
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 // GroupData is an exported alias for groupData, for use in tests.
 type GroupData = groupData
@@ -15,35 +18,94 @@ func (a *App) PersonForToken(token string) (string, bool) {
 
 // UpdateVotes exposes updateVotes for testing.
 func (a *App) UpdateVotes(person string, votes map[string]string) {
-	a.updateVotes(person, votes)
+	a.updateVotes(person, votes, tagFilter{})
 }
 
 // VotePageData exposes votePageData for testing.
 func (a *App) VotePageData(person string) []GroupData {
-	return a.entriesData(person)
+	return a.entriesData(person, a.votable(person, tagFilter{}))
 }
 
-// TallyData exposes tallyData for testing.
-func (a *App) TallyData(weekday time.Weekday, period string) []GroupData {
-	return a.tallyData(weekday, period)
+// TallyData exposes tallyData for testing, with the tally of someone who
+// cannot see any private entries.
+func (a *App) TallyData(date time.Time, period string, current bool) []GroupData {
+	return a.tallyData("", date, period, current, tagFilter{})
 }
 
-// PeriodForHour exposes periodForHour for testing.
-func PeriodForHour(periods Periods, hour int) string {
-	return periodForHour(periods, hour)
+// PersonTallyData exposes tallyData with the tally of person for testing.
+func (a *App) PersonTallyData(person string, date time.Time, period string) []GroupData {
+	return a.tallyData(person, date, period, false, tagFilter{})
+}
+
+// TagFilter is an exported alias for tagFilter, for use in tests.
+type TagFilter = tagFilter
+
+// FilteredTallyData exposes tallyData with a tag filter for testing.
+func (a *App) FilteredTallyData(date time.Time, period string, current bool, f TagFilter) []GroupData {
+	return a.tallyData("", date, period, current, f)
+}
+
+// Match exposes match for testing.
+func (f TagFilter) Match(tags Tags) bool {
+	return f.match(tags)
+}
+
+// PeriodWindow exposes periodWindow for testing.
+func PeriodWindow(bounds [2]int) (int, int) {
+	return periodWindow(bounds)
+}
+
+// Overlaps exposes overlaps for testing.
+func Overlaps(intervals []string, shift, start, end int) bool {
+	return overlaps(intervals, shift, start, end)
+}
+
+// DateForWeekday exposes dateForWeekday for testing.
+func (a *App) DateForWeekday(weekday time.Weekday) time.Time {
+	return a.dateForWeekday(weekday)
+}
+
+// UpdateHolidays exposes updateHolidays for testing.
+func (a *App) UpdateHolidays(holidays []Holiday) {
+	a.updateHolidays(holidays)
+}
+
+// Holidays exposes holidays for testing.
+func (a *App) Holidays() []Holiday {
+	return a.holidays()
 }
 
 // Weekdays exposes weekdays for testing.
 var Weekdays = weekdays
 
-// SetNowFunc overrides the time function used by the App for testing.
+// SetNowFunc overrides the clock used by the App for testing.
 func (a *App) SetNowFunc(f func() time.Time) {
-	a.nowFunc = f
+	a.clock = ClockFunc(f)
+}
+
+// PeriodTallyDate exposes periodTallyDate for testing.
+func (a *App) PeriodTallyDate(period string) time.Time {
+	return a.periodTallyDate(period)
+}
+
+// CurrentPeriod exposes currentPeriod for testing.
+func (a *App) CurrentPeriod() (string, time.Time) {
+	return a.currentPeriod()
+}
+
+// UpcomingPeriod exposes upcomingPeriod for testing.
+func (a *App) UpcomingPeriod() (string, time.Time) {
+	return a.upcomingPeriod()
+}
+
+// WallTime exposes wallTime for testing.
+func WallTime(loc *time.Location, year int, month time.Month, day, minute int) time.Time {
+	return wallTime(loc, year, month, day, minute)
 }
 
-// PeriodTallyWeekday exposes periodTallyWeekday for testing.
-func (a *App) PeriodTallyWeekday(period string) time.Weekday {
-	return a.periodTallyWeekday(period)
+// ParseDate exposes parseDate for testing.
+func (a *App) ParseDate(s string) (time.Time, error) {
+	return a.parseDate(s)
 }
 
 // Votes returns the current votes map for testing.
@@ -60,9 +122,10 @@ func (a *App) Entries() []Entry {
 	return a.db.Entries
 }
 
-// UpdateEntries exposes updateEntries for testing.
-func (a *App) UpdateEntries(entries []Entry) {
-	a.updateEntries(entries)
+// UpdateEntries exposes updateEntries for testing, on behalf of someone who
+// cannot see any private entries.
+func (a *App) UpdateEntries(entries []Entry) error {
+	return a.updateEntries("", entries)
 }
 
 // UpdateGroupOrder exposes updateGroupOrder for testing.
@@ -86,3 +149,117 @@ func SortGroupNames(names []string, groupOrder []string) {
 func WeekdayForShort(short string) (time.Weekday, bool) {
 	return weekdayForShort(short)
 }
+
+// SetNowFunc overrides the time function used by the AuthLimiter for testing.
+func (l *AuthLimiter) SetNowFunc(f func() time.Time) {
+	l.nowFunc = f
+}
+
+// ClientIP exposes clientIP for testing.
+func (l *AuthLimiter) ClientIP(r *http.Request) string {
+	return l.clientIP(r)
+}
+
+// Blocked exposes blocked for testing.
+func (l *AuthLimiter) Blocked(ip string) time.Duration {
+	return l.blocked(ip)
+}
+
+// Fail exposes fail for testing.
+func (l *AuthLimiter) Fail(ip string) time.Duration {
+	return l.fail(ip)
+}
+
+// CSRFToken returns the CSRF token for requests of person authenticated with
+// a token, for testing.
+func (a *App) CSRFToken(person string) string {
+	return a.signCSRF(person, "")
+}
+
+// SessionCSRFToken exposes csrfToken for testing requests authenticated with
+// the session cookie of r.
+func (a *App) SessionCSRFToken(r *http.Request, person string) string {
+	return a.csrfToken(r, person)
+}
+
+// HoursForPeriod exposes hoursForPeriod for testing.
+func HoursForPeriod(start, end int) []int {
+	return hoursForPeriod(start, end)
+}
+
+// UpdatePeriods exposes updatePeriods for testing.
+func (a *App) UpdatePeriods(periods Periods, renames map[string]string) error {
+	return a.updatePeriods(periods, renames)
+}
+
+// PeriodConfig exposes periodConfig for testing.
+func (a *App) PeriodConfig() (Periods, []string) {
+	return a.periodConfig()
+}
+
+// PlannerDay is an exported alias for plannerDay, for use in tests.
+type PlannerDay = plannerDay
+
+// PlannerData exposes plannerData for testing.
+func (a *App) PlannerData(person string, top int) []PlannerDay {
+	return a.plannerData(person, top)
+}
+
+// UpdatePlan exposes updatePlan for testing.
+func (a *App) UpdatePlan(plan Plan) {
+	a.updatePlan(plan)
+}
+
+// Plans returns the current plans for testing.
+func (a *App) Plans() []Plan {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.db.Plans
+}
+
+// IcalFold exposes icalFold for testing.
+func IcalFold(line string) string {
+	return icalFold(line)
+}
+
+// ParseOpeningHours exposes parseOpeningHours for testing.
+func ParseOpeningHours(s string) (Hours, error) {
+	return parseOpeningHours(s)
+}
+
+// FormatOpeningHours exposes formatOpeningHours for testing.
+func FormatOpeningHours(h Hours) string {
+	return formatOpeningHours(h)
+}
+
+// ValidGroupParents exposes validGroupParents for testing.
+func ValidGroupParents(parents map[string]string) map[string]string {
+	return validGroupParents(parents)
+}
+
+// UpdateGroupParents exposes updateGroupParents for testing.
+func (a *App) UpdateGroupParents(parents map[string]string) {
+	a.updateGroupParents(parents)
+}
+
+// GroupParents returns the current group parents for testing.
+func (a *App) GroupParents() map[string]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.db.GroupParents
+}
+
+// VoterData is an exported alias for voterData, for use in tests.
+type VoterData = voterData
+
+// Suggestions returns the pending suggestions for testing.
+func (a *App) Suggestions() []Suggestion {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.db.Suggestions
+}
+
+// SimilarNames exposes similarNames for testing.
+func SimilarNames(a, b string) bool {
+	return similarNames(a, b)
+}
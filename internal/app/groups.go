@@ -0,0 +1,93 @@
+package app
+
+import (
+	"maps"
+	"slices"
+)
+
+// validGroupParents returns the links in parents between non-empty groups,
+// dropping links of groups to themselves and links that would form cycles.
+// Links are considered in the order of the group names, so the result does
+// not depend on map iteration order.
+func validGroupParents(parents map[string]string) map[string]string {
+	valid := make(map[string]string)
+	for _, group := range slices.Sorted(maps.Keys(parents)) {
+		parent := parents[group]
+		if group == "" || parent == "" || group == parent {
+			continue
+		}
+		cycle := false
+		for p, ok := parent, true; ok; p, ok = valid[p] {
+			if p == group {
+				cycle = true
+				break
+			}
+		}
+		if !cycle {
+			valid[group] = parent
+		}
+	}
+	return valid
+}
+
+// nestGroups arranges groups, which map group names to their entries, in a
+// tree following the group parents. Groups without entries are included if
+// any of their subgroups has entries. Groups are sorted at each level by
+// sortGroupNames. The caller must hold the read lock.
+func (d *db) nestGroups(groups map[string][]entryData) []groupData {
+	children := make(map[string][]string)
+	seen := make(map[string]bool)
+	var roots []string
+	var add func(name string)
+	add = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		if parent, ok := d.GroupParents[name]; ok {
+			children[parent] = append(children[parent], name)
+			add(parent)
+		} else {
+			roots = append(roots, name)
+		}
+	}
+	for name := range groups {
+		add(name)
+	}
+
+	var build func(names []string) []groupData
+	build = func(names []string) []groupData {
+		sortGroupNames(names, d.GroupOrder)
+		var result []groupData
+		for _, name := range names {
+			result = append(result, groupData{
+				Name:    name,
+				Parent:  d.GroupParents[name],
+				Entries: groups[name],
+				Groups:  build(children[name]),
+			})
+		}
+		return result
+	}
+	return build(roots)
+}
+
+// flattenGroups returns groups and all their subgroups, with each group
+// followed by its subgroups.
+func flattenGroups(groups []groupData) []groupData {
+	var result []groupData
+	for _, g := range groups {
+		result = append(result, g)
+		result = append(result, flattenGroups(g.Groups)...)
+	}
+	return result
+}
+
+// updateGroupParents replaces the parents of groups.
+func (a *App) updateGroupParents(parents map[string]string) {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.db.GroupParents = validGroupParents(parents)
+}
@@ -0,0 +1,166 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestValidGroupParents(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		parents map[string]string
+		want    map[string]string
+	}{{
+		desc:    "valid parents",
+		parents: map[string]string{"Chinatown": "Downtown", "North": "Chinatown"},
+		want:    map[string]string{"Chinatown": "Downtown", "North": "Chinatown"},
+	}, {
+		desc:    "empty names",
+		parents: map[string]string{"": "Downtown", "Uptown": ""},
+		want:    map[string]string{},
+	}, {
+		desc:    "own parent",
+		parents: map[string]string{"Downtown": "Downtown"},
+		want:    map[string]string{},
+	}, {
+		desc:    "cycle",
+		parents: map[string]string{"A": "C", "B": "A", "C": "B", "D": "C"},
+		want:    map[string]string{"A": "C", "B": "A", "D": "C"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := app.ValidGroupParents(test.parents); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ValidGroupParents() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// groupTree describes nested groups as "Name(entries)[subgroups]".
+func groupTree(groups []app.GroupData) string {
+	var parts []string
+	for _, g := range groups {
+		s := g.Name + "(" + strings.Join(entryNames([]app.GroupData{{Entries: g.Entries}}), ",") + ")"
+		if len(g.Groups) > 0 {
+			s += "[" + groupTree(g.Groups) + "]"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestNestedGroups(t *testing.T) {
+	var tests = []struct {
+		desc      string
+		parents   map[string]string
+		order     []string
+		wantVote  string
+		wantTally string
+	}{{
+		desc:      "no parents",
+		wantVote:  "Chinatown(Burger Joint) Downtown(Pizza Place) Harbor(Taco Stand) Uptown(Sushi Bar)",
+		wantTally: "Chinatown(Burger Joint) Downtown(Pizza Place) Harbor(Taco Stand) Uptown(Sushi Bar)",
+	}, {
+		desc:      "nested",
+		parents:   map[string]string{"Chinatown": "Downtown", "Harbor": "Downtown"},
+		order:     []string{"Uptown", "Harbor", "Downtown", "Chinatown"},
+		wantVote:  "Uptown(Sushi Bar) Downtown(Pizza Place)[Harbor(Taco Stand) Chinatown(Burger Joint)]",
+		wantTally: "Uptown(Sushi Bar) Downtown(Pizza Place)[Harbor(Taco Stand) Chinatown(Burger Joint)]",
+	}, {
+		desc:      "parent without entries",
+		parents:   map[string]string{"Chinatown": "Center", "Harbor": "Center", "Center": "Downtown"},
+		wantVote:  "Downtown(Pizza Place)[Center()[Chinatown(Burger Joint) Harbor(Taco Stand)]] Uptown(Sushi Bar)",
+		wantTally: "Downtown(Pizza Place)[Center()[Chinatown(Burger Joint) Harbor(Taco Stand)]] Uptown(Sushi Bar)",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			// Burger Joint and Taco Stand are in groups that may be nested in
+			// Downtown.
+			entries := testEntries()
+			entries[1].Group = "Chinatown"
+			entries[3].Group = "Harbor"
+			a := newTestApp(t, entries...)
+			a.UpdateGroupParents(test.parents)
+			a.UpdateGroupOrder(test.order)
+
+			if got := groupTree(a.VotePageData("alice")); got != test.wantVote {
+				t.Errorf("vote page groups = %s, want %s", got, test.wantVote)
+			}
+			if got := groupTree(a.TallyData(testMonday, "dinner", false)); got != test.wantTally {
+				t.Errorf("tally groups = %s, want %s", got, test.wantTally)
+			}
+		})
+	}
+}
+
+func TestNestedGroupsPages(t *testing.T) {
+	entries := testEntries()
+	entries[1].Group = "Chinatown"
+	entries[3].Group = "Harbor"
+	a := newTestApp(t, entries...)
+	a.UpdateGroupParents(map[string]string{"Chinatown": "Downtown", "Harbor": "Downtown"})
+
+	for _, url := range []string{"/?token=tokenA", "/votes?period=lunch&token=tokenA"} {
+		t.Run(url, func(t *testing.T) {
+			req := httptest.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			downtown := strings.Index(body, `<summary class="location">Downtown</summary>`)
+			chinatown := strings.Index(body, `<summary class="location">Chinatown</summary>`)
+			uptown := strings.Index(body, `<summary class="location">Uptown</summary>`)
+			if downtown < 0 || chinatown < downtown || uptown < chinatown {
+				t.Fatalf("body does not nest Chinatown in Downtown")
+			}
+			// Chinatown is closed before Downtown is.
+			if n := strings.Count(body[downtown:uptown], "</details>"); n < 2 {
+				t.Errorf("Downtown section has %d closed sections, want at least 2", n)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/entries?token=tokenA", nil)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if want := `<input type="text" class="group-parent" value="Downtown" placeholder="Parent group" />`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("edit page does not contain %q", want)
+	}
+}
+
+func TestHandleEntriesPostGroupParents(t *testing.T) {
+	a := newTestApp(t)
+
+	form := url.Values{
+		"_csrf":        {a.CSRFToken("alice")},
+		"Downtown|A":   {"1"},
+		"Chinatown|B":  {"1"},
+		"Harbor|C":     {"1"},
+		"_groupOrder":  {"Downtown", "Chinatown", "Harbor"},
+		"_groupParent": {"Chinatown|Downtown", "Harbor|Chinatown", "Downtown|Harbor", "invalid"},
+	}
+	req := httptest.NewRequest("POST", "/entries?token=tokenA", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	// The link closing the cycle is dropped, in the order of the group names.
+	want := map[string]string{"Chinatown": "Downtown", "Downtown": "Harbor"}
+	if got := a.GroupParents(); !reflect.DeepEqual(got, want) {
+		t.Errorf("group parents = %v, want %v", got, want)
+	}
+}
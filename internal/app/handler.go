@@ -2,7 +2,9 @@ package app
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -10,29 +12,68 @@ import (
 	"github.com/alnvdl/anything/internal/version"
 )
 
-// authenticate extracts the token from the request and resolves it to a person.
-func (a *App) authenticate(r *http.Request) (string, bool) {
+// authenticate extracts the token from the request and resolves it to a
+// person. Requests without a token are authenticated by their session cookie
+// instead, and page loads without either are redirected to the login page if
+// OIDC is enabled. If authentication fails or the client is blocked for
+// failing too often, an error response is written and false is returned.
+func (a *App) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ip := a.limiter.clientIP(r)
+	// Blocked clients are only logged when the block starts, as they may
+	// keep sending requests.
+	if d := a.limiter.blocked(ip); d > 0 {
+		tooManyRequests(w, d)
+		return "", false
+	}
+
 	token := r.URL.Query().Get("token")
-	return a.personForToken(token)
+	if token == "" {
+		if person, ok := a.sessionPerson(r); ok {
+			return person, true
+		}
+		if a.oidc != nil && r.Method == http.MethodGet {
+			http.Redirect(w, r, a.basePath+"/login", http.StatusSeeOther)
+			return "", false
+		}
+	}
+	person, ok := a.personForToken(token)
+	if !ok {
+		if d := a.limiter.fail(ip); d > 0 {
+			tooManyRequests(w, d)
+		} else {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		}
+		return "", false
+	}
+	return person, true
 }
 
 // handleVote serves the voting page.
 func (a *App) handleVote(w http.ResponseWriter, r *http.Request) {
-	person, ok := a.authenticate(r)
+	person, ok := a.authenticate(w, r)
 	if !ok {
-		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
 	token := r.URL.Query().Get("token")
-	groups := a.entriesData(person)
+	filter := parseTagFilter(r.URL.Query())
+	groups := a.entriesData(person, a.votable(person, filter))
+	_, periodList := a.periodConfig()
 
 	data := pageData{
-		Title:   "Anything",
-		Token:   token,
-		Person:  person,
-		Periods: a.periodList,
-		Groups:  groups,
+		Title:      "Anything",
+		BasePath:   a.basePath,
+		LoggedIn:   token == "",
+		Token:      token,
+		CSRFToken:  a.csrfToken(r, person),
+		Nonce:      requestNonce(r),
+		Person:     person,
+		Periods:    periodList,
+		Groups:     groups,
+		Filter:     filter,
+		TagOptions: a.tagOptions(person, filter),
+
+		PrivateVotes: a.hasPrivateVotes(person),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -43,67 +84,57 @@ func (a *App) handleVote(w http.ResponseWriter, r *http.Request) {
 
 // handleTallyGet serves the tally page for a given period.
 func (a *App) handleTallyGet(w http.ResponseWriter, r *http.Request) {
-	person, ok := a.authenticate(r)
+	person, ok := a.authenticate(w, r)
 	if !ok {
-		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
-	token := r.URL.Query().Get("token")
+	periods, _ := a.periodConfig()
 	period := r.URL.Query().Get("period")
-	if _, ok := a.periods[period]; !ok {
+	if _, ok := periods[period]; !ok {
 		http.Error(w, "Bad Request: invalid period", http.StatusBadRequest)
 		return
 	}
 
-	var wd time.Weekday
-	if wdParam := r.URL.Query().Get("weekday"); wdParam != "" {
-		var ok bool
-		wd, ok = weekdayForShort(wdParam)
+	var date time.Time
+	if dateParam := r.URL.Query().Get("date"); dateParam != "" {
+		var err error
+		date, err = a.parseDate(dateParam)
+		if err != nil {
+			http.Error(w, "Bad Request: invalid date", http.StatusBadRequest)
+			return
+		}
+	} else if wdParam := r.URL.Query().Get("weekday"); wdParam != "" {
+		wd, ok := weekdayForShort(wdParam)
 		if !ok {
 			http.Error(w, "Bad Request: invalid weekday", http.StatusBadRequest)
 			return
 		}
+		date = a.dateForWeekday(wd)
 	} else {
-		wd = a.periodTallyWeekday(period)
-	}
-
-	groups := a.tallyData(wd, period)
-	prevWd := (wd + 6) % 7
-	nextWd := (wd + 1) % 7
-
-	data := pageData{
-		Title:            "Anything",
-		Token:            token,
-		Person:           person,
-		Period:           period,
-		Weekday:          weekdays[wd].Full,
-		PrevWeekdayShort: weekdays[prevWd].Short,
-		NextWeekdayShort: weekdays[nextWd].Short,
-		Periods:          a.periodList,
-		Groups:           groups,
+		date = a.periodTallyDate(period)
 	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := a.tallyTmpl.ExecuteTemplate(w, "layout", data); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-	}
+	current, start := a.currentPeriod()
+	a.renderTally(w, r, person, date, period, date.Equal(start) && period == current)
 }
 
 // handleTallyPost handles vote submission and shows the tally.
 func (a *App) handleTallyPost(w http.ResponseWriter, r *http.Request) {
-	person, ok := a.authenticate(r)
+	person, ok := a.authenticate(w, r)
 	if !ok {
-		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
-	token := r.URL.Query().Get("token")
-
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	if !a.checkCSRF(w, r, person) {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
 
 	// Extract votes from form data.
 	votes := make(map[string]string)
@@ -111,31 +142,61 @@ func (a *App) handleTallyPost(w http.ResponseWriter, r *http.Request) {
 		votes[name] = r.PostForm.Get(name)
 	}
 
-	a.updateVotes(person, votes)
-
-	now := a.nowFunc().In(a.timezone)
-	period := periodForHour(a.periods, now.Hour())
+	filter := parseTagFilter(r.URL.Query())
+	a.updateVotes(person, votes, filter)
+	a.setPrivateVotes(person, r.PostForm.Get("_private") != "")
 
+	period, date := a.currentPeriod()
 	if period == "" {
-		http.Error(w, "No active period", http.StatusBadRequest)
+		// Votes cast between periods count towards the next one.
+		next, date := a.upcomingPeriod()
+		http.Redirect(w, r, a.basePath+"/votes?period="+url.QueryEscape(next)+
+			"&date="+date.Format(time.DateOnly)+"&token="+token+filter.query(), http.StatusSeeOther)
 		return
 	}
 
-	wd := now.Weekday()
-	groups := a.tallyData(wd, period)
-	prevWd := (wd + 6) % 7
-	nextWd := (wd + 1) % 7
+	a.renderTally(w, r, person, date, period, true)
+}
+
+// renderTally renders the tally page for a given date and period.
+func (a *App) renderTally(w http.ResponseWriter, r *http.Request, person string, date time.Time, period string, current bool) {
+	token := r.URL.Query().Get("token")
+	filter := parseTagFilter(r.URL.Query())
+	groups := a.tallyData(person, date, period, current, filter)
+	_, periodList := a.periodConfig()
+
+	var plan *Plan
+	if p, ok := a.plan(person, date, period); ok {
+		plan = &p
+	}
+
+	var notice string
+	if current, _ := a.currentPeriod(); current == "" {
+		if next, nextDate := a.upcomingPeriod(); next == period && nextDate.Equal(date) {
+			notice = "No period is active right now, so this is the tally for the next one."
+		}
+	}
 
 	data := pageData{
-		Title:            "Anything",
-		Token:            token,
-		Person:           person,
-		Period:           period,
-		Weekday:          weekdays[wd].Full,
-		PrevWeekdayShort: weekdays[prevWd].Short,
-		NextWeekdayShort: weekdays[nextWd].Short,
-		Periods:          a.periodList,
-		Groups:           groups,
+		Title:      "Anything",
+		BasePath:   a.basePath,
+		LoggedIn:   token == "",
+		Token:      token,
+		CSRFToken:  a.csrfToken(r, person),
+		Nonce:      requestNonce(r),
+		Person:     person,
+		Period:     period,
+		Weekday:    weekdays[date.Weekday()].Full,
+		Date:       a.dateLabel(date),
+		Day:        date.Format(time.DateOnly),
+		PrevDate:   a.addDays(date, -1).Format(time.DateOnly),
+		NextDate:   a.addDays(date, 1).Format(time.DateOnly),
+		Periods:    periodList,
+		Groups:     groups,
+		Notice:     notice,
+		Plan:       plan,
+		Filter:     filter,
+		TagOptions: a.tagOptions(person, filter),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -146,14 +207,13 @@ func (a *App) handleTallyPost(w http.ResponseWriter, r *http.Request) {
 
 // handleManifest serves the PWA manifest with the user's token.
 func (a *App) handleManifest(w http.ResponseWriter, r *http.Request) {
-	_, ok := a.authenticate(r)
+	_, ok := a.authenticate(w, r)
 	if !ok {
-		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
 	token := r.URL.Query().Get("token")
-	data := struct{ Token string }{Token: token}
+	data := struct{ BasePath, Token string }{BasePath: a.basePath, Token: token}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	if err := a.manifestTmpl.ExecuteTemplate(w, "manifest.json", data); err != nil {
@@ -165,11 +225,11 @@ func (a *App) handleStatus(w http.ResponseWriter, _ *http.Request) {
 	http.Error(w, version.Version(), http.StatusOK)
 }
 
-// handleExport serves a JSON dump of the entire database.
+// handleExport serves a JSON dump of the database, without what the person
+// cannot see or the entries left out by the tag filter in the query.
 func (a *App) handleExport(w http.ResponseWriter, r *http.Request) {
-	_, ok := a.authenticate(r)
+	person, ok := a.authenticate(w, r)
 	if !ok {
-		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -178,33 +238,69 @@ func (a *App) handleExport(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	enc := json.NewEncoder(w)
-	if err := enc.Encode(a.db); err != nil {
+	if err := enc.Encode(a.exportDB(person, parseTagFilter(r.URL.Query()))); err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
+// maxImportSize is the maximum size in bytes of an uploaded database dump.
+const maxImportSize = 10 << 20
+
+// handleImport replaces the database with an uploaded JSON dump in the same
+// format served by handleExport, keeping what the person cannot see. Only
+// editors can import.
+func (a *App) handleImport(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if !a.checkEditor(w, person) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := a.importDB(person, r.Body); err != nil {
+		http.Error(w, "Bad Request: invalid database dump: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // handleEntriesGet serves the entries editing page.
 func (a *App) handleEntriesGet(w http.ResponseWriter, r *http.Request) {
-	_, ok := a.authenticate(r)
+	person, ok := a.authenticate(w, r)
 	if !ok {
-		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
 	token := r.URL.Query().Get("token")
-	groups := a.entriesData("")
+	// Groups are nested in their parents by the page script.
+	groups := flattenGroups(a.entriesData("", func(e Entry) bool {
+		return !e.Archived && e.visibleTo(person)
+	}))
+	_, periodList := a.periodConfig()
 
 	wds := make([]weekdayInfo, 7)
 	for wd := time.Sunday; wd <= time.Saturday; wd++ {
 		wds[wd] = weekdays[wd]
 	}
+	wds = append(wds, weekdayInfo{Short: holidayKey, Full: "Holidays"})
 
 	data := pageData{
-		Title:    "Anything",
-		Token:    token,
-		Periods:  a.periodList,
-		Weekdays: wds,
-		Groups:   groups,
+		Title:      "Anything",
+		BasePath:   a.basePath,
+		LoggedIn:   token == "",
+		Token:      token,
+		CSRFToken:  a.csrfToken(r, person),
+		Nonce:      requestNonce(r),
+		Periods:    periodList,
+		Weekdays:   wds,
+		Groups:     groups,
+		Holidays:   a.holidays(),
+		CanEdit:    a.canEdit(person),
+		Duplicates: a.duplicates(person),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -213,11 +309,10 @@ func (a *App) handleEntriesGet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleEntriesPost handles entry editing form submission.
+// handleEntriesPost handles entry editing form submission by editors.
 func (a *App) handleEntriesPost(w http.ResponseWriter, r *http.Request) {
-	_, ok := a.authenticate(r)
+	person, ok := a.authenticate(w, r)
 	if !ok {
-		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -227,55 +322,212 @@ func (a *App) handleEntriesPost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	if !a.checkCSRF(w, r, person) || !a.checkEditor(w, person) {
+		return
+	}
 
 	var entries []Entry
 	for key := range r.PostForm {
-		value := r.PostForm.Get(key)
-		group, name, ok := strings.Cut(key, "|")
-		if !ok || group == "" || name == "" {
-			continue
+		e, ok, err := parseEntry(person, key, r.PostForm.Get(key))
+		if err != nil {
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
 		}
-
-		parts := strings.Split(value, ";")
-		if len(parts) < 1 {
-			continue
+		if ok {
+			entries = append(entries, e)
 		}
+	}
 
-		cost, err := strconv.Atoi(parts[0])
-		if err != nil || cost < 1 || cost > 4 {
-			continue
+	groupOrder := r.PostForm["_groupOrder"]
+
+	// Group parents are sent as "group|parent".
+	parents := make(map[string]string)
+	for _, value := range r.PostForm["_groupParent"] {
+		if group, parent, ok := strings.Cut(value, "|"); ok {
+			parents[group] = parent
 		}
+	}
 
-		open := make(map[string][]string)
-		for _, part := range parts[1:] {
-			if part == "" {
-				continue
-			}
-			day, periodsStr, ok := strings.Cut(part, ":")
-			if !ok || periodsStr == "" {
-				continue
-			}
-			periods := strings.Split(periodsStr, ",")
-			open[day] = periods
+	// Holidays are sent as "date|name".
+	var holidays []Holiday
+	for _, value := range r.PostForm["_holiday"] {
+		date, name, _ := strings.Cut(value, "|")
+		h := Holiday{Date: date, Name: name}
+		if validHoliday(h) {
+			holidays = append(holidays, h)
 		}
+	}
 
-		entries = append(entries, Entry{
-			Name:  name,
-			Group: group,
-			Cost:  cost,
-			Open:  open,
-		})
+	if err := a.updateEntries(person, entries); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
 	}
+	a.updateGroupOrder(groupOrder)
+	a.updateGroupParents(parents)
+	a.updateHolidays(holidays)
 
-	groupOrder := r.PostForm["_groupOrder"]
+	http.Redirect(w, r, a.basePath+"/?token="+token, http.StatusSeeOther)
+}
 
-	a.updateEntries(entries)
-	a.updateGroupOrder(groupOrder)
+// parseEntry parses an entry sent by person in the edit form, where the key
+// is "Group|Name" and the value has the cost followed by the other fields,
+// separated by ";". It reports whether the key and the cost are valid, and
+// returns an error if any other field of such an entry is malformed.
+func parseEntry(person, key, value string) (Entry, bool, error) {
+	group, name, ok := strings.Cut(key, "|")
+	name = cleanName(name)
+	if !ok || group == "" || name == "" || strings.Contains(name, "|") {
+		return Entry{}, false, nil
+	}
+
+	parts := strings.Split(value, ";")
+	if len(parts) < 1 {
+		return Entry{}, false, nil
+	}
 
-	http.Redirect(w, r, "/?token="+token, http.StatusSeeOther)
+	cost, err := strconv.Atoi(parts[0])
+	if err != nil || cost < 1 || cost > 4 {
+		return Entry{}, false, nil
+	}
+
+	open := make(map[string][]string)
+	var hours, openingHours Hours
+	var exceptions []Exception
+	var tags Tags
+	var details Details
+	var place, snoozedUntil, owner string
+	var archived bool
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		// Archived entries are sent with "archived", snoozed ones with
+		// "s:date", and private ones with "private".
+		if part == "archived" {
+			archived = true
+			continue
+		}
+		if part == "private" {
+			owner = person
+			continue
+		}
+		if date, ok := strings.CutPrefix(part, "s:"); ok {
+			if _, err := time.Parse(time.DateOnly, date); err == nil {
+				snoozedUntil = date
+			}
+			continue
+		}
+		if ok, err := details.parseDetail(part); err != nil {
+			return Entry{}, false, fmt.Errorf("entry %q in group %q has invalid details: %w", name, group, err)
+		} else if ok {
+			continue
+		}
+		// The place is sent URL-encoded as "place:name".
+		if spec, ok := strings.CutPrefix(part, "place:"); ok {
+			if name, err := url.PathUnescape(spec); err == nil {
+				place = strings.TrimSpace(name)
+			}
+			continue
+		}
+		// Tags are sent URL-encoded as "t:tag,tag".
+		if spec, ok := strings.CutPrefix(part, "t:"); ok {
+			if list, err := url.PathUnescape(spec); err == nil {
+				tags = parseTags(list)
+			}
+			continue
+		}
+		// Exceptions are sent as "x:from/to=spec", where spec lists
+		// periods and opening intervals, and is empty if closed.
+		if spec, ok := strings.CutPrefix(part, "x:"); ok {
+			if ex, ok := parseException(spec); ok {
+				exceptions = append(exceptions, ex)
+			}
+			continue
+		}
+		// Opening hours expressions are sent URL-encoded as "o:expr",
+		// and take precedence over the opening hours of each day.
+		if spec, ok := strings.CutPrefix(part, "o:"); ok {
+			expr, err := url.PathUnescape(spec)
+			if err != nil {
+				return Entry{}, false, fmt.Errorf("entry %q in group %q has invalid opening hours %q", name, group, spec)
+			}
+			openingHours, err = parseOpeningHours(expr)
+			if err != nil {
+				return Entry{}, false, fmt.Errorf("entry %q in group %q has invalid opening hours: %w", name, group, err)
+			}
+			continue
+		}
+		// Opening hours are sent as "h:day=interval,interval".
+		if spec, ok := strings.CutPrefix(part, "h:"); ok {
+			day, intervalsStr, ok := strings.Cut(spec, "=")
+			if !ok || intervalsStr == "" {
+				return Entry{}, false, fmt.Errorf("entry %q in group %q has invalid opening hours %q", name, group, spec)
+			}
+			dayHours := Hours{day: strings.Split(intervalsStr, ",")}
+			if err := dayHours.Validate(); err != nil {
+				return Entry{}, false, fmt.Errorf("entry %q in group %q has invalid opening hours: %w", name, group, err)
+			}
+			if hours == nil {
+				hours = make(Hours)
+			}
+			hours[day] = dayHours[day]
+			continue
+		}
+		day, periodsStr, ok := strings.Cut(part, ":")
+		if !ok || periodsStr == "" {
+			continue
+		}
+		periods := strings.Split(periodsStr, ",")
+		open[day] = periods
+	}
+	if openingHours != nil {
+		hours = openingHours
+	}
+
+	return Entry{
+		Name:       name,
+		Group:      group,
+		Place:      place,
+		Owner:      owner,
+		Cost:       cost,
+		Open:       open,
+		Hours:      hours,
+		Exceptions: exceptions,
+		Tags:       tags,
+		Details:    details,
+
+		Archived:     archived,
+		SnoozedUntil: snoozedUntil,
+	}, true, nil
 }
 
 // ServeHTTP implements http.Handler.
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	a.mux.ServeHTTP(w, r)
+	a.handler.ServeHTTP(w, r)
+}
+
+// parseException parses an exception sent by the edit page in
+// "from/to=spec" format, where spec is a comma-separated list of periods and
+// opening intervals. It returns false if the exception is invalid.
+func parseException(s string) (Exception, bool) {
+	dates, spec, ok := strings.Cut(s, "=")
+	if !ok {
+		return Exception{}, false
+	}
+	from, to, _ := strings.Cut(dates, "/")
+	ex := Exception{From: from, To: to}
+	if ex.To == ex.From {
+		ex.To = ""
+	}
+	for _, item := range strings.Split(spec, ",") {
+		if item == "" {
+			continue
+		}
+		if _, _, err := parseInterval(item); err == nil {
+			ex.Hours = append(ex.Hours, item)
+		} else {
+			ex.Open = append(ex.Open, item)
+		}
+	}
+	return ex, ex.Validate() == nil
 }
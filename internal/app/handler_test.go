@@ -2,9 +2,12 @@ package app_test
 
 import (
 	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -85,6 +88,7 @@ func TestHandleTallyGet(t *testing.T) {
 		token      string
 		period     string
 		weekday    string
+		date       string
 		wantStatus int
 		wantBody   []string
 	}{{
@@ -92,40 +96,68 @@ func TestHandleTallyGet(t *testing.T) {
 		token:      "tokenA",
 		period:     "lunch",
 		wantStatus: http.StatusOK,
-		wantBody:   []string{"Anything", "for lunch on", "Monday", "Downtown", "Uptown", "weekday=sun", "weekday=tue", "manifest.json?token=tokenA"},
+		wantBody:   []string{"Anything", "for lunch on", "Monday, February 9", "Downtown", "Uptown", "date=2026-02-08", "date=2026-02-10", "manifest.json?token=tokenA"},
 	}, {
 		desc:       "past period shows next day",
 		token:      "tokenA",
 		period:     "breakfast",
 		wantStatus: http.StatusOK,
-		wantBody:   []string{"Anything", "for breakfast on", "Tuesday", "weekday=mon", "weekday=wed"},
+		wantBody:   []string{"Anything", "for breakfast on", "Tuesday, February 10", "date=2026-02-09", "date=2026-02-11"},
 	}, {
 		desc:       "future period shows today",
 		token:      "tokenA",
 		period:     "dinner",
 		wantStatus: http.StatusOK,
-		wantBody:   []string{"Anything", "for dinner on", "Monday", "weekday=sun", "weekday=tue"},
+		wantBody:   []string{"Anything", "for dinner on", "Monday, February 9", "date=2026-02-08", "date=2026-02-10"},
 	}, {
 		desc:       "explicit weekday overrides default",
 		token:      "tokenA",
 		period:     "lunch",
 		weekday:    "fri",
 		wantStatus: http.StatusOK,
-		wantBody:   []string{"Anything", "for lunch on", "Friday", "weekday=thu", "weekday=sat"},
+		wantBody:   []string{"Anything", "for lunch on", "Friday, February 13", "date=2026-02-12", "date=2026-02-14"},
 	}, {
-		desc:       "explicit weekday sunday wraps to saturday and monday",
+		desc:       "explicit weekday sunday navigates across weeks",
 		token:      "tokenA",
 		period:     "lunch",
 		weekday:    "sun",
 		wantStatus: http.StatusOK,
-		wantBody:   []string{"Sunday", "weekday=sat", "weekday=mon"},
+		wantBody:   []string{"Sunday, February 15", "date=2026-02-14", "date=2026-02-16"},
 	}, {
-		desc:       "explicit weekday saturday wraps to friday and sunday",
+		desc:       "explicit weekday saturday navigates across weeks",
 		token:      "tokenA",
 		period:     "dinner",
 		weekday:    "sat",
 		wantStatus: http.StatusOK,
-		wantBody:   []string{"Saturday", "weekday=fri", "weekday=sun"},
+		wantBody:   []string{"Saturday, February 14", "date=2026-02-13", "date=2026-02-15"},
+	}, {
+		desc:       "explicit date",
+		token:      "tokenA",
+		period:     "lunch",
+		date:       "2026-03-01",
+		wantStatus: http.StatusOK,
+		wantBody:   []string{"for lunch on Sunday, March 1", "date=2026-02-28", "date=2026-03-02"},
+	}, {
+		desc:       "explicit date in another year",
+		token:      "tokenA",
+		period:     "dinner",
+		date:       "2027-01-01",
+		wantStatus: http.StatusOK,
+		wantBody:   []string{"Friday, January 1, 2027", "date=2026-12-31", "date=2027-01-02"},
+	}, {
+		desc:       "explicit date overrides weekday",
+		token:      "tokenA",
+		period:     "lunch",
+		weekday:    "fri",
+		date:       "2026-02-21",
+		wantStatus: http.StatusOK,
+		wantBody:   []string{"Saturday, February 21"},
+	}, {
+		desc:       "invalid date",
+		token:      "tokenA",
+		period:     "lunch",
+		date:       "2026-02-30",
+		wantStatus: http.StatusBadRequest,
 	}, {
 		desc:       "invalid weekday",
 		token:      "tokenA",
@@ -155,6 +187,9 @@ func TestHandleTallyGet(t *testing.T) {
 			if test.weekday != "" {
 				u += "&weekday=" + test.weekday
 			}
+			if test.date != "" {
+				u += "&date=" + test.date
+			}
 			req := httptest.NewRequest("GET", u, nil)
 			w := httptest.NewRecorder()
 			a.ServeHTTP(w, req)
@@ -182,6 +217,7 @@ func TestHandleTallyPost(t *testing.T) {
 	})
 
 	form := url.Values{}
+	form.Set("_csrf", a.CSRFToken("alice"))
 	form.Set("Downtown|Pizza Place", "strong-yes")
 	form.Set("Downtown|Burger Joint", "no")
 
@@ -200,7 +236,7 @@ func TestHandleTallyPost(t *testing.T) {
 	}
 
 	// Verify day navigation links appear.
-	for _, s := range []string{"weekday=sun", "weekday=tue", "manifest.json?token=tokenA"} {
+	for _, s := range []string{"date=2026-02-08", "date=2026-02-10", "manifest.json?token=tokenA"} {
 		if !strings.Contains(body, s) {
 			t.Errorf("body does not contain %q", s)
 		}
@@ -216,6 +252,232 @@ func TestHandleTallyPost(t *testing.T) {
 	}
 }
 
+func TestHandleTallyPostGap(t *testing.T) {
+	var tests = []struct {
+		desc         string
+		now          time.Time
+		wantLocation string
+	}{{
+		desc:         "gap before a period on the same day",
+		now:          time.Date(2026, 2, 9, 14, 0, 0, 0, time.UTC), // Monday.
+		wantLocation: "/votes?period=late+dinner&date=2026-02-09&token=tokenA",
+	}, {
+		desc:         "gap after the last period of the day",
+		now:          time.Date(2026, 2, 9, 23, 0, 0, 0, time.UTC), // Monday.
+		wantLocation: "/votes?period=lunch&date=2026-02-10&token=tokenA",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			// No period covers hours 13-17 and 22-10.
+			a, err := app.New(app.Params{
+				Entries:  testEntries(),
+				People:   testPeople(),
+				Timezone: time.UTC,
+				Periods: app.Periods{
+					"lunch":       {10, 13},
+					"late dinner": {18, 22},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.SetNowFunc(func() time.Time { return test.now })
+
+			form := url.Values{}
+			form.Set("_csrf", a.CSRFToken("alice"))
+			form.Set("Downtown|Pizza Place", "strong-yes")
+			req := httptest.NewRequest("POST", "/votes?token=tokenA", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != http.StatusSeeOther {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusSeeOther)
+			}
+			loc := w.Header().Get("Location")
+			if loc != test.wantLocation {
+				t.Errorf("Location = %q, want %q", loc, test.wantLocation)
+			}
+			if got := a.Votes()["alice"]["Downtown"]["Pizza Place"]; got != "strong-yes" {
+				t.Errorf("alice Pizza Place vote = %q, want strong-yes", got)
+			}
+
+			// The tally for the next period says it is not the current one.
+			req = httptest.NewRequest("GET", loc, nil)
+			w = httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s status = %d, want %d", loc, w.Code, http.StatusOK)
+			}
+			if !strings.Contains(w.Body.String(), "No period is active right now") {
+				t.Errorf("GET %s body does not explain that no period is active", loc)
+			}
+
+			// Other tallies do not.
+			req = httptest.NewRequest("GET", "/votes?period=lunch&date=2026-02-01&token=tokenA", nil)
+			w = httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if strings.Contains(w.Body.String(), "No period is active right now") {
+				t.Error("tally for another date explains that no period is active")
+			}
+		})
+	}
+}
+
+func TestHandleTallyPostAfterMidnight(t *testing.T) {
+	a, err := app.New(app.Params{
+		Entries:  testEntries(),
+		People:   testPeople(),
+		Timezone: time.UTC,
+		Periods: app.Periods{
+			"day":   {10, 22},
+			"night": {22, 2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tuesday at 00:30, in the night period that started on Monday.
+	a.SetNowFunc(func() time.Time {
+		return time.Date(2026, 2, 10, 0, 30, 0, 0, time.UTC)
+	})
+
+	form := url.Values{}
+	form.Set("_csrf", a.CSRFToken("alice"))
+	form.Set("Downtown|Pizza Place", "strong-yes")
+	req := httptest.NewRequest("POST", "/votes?token=tokenA", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest("GET", "/votes?period=night&token=tokenA", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	for _, body := range []string{w.Body.String(), rec.Body.String()} {
+		for _, s := range []string{"for night on", "Monday, February 9", "date=2026-02-08", "date=2026-02-10"} {
+			if !strings.Contains(body, s) {
+				t.Errorf("body does not contain %q", s)
+			}
+		}
+	}
+}
+
+func TestCSRFProtection(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		path       string
+		csrf       func(a *app.App) string
+		headers    map[string]string
+		wantStatus int
+	}{{
+		desc:       "vote with valid CSRF token",
+		path:       "/votes?token=tokenA",
+		csrf:       func(a *app.App) string { return a.CSRFToken("alice") },
+		wantStatus: http.StatusOK,
+	}, {
+		desc:       "vote without CSRF token",
+		path:       "/votes?token=tokenA",
+		csrf:       func(a *app.App) string { return "" },
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "vote with another person's CSRF token",
+		path:       "/votes?token=tokenA",
+		csrf:       func(a *app.App) string { return a.CSRFToken("bob") },
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "vote from a cross-site page",
+		path:       "/votes?token=tokenA",
+		csrf:       func(a *app.App) string { return a.CSRFToken("alice") },
+		headers:    map[string]string{"Sec-Fetch-Site": "cross-site"},
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "vote from a foreign origin",
+		path:       "/votes?token=tokenA",
+		csrf:       func(a *app.App) string { return a.CSRFToken("alice") },
+		headers:    map[string]string{"Origin": "https://evil.example"},
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "vote from the same origin",
+		path:       "/votes?token=tokenA",
+		csrf:       func(a *app.App) string { return a.CSRFToken("alice") },
+		headers:    map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://example.com"},
+		wantStatus: http.StatusOK,
+	}, {
+		desc:       "entries with valid CSRF token",
+		path:       "/entries?token=tokenA",
+		csrf:       func(a *app.App) string { return a.CSRFToken("alice") },
+		wantStatus: http.StatusSeeOther,
+	}, {
+		desc:       "entries with invalid CSRF token",
+		path:       "/entries?token=tokenA",
+		csrf:       func(a *app.App) string { return "forged" },
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "entries from a cross-site page",
+		path:       "/entries?token=tokenA",
+		csrf:       func(a *app.App) string { return a.CSRFToken("alice") },
+		headers:    map[string]string{"Sec-Fetch-Site": "cross-site"},
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "settings with invalid CSRF token",
+		path:       "/settings?token=tokenA",
+		csrf:       func(a *app.App) string { return "forged" },
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "import from a cross-site page",
+		path:       "/import.json?token=tokenA",
+		headers:    map[string]string{"Sec-Fetch-Site": "cross-site"},
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			a.SetNowFunc(func() time.Time {
+				return time.Date(2026, 2, 9, 12, 0, 0, 0, time.UTC)
+			})
+
+			form := url.Values{"G|Entry": {"1;mon:lunch"}}
+			if test.csrf != nil {
+				form.Set("_csrf", test.csrf(a))
+			}
+			req := httptest.NewRequest("POST", test.path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if test.wantStatus == http.StatusForbidden && len(a.Entries()) != len(testEntries()) {
+				t.Error("rejected post changed the entries")
+			}
+		})
+	}
+}
+
+func TestCSRFTokenInForms(t *testing.T) {
+	a := newTestApp(t)
+
+	for _, path := range []string{"/?token=tokenA", "/entries?token=tokenA", "/settings?token=tokenA"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, req)
+
+		want := `name="_csrf" value="` + a.CSRFToken("alice") + `"`
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("GET %s body does not contain %q", path, want)
+		}
+	}
+}
+
 func TestHandleTallyPostInvalidToken(t *testing.T) {
 	a := newTestApp(t)
 
@@ -289,7 +551,7 @@ func TestHandleEntriesGet(t *testing.T) {
 		desc:       "valid token shows entries form",
 		token:      "tokenA",
 		wantStatus: http.StatusOK,
-		wantBody:   []string{"Anything", "Downtown", "Uptown", "Pizza Place", "Burger Joint", "Sushi Bar", "Taco Stand", "Save", "+", "Add group", "mon", "tue", "wed", "thu", "fri", "sat", "sun", "breakfast", "lunch", "dinner", "move-group-up", "move-group-down", "manifest.json?token=tokenA"},
+		wantBody:   []string{"Anything", "Downtown", "Uptown", "Pizza Place", "Burger Joint", "Sushi Bar", "Taco Stand", "Save", "+", "Add group", "mon", "tue", "wed", "thu", "fri", "sat", "sun", "breakfast", "lunch", "dinner", "move-group-up", "move-group-down", "schedule-hours", "opening-hours", "manifest.json?token=tokenA"},
 	}, {
 		desc:       "invalid token",
 		token:      "bad",
@@ -322,10 +584,16 @@ func TestHandleEntriesGet(t *testing.T) {
 
 // entryMatches checks if an entry matches the expected values.
 func entryMatches(got, want app.Entry) bool {
-	if got.Name != want.Name || got.Group != want.Group || got.Cost != want.Cost {
+	if got.Name != want.Name || got.Group != want.Group || got.Cost != want.Cost || got.Place != want.Place {
+		return false
+	}
+	if !reflect.DeepEqual(got.Tags, want.Tags) || got.Details != want.Details {
+		return false
+	}
+	if got.Archived != want.Archived || got.SnoozedUntil != want.SnoozedUntil {
 		return false
 	}
-	if len(got.Open) != len(want.Open) {
+	if len(got.Open) != len(want.Open) || !reflect.DeepEqual(got.Hours, want.Hours) || !reflect.DeepEqual(got.Exceptions, want.Exceptions) {
 		return false
 	}
 	for day, wantPeriods := range want.Open {
@@ -358,9 +626,11 @@ func TestHandleEntriesPost(t *testing.T) {
 		token        string
 		form         url.Values
 		wantStatus   int
+		wantBody     string
 		wantLocation string
 		wantEntries  []app.Entry
 		wantOrder    []string
+		wantHolidays []app.Holiday
 	}{{
 		desc:  "valid post updates entries",
 		token: "tokenA",
@@ -414,6 +684,15 @@ func TestHandleEntriesPost(t *testing.T) {
 		wantStatus:   http.StatusSeeOther,
 		wantLocation: "/?token=tokenA",
 		wantEntries:  []app.Entry{},
+	}, {
+		desc:  "name with separator is discarded",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry|Name": {"1;mon:lunch"},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries:  []app.Entry{},
 	}, {
 		desc:  "non-numeric cost is discarded",
 		token: "tokenA",
@@ -441,6 +720,209 @@ func TestHandleEntriesPost(t *testing.T) {
 		wantStatus:   http.StatusSeeOther,
 		wantLocation: "/?token=tokenA",
 		wantEntries:  []app.Entry{},
+	}, {
+		desc:  "opening hours",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry": {"2;mon:lunch;h:mon=11:30-14:30,18:00-02:00"},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries: []app.Entry{{
+			Name:  "Entry",
+			Group: "G",
+			Cost:  2,
+			Open:  map[string][]string{"mon": {"lunch"}},
+			Hours: app.Hours{"mon": {"11:30-14:30", "18:00-02:00"}},
+		}},
+	}, {
+		desc:  "invalid opening hours",
+		token: "tokenA",
+		form: url.Values{
+			"G|A": {"2;mon:lunch;h:tue=bad"},
+			"G|B": {"2;mon:lunch"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantBody:    `entry "A" in group "G" has invalid opening hours`,
+		wantEntries: testEntries(),
+	}, {
+		desc:  "invalid weekday in opening hours",
+		token: "tokenA",
+		form: url.Values{
+			"G|B": {"2;mon:lunch;h:xyz=11:00-12:00"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantBody:    `entry "B" in group "G" has invalid opening hours: invalid weekday "xyz"`,
+		wantEntries: testEntries(),
+	}, {
+		desc:  "opening hours without intervals",
+		token: "tokenA",
+		form: url.Values{
+			"G|C": {"2;mon:lunch;h:wed"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantBody:    `entry "C" in group "G" has invalid opening hours "wed"`,
+		wantEntries: testEntries(),
+	}, {
+		desc:  "tags",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry": {"2;mon:lunch;t:Delivery%2C%20vegetarian%20friendly%2C%2Cdelivery"},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries: []app.Entry{{
+			Name:  "Entry",
+			Group: "G",
+			Cost:  2,
+			Open:  map[string][]string{"mon": {"lunch"}},
+			Tags:  app.Tags{"delivery", "vegetarian friendly"},
+		}},
+	}, {
+		desc:  "place",
+		token: "tokenA",
+		form: url.Values{
+			"A|Chain Downtown": {"2;mon:lunch;place:%20The%20Chain%3B%20Inc."},
+			"B|Chain Uptown":   {"1;place:The%20Chain%3B%20Inc."},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries: []app.Entry{{
+			Name:  "Chain Downtown",
+			Group: "A",
+			Place: "The Chain; Inc.",
+			Cost:  2,
+			Open:  map[string][]string{"mon": {"lunch"}},
+		}, {
+			Name:  "Chain Uptown",
+			Group: "B",
+			Place: "The Chain; Inc.",
+			Cost:  1,
+			Open:  map[string][]string{},
+		}},
+	}, {
+		desc:  "details",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry": {"2;u:https%3A%2F%2Fexample.com%2Fmenu;a:1%20Main%20St;p:%2B1%20555%20123;n:Cash%20only.%0AClosed%20in%20August."},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries: []app.Entry{{
+			Name:  "Entry",
+			Group: "G",
+			Cost:  2,
+			Open:  map[string][]string{},
+			Details: app.Details{
+				Notes:   "Cash only.\nClosed in August.",
+				URL:     "https://example.com/menu",
+				Address: "1 Main St",
+				Phone:   "+1 555 123",
+			},
+		}},
+	}, {
+		desc:  "invalid URL",
+		token: "tokenA",
+		form: url.Values{
+			"G|Invalid": {"2;u:javascript%3Aalert(1)"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantBody:    `entry "Invalid" in group "G" has invalid details: URL "javascript:alert(1)" must be an absolute http or https URL`,
+		wantEntries: testEntries(),
+	}, {
+		desc:  "invalid phone",
+		token: "tokenA",
+		form: url.Values{
+			"G|Invalid": {"2;p:call%20me"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantBody:    `entry "Invalid" in group "G" has invalid details: phone "call me" must only have digits`,
+		wantEntries: testEntries(),
+	}, {
+		desc:  "archived and snoozed entries",
+		token: "tokenA",
+		form: url.Values{
+			"G|Archived": {"2;mon:lunch;archived"},
+			"G|Snoozed":  {"1;s:2024-02-01"},
+			"G|Invalid":  {"1;s:2024-02-30;s:soon"},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries: []app.Entry{{
+			Name:     "Archived",
+			Group:    "G",
+			Cost:     2,
+			Open:     map[string][]string{"mon": {"lunch"}},
+			Archived: true,
+		}, {
+			Name:         "Snoozed",
+			Group:        "G",
+			Cost:         1,
+			Open:         map[string][]string{},
+			SnoozedUntil: "2024-02-01",
+		}, {
+			Name:  "Invalid",
+			Group: "G",
+			Cost:  1,
+			Open:  map[string][]string{},
+		}},
+	}, {
+		desc:  "opening hours expression",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry": {"2;h:mon=11:30-14:30;o:Tu%2CTh%2011%3A00-14%3A00%3B%20PH%20off"},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries: []app.Entry{{
+			Name:  "Entry",
+			Group: "G",
+			Cost:  2,
+			Open:  map[string][]string{},
+			Hours: app.Hours{"tue": {"11:00-14:00"}, "thu": {"11:00-14:00"}, "hol": {}},
+		}},
+	}, {
+		desc:  "invalid opening hours expression",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry":   {"2;o:Tu%2CTh%2011%3A00-14%3A00"},
+			"G|Invalid": {"2;h:mon=11:30-14:30;o:Tu%2011%3A00"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantBody:    `entry "Invalid" in group "G" has invalid opening hours: rule "Tu 11:00"`,
+		wantEntries: testEntries(),
+	}, {
+		desc:  "malformed opening hours expression",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry": {"2;o:Tu%zz"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantBody:    `entry "Entry" in group "G" has invalid opening hours "Tu%zz"`,
+		wantEntries: testEntries(),
+	}, {
+		desc:  "exceptions and holidays",
+		token: "tokenA",
+		form: url.Values{
+			"G|Entry":  {"2;mon:lunch;x:2024-12-20/2025-01-05=;x:2024-12-25/2024-12-25=lunch,18:00-22:00;x:2025-02-01/2025-01-01=;x:bad=lunch"},
+			"_holiday": {"2024-12-25|Christmas", "2025-01-01|", "invalid|Nope"},
+		},
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/?token=tokenA",
+		wantEntries: []app.Entry{{
+			Name:  "Entry",
+			Group: "G",
+			Cost:  2,
+			Open:  map[string][]string{"mon": {"lunch"}},
+			Exceptions: []app.Exception{
+				{From: "2024-12-20", To: "2025-01-05"},
+				{From: "2024-12-25", Open: []string{"lunch"}, Hours: []string{"18:00-22:00"}},
+			},
+		}},
+		wantHolidays: []app.Holiday{
+			{Date: "2024-12-25", Name: "Christmas"},
+			{Date: "2025-01-01"},
+		},
 	}, {
 		desc:  "empty schedule parts are skipped",
 		token: "tokenA",
@@ -532,6 +1014,7 @@ func TestHandleEntriesPost(t *testing.T) {
 			u := "/entries?token=" + test.token
 			var req *http.Request
 			if test.form != nil {
+				test.form.Set("_csrf", a.CSRFToken("alice"))
 				req = httptest.NewRequest("POST", u, strings.NewReader(test.form.Encode()))
 				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 			} else {
@@ -543,6 +1026,9 @@ func TestHandleEntriesPost(t *testing.T) {
 			if w.Code != test.wantStatus {
 				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
 			}
+			if !strings.Contains(w.Body.String(), test.wantBody) {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.wantBody)
+			}
 
 			if test.wantLocation != "" {
 				loc := w.Header().Get("Location")
@@ -568,6 +1054,12 @@ func TestHandleEntriesPost(t *testing.T) {
 				}
 			}
 
+			if test.wantHolidays != nil {
+				if got := a.Holidays(); !reflect.DeepEqual(got, test.wantHolidays) {
+					t.Errorf("holidays = %v, want %v", got, test.wantHolidays)
+				}
+			}
+
 			if test.wantOrder != nil {
 				order := a.GroupOrder()
 				if len(order) != len(test.wantOrder) {
@@ -708,3 +1200,174 @@ func TestHandleExport(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleImport(t *testing.T) {
+	var tests = []struct {
+		desc        string
+		token       string
+		body        string
+		wantStatus  int
+		wantEntries int
+	}{{
+		desc:        "valid dump replaces entries and votes",
+		token:       "tokenA",
+		body:        `{"entries":[{"Name":"A","Group":"G","Open":{},"Cost":1}],"votes":{"bob":{"G":{"A":"no"}}},"groupOrder":["G"]}`,
+		wantStatus:  http.StatusNoContent,
+		wantEntries: 1,
+	}, {
+		desc:        "invalid token",
+		token:       "bad",
+		body:        `{}`,
+		wantStatus:  http.StatusForbidden,
+		wantEntries: 4,
+	}, {
+		desc:        "invalid JSON",
+		token:       "tokenA",
+		body:        `{"entries":[`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "empty body",
+		token:       "tokenA",
+		body:        ``,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "invalid cost",
+		token:       "tokenA",
+		body:        `{"entries":[{"Name":"A","Group":"G","Open":{},"Cost":5}]}`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "name with separator",
+		token:       "tokenA",
+		body:        `{"entries":[{"Name":"A|B","Group":"G","Open":{},"Cost":1}]}`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "empty name",
+		token:       "tokenA",
+		body:        `{"entries":[{"Name":" ","Group":"G","Open":{},"Cost":1}]}`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "invalid hours",
+		token:       "tokenA",
+		body:        `{"entries":[{"Name":"A","Group":"G","Open":{},"Hours":{"mon":["bad"]},"Cost":1}]}`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "duplicate entries",
+		token:       "tokenA",
+		body:        `{"version":2,"entries":[{"Name":"Cafe","Group":"G","Open":{},"Cost":1},{"Name":"cafe","Group":"G","Open":{},"Cost":2}]}`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "invalid periods",
+		token:       "tokenA",
+		body:        `{"entries":[],"periods":{"lunch":[10,15],"dinner":[12,22]}}`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}, {
+		desc:        "dump too large",
+		token:       "tokenA",
+		body:        `{"entries":[]` + strings.Repeat(" ", 10<<20) + `}`,
+		wantStatus:  http.StatusBadRequest,
+		wantEntries: 4,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+
+			req := httptest.NewRequest("POST", "/import.json?token="+test.token, strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if got := len(a.Entries()); got != test.wantEntries {
+				t.Errorf("got %d entries, want %d", got, test.wantEntries)
+			}
+			if test.wantStatus == http.StatusNoContent && a.Votes()["bob"]["G"]["A"] != "no" {
+				t.Errorf("bob vote = %q, want no", a.Votes()["bob"]["G"]["A"])
+			}
+		})
+	}
+}
+
+func TestAuthenticateRateLimit(t *testing.T) {
+	limiter := app.NewAuthLimiter(app.AuthLimiterParams{
+		MaxFailures:   2,
+		BlockDuration: time.Minute,
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	a, err := app.New(app.Params{
+		Entries:     testEntries(),
+		People:      testPeople(),
+		Timezone:    time.UTC,
+		Periods:     testPeriods(),
+		AuthLimiter: limiter,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		desc           string
+		remoteAddr     string
+		token          string
+		wantStatus     int
+		wantRetryAfter string
+	}{{
+		desc:       "first failure is forbidden",
+		remoteAddr: "1.2.3.4:1000",
+		token:      "bad",
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:           "reaching the limit blocks the client",
+		remoteAddr:     "1.2.3.4:1000",
+		token:          "bad",
+		wantStatus:     http.StatusTooManyRequests,
+		wantRetryAfter: "60",
+	}, {
+		desc:           "blocked client is rejected even with a valid token",
+		remoteAddr:     "1.2.3.4:2000",
+		token:          "tokenA",
+		wantStatus:     http.StatusTooManyRequests,
+		wantRetryAfter: "60",
+	}, {
+		desc:       "other clients are not blocked",
+		remoteAddr: "5.6.7.8:1000",
+		token:      "bad",
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "valid token is accepted after a failure",
+		remoteAddr: "5.6.7.8:1000",
+		token:      "tokenA",
+		wantStatus: http.StatusOK,
+	}, {
+		desc:           "success does not reset failures",
+		remoteAddr:     "5.6.7.8:1000",
+		token:          "bad",
+		wantStatus:     http.StatusTooManyRequests,
+		wantRetryAfter: "60",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?token="+test.token, nil)
+			req.RemoteAddr = test.remoteAddr
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if got := w.Header().Get("Retry-After"); got != test.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, test.wantRetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	a := newTestApp(t)
+
+	for _, path := range []string{"/?token=tokenA", "/entries?token=tokenA", "/votes?period=lunch&token=tokenA", "/static/anything.css", "/?token=bad"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			for name := range app.DefaultSecurityHeaders {
+				if w.Header().Get(name) == "" {
+					t.Errorf("response has no %s header", name)
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersSpaces(t *testing.T) {
+	s := newTestSpaces(t)
+
+	for _, path := range []string{"/s/family/?token=tokenA", "/s/other/?token=tokenA", "/?token=tokenA", "/status"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+
+			for name := range app.DefaultSecurityHeaders {
+				if w.Header().Get(name) == "" {
+					t.Errorf("response has no %s header", name)
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersScriptNonces(t *testing.T) {
+	a := newTestApp(t)
+	nonceRe := regexp.MustCompile(`script-src 'nonce-([^']+)'`)
+
+	seen := make(map[string]bool)
+	for _, path := range []string{"/?token=tokenA", "/entries?token=tokenA", "/votes?period=lunch&token=tokenA"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			m := nonceRe.FindStringSubmatch(w.Header().Get("Content-Security-Policy"))
+			if m == nil {
+				t.Fatalf("Content-Security-Policy has no script nonce: %q", w.Header().Get("Content-Security-Policy"))
+			}
+			nonce := m[1]
+			if seen[nonce] {
+				t.Errorf("nonce %q was reused across requests", nonce)
+			}
+			seen[nonce] = true
+
+			body := w.Body.String()
+			scripts := strings.Count(body, "<script")
+			if scripts == 0 {
+				t.Fatal("page has no scripts")
+			}
+			if got := strings.Count(body, `<script nonce="`+nonce+`">`); got != scripts {
+				t.Errorf("%d of %d scripts carry the nonce", got, scripts)
+			}
+			if strings.Contains(body, "style=") {
+				t.Error("page uses inline styles, which are blocked by the CSP")
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersOverrides(t *testing.T) {
+	a, err := app.New(app.Params{
+		Entries:  testEntries(),
+		People:   testPeople(),
+		Timezone: time.UTC,
+		Periods:  testPeriods(),
+		SecurityHeaders: map[string]string{
+			"strict-transport-security": "",
+			"Content-Security-Policy":   "script-src 'self' 'nonce-{nonce}'",
+			"Permissions-Policy":        "camera=()",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/?token=tokenA", nil)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security = %q, want it removed", got)
+	}
+	if got := w.Header().Get("Permissions-Policy"); got != "camera=()" {
+		t.Errorf("Permissions-Policy = %q, want %q", got, "camera=()")
+	}
+	csp := w.Header().Get("Content-Security-Policy")
+	if !strings.HasPrefix(csp, "script-src 'self' 'nonce-") || strings.Contains(csp, "{nonce}") {
+		t.Errorf("Content-Security-Policy = %q, want overridden policy with nonce", csp)
+	}
+	if got := w.Header().Get("X-Frame-Options"); got != "DENY" {
+		t.Errorf("X-Frame-Options = %q, want default DENY", got)
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minutesPerDay is the number of minutes in a day.
+const minutesPerDay = 24 * 60
+
+// Hours maps weekday short names to opening intervals in "HH:MM-HH:MM"
+// format (e.g., {"fri": ["11:30-14:30", "18:00-02:00"]}). An interval ending
+// at or before its start runs past midnight into the next day, and "24:00"
+// may be used as an end time. The "hol" key holds the intervals for holidays.
+type Hours map[string][]string
+
+// parseInterval parses an interval in "HH:MM-HH:MM" format, returning its
+// start and end as minutes since midnight. The end is moved to the next day
+// for intervals that run past midnight.
+func parseInterval(s string) (int, int, error) {
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("interval %q is not in HH:MM-HH:MM format", s)
+	}
+	start, err := parseClock(strings.TrimSpace(startStr))
+	if err != nil || start == minutesPerDay {
+		return 0, 0, fmt.Errorf("interval %q has an invalid start time", s)
+	}
+	end, err := parseClock(strings.TrimSpace(endStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("interval %q has an invalid end time", s)
+	}
+	if end <= start {
+		end += minutesPerDay
+	}
+	return start, end, nil
+}
+
+// parseClock parses a time of day in "HH:MM" format, returning the number of
+// minutes since midnight. It accepts "24:00" as the end of the day.
+func parseClock(s string) (int, error) {
+	hStr, mStr, ok := strings.Cut(s, ":")
+	if !ok || len(hStr) != 2 || len(mStr) != 2 {
+		return 0, fmt.Errorf("time %q is not in HH:MM format", s)
+	}
+	h, errH := strconv.Atoi(hStr)
+	m, errM := strconv.Atoi(mStr)
+	if errH != nil || errM != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("time %q is not valid", s)
+	}
+	return h*60 + m, nil
+}
+
+// Validate checks that every weekday and interval in h is well-formed.
+func (h Hours) Validate() error {
+	for day, intervals := range h {
+		if _, ok := weekdayForShort(day); !ok && day != holidayKey {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+		for _, interval := range intervals {
+			if _, _, err := parseInterval(interval); err != nil {
+				return fmt.Errorf("%s: %w", day, err)
+			}
+		}
+	}
+	return nil
+}
+
+// overlaps reports whether any of the intervals, shifted by shift minutes,
+// overlaps the window of minutes [start, end). Invalid intervals are ignored.
+func overlaps(intervals []string, shift, start, end int) bool {
+	for _, interval := range intervals {
+		s, e, err := parseInterval(interval)
+		if err != nil {
+			continue
+		}
+		if s+shift < end && start < e+shift {
+			return true
+		}
+	}
+	return false
+}
+
+// periodWindow returns the window of minutes [start, end) covered by a period
+// with the given bounds in hours. The end is moved to the next day for periods
+// that wrap around midnight.
+func periodWindow(bounds [2]int) (int, int) {
+	start, end := bounds[0]*60, bounds[1]*60
+	if end <= start {
+		end += minutesPerDay
+	}
+	return start, end
+}
+
+// openUntil returns the latest end, shifted by shift minutes, of the intervals
+// that contain minute, and false if none of them does. Invalid intervals are
+// ignored.
+func openUntil(intervals []string, shift, minute int) (int, bool) {
+	until, ok := 0, false
+	for _, interval := range intervals {
+		s, e, err := parseInterval(interval)
+		if err != nil {
+			continue
+		}
+		if s+shift <= minute && minute < e+shift {
+			until, ok = max(until, e+shift), true
+		}
+	}
+	return until, ok
+}
+
+// nextOpening returns the earliest start, shifted by shift minutes, of the
+// intervals starting within the window of minutes [start, end), and false if
+// none of them does. Invalid intervals are ignored.
+func nextOpening(intervals []string, shift, start, end int) (int, bool) {
+	opening, ok := 0, false
+	for _, interval := range intervals {
+		s, _, err := parseInterval(interval)
+		if err != nil {
+			continue
+		}
+		if s += shift; start <= s && s < end && (!ok || s < opening) {
+			opening, ok = s, true
+		}
+	}
+	return opening, ok
+}
+
+// formatClock formats minutes since midnight in "HH:MM" format, wrapping
+// around to the next day if needed.
+func formatClock(minute int) string {
+	minute = (minute%minutesPerDay + minutesPerDay) % minutesPerDay
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
@@ -0,0 +1,236 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestHoursValidate(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		hours   app.Hours
+		wantErr string
+	}{{
+		desc:  "valid intervals",
+		hours: app.Hours{"mon": {"11:30-14:30", "18:00-23:00"}},
+	}, {
+		desc:  "overnight interval",
+		hours: app.Hours{"fri": {"18:00-02:00"}},
+	}, {
+		desc:  "until midnight",
+		hours: app.Hours{"sat": {"18:00-24:00"}},
+	}, {
+		desc:    "invalid weekday",
+		hours:   app.Hours{"monday": {"11:30-14:30"}},
+		wantErr: `invalid weekday "monday"`,
+	}, {
+		desc:    "missing dash",
+		hours:   app.Hours{"mon": {"11:30"}},
+		wantErr: "not in HH:MM-HH:MM format",
+	}, {
+		desc:    "invalid start",
+		hours:   app.Hours{"mon": {"24:00-02:00"}},
+		wantErr: "invalid start time",
+	}, {
+		desc:    "invalid end",
+		hours:   app.Hours{"mon": {"11:30-14:60"}},
+		wantErr: "invalid end time",
+	}, {
+		desc:    "single digit hour",
+		hours:   app.Hours{"mon": {"9:00-14:00"}},
+		wantErr: "invalid start time",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.hours.Validate()
+			if !errorContains(err, test.wantErr) {
+				t.Errorf("Validate() err = %v, wantErr = %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	intervals := []string{"11:30-14:30", "18:00-02:00", "invalid"}
+
+	var tests = []struct {
+		desc  string
+		shift int
+		start int
+		end   int
+		want  bool
+	}{{
+		desc:  "overlaps interval",
+		start: 10 * 60,
+		end:   15 * 60,
+		want:  true,
+	}, {
+		desc:  "after closing",
+		start: 14*60 + 30,
+		end:   15 * 60,
+		want:  false,
+	}, {
+		desc:  "before opening",
+		start: 10 * 60,
+		end:   11*60 + 30,
+		want:  false,
+	}, {
+		desc:  "overnight interval",
+		start: 24 * 60,
+		end:   24*60 + 60,
+		want:  true,
+	}, {
+		desc:  "overnight interval from the previous day",
+		shift: -24 * 60,
+		start: 0,
+		end:   60,
+		want:  true,
+	}, {
+		desc:  "overnight interval ended on the next day",
+		shift: -24 * 60,
+		start: 2 * 60,
+		end:   10 * 60,
+		want:  false,
+	}, {
+		desc:  "interval of the next day",
+		shift: 24 * 60,
+		start: 22 * 60,
+		end:   24*60 + 12*60,
+		want:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := app.Overlaps(intervals, test.shift, test.start, test.end)
+			if got != test.want {
+				t.Errorf("Overlaps(%d, %d, %d) = %v, want %v", test.shift, test.start, test.end, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPeriodWindow(t *testing.T) {
+	var tests = []struct {
+		desc      string
+		bounds    [2]int
+		wantStart int
+		wantEnd   int
+	}{{
+		desc:      "same day",
+		bounds:    [2]int{10, 15},
+		wantStart: 600,
+		wantEnd:   900,
+	}, {
+		desc:      "until midnight",
+		bounds:    [2]int{15, 0},
+		wantStart: 900,
+		wantEnd:   1440,
+	}, {
+		desc:      "wraps around midnight",
+		bounds:    [2]int{22, 2},
+		wantStart: 1320,
+		wantEnd:   1560,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			start, end := app.PeriodWindow(test.bounds)
+			if start != test.wantStart || end != test.wantEnd {
+				t.Errorf("PeriodWindow(%v) = (%d, %d), want (%d, %d)", test.bounds, start, end, test.wantStart, test.wantEnd)
+			}
+		})
+	}
+}
+
+func TestTallyDataOpeningHours(t *testing.T) {
+	entries := []app.Entry{{
+		Name:  "Early Lunch",
+		Group: "Group",
+		Open:  map[string][]string{"mon": {"lunch"}},
+		Hours: app.Hours{"mon": {"11:30-14:30"}},
+		Cost:  1,
+	}, {
+		Name:  "Late Lunch",
+		Group: "Group",
+		Hours: app.Hours{"mon": {"12:00-16:00"}},
+		Cost:  1,
+	}, {
+		Name:  "Night Owl",
+		Group: "Group",
+		Hours: app.Hours{"sun": {"20:00-02:00"}},
+		Cost:  1,
+	}}
+
+	var tests = []struct {
+		desc       string
+		now        time.Time
+		date       time.Time
+		period     string
+		current    bool
+		wantClosed map[string]bool
+	}{{
+		desc:       "whole period",
+		now:        time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+		date:       testMonday,
+		period:     "lunch",
+		wantClosed: map[string]bool{"Early Lunch": false, "Late Lunch": false, "Night Owl": true},
+	}, {
+		desc:       "current period after an entry closes",
+		now:        time.Date(2024, 1, 1, 14, 40, 0, 0, time.UTC), // Monday.
+		date:       testMonday,
+		period:     "lunch",
+		current:    true,
+		wantClosed: map[string]bool{"Early Lunch": true, "Late Lunch": false, "Night Owl": true},
+	}, {
+		desc:       "current period before an entry closes",
+		now:        time.Date(2024, 1, 1, 14, 20, 0, 0, time.UTC), // Monday.
+		date:       testMonday,
+		period:     "lunch",
+		current:    true,
+		wantClosed: map[string]bool{"Early Lunch": false, "Late Lunch": false, "Night Owl": true},
+	}, {
+		desc:       "overnight hours from the previous day",
+		now:        time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), // Monday.
+		date:       testMonday,
+		period:     "breakfast",
+		current:    true,
+		wantClosed: map[string]bool{"Early Lunch": true, "Late Lunch": true, "Night Owl": true},
+	}, {
+		desc:       "overnight hours from a different weekday",
+		now:        time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC),
+		date:       testMonday.AddDate(0, 0, 1),
+		period:     "breakfast",
+		wantClosed: map[string]bool{"Early Lunch": true, "Late Lunch": true, "Night Owl": true},
+	}, {
+		desc:       "overnight hours in the period",
+		now:        time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+		date:       testMonday,
+		period:     "breakfast",
+		wantClosed: map[string]bool{"Early Lunch": true, "Late Lunch": true, "Night Owl": false},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a, err := app.New(app.Params{
+				Entries:  entries,
+				People:   map[string]string{"alice": "t1"},
+				Timezone: time.UTC,
+				Periods:  testPeriods(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.SetNowFunc(func() time.Time { return test.now })
+
+			groups := a.TallyData(test.date, test.period, test.current)
+			for _, e := range groups[0].Entries {
+				if e.Closed != test.wantClosed[e.Name] {
+					t.Errorf("%s: Closed = %v, want %v", e.Name, e.Closed, test.wantClosed[e.Name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// icalTimeFormat is the format of UTC date-times in iCalendar (RFC 5545).
+const icalTimeFormat = "20060102T150405Z"
+
+// icalEscape escapes text values in iCalendar content lines.
+var icalEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// icalFold folds an iCalendar content line into lines of at most 75 octets,
+// without splitting UTF-8 sequences.
+func icalFold(line string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// planTimes returns the start and end times of plan, using the hours of its
+// period in the App's timezone. It returns false if the date or period of the
+// plan are not valid. The caller must hold the read lock.
+func (a *App) planTimes(plan Plan) (time.Time, time.Time, bool) {
+	date, err := a.parseDate(plan.Date)
+	if _, ok := a.db.Periods[plan.Period]; err != nil || !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	start, end := a.periodInstants(a.db.Periods, date, plan.Period)
+	return start, end, true
+}
+
+// calendar returns the plans person can see for the entries selected by
+// filter as an iCalendar (RFC 5545) document, with an event for each plan.
+func (a *App) calendar(person string, filter tagFilter) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	plans := slices.Clone(a.db.Plans)
+	slices.SortFunc(plans, func(x, y Plan) int {
+		return cmp.Or(cmp.Compare(x.Date, y.Date),
+			cmp.Compare(a.db.Periods[x.Period][0], a.db.Periods[y.Period][0]))
+	})
+
+	stamp := a.clock.Now().UTC().Format(icalTimeFormat)
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		b.WriteString(icalFold(fmt.Sprintf(format, args...)))
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//alnvdl//anything//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Anything")
+	for _, plan := range plans {
+		start, end, ok := a.planTimes(plan)
+		if !ok || !a.planVisible(person, plan) || !slices.ContainsFunc(a.db.Entries, func(e Entry) bool {
+			return e.Group == plan.Group && e.Name == plan.Name && filter.match(e.Tags)
+		}) {
+			continue
+		}
+		line("BEGIN:VEVENT")
+		line("UID:%s/%s@anything%s", plan.Date, url.PathEscape(plan.Period), a.basePath)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART:%s", start.UTC().Format(icalTimeFormat))
+		line("DTEND:%s", end.UTC().Format(icalTimeFormat))
+		line("SUMMARY:%s", icalEscape.Replace(title(plan.Period)+": "+plan.Name))
+		line("LOCATION:%s", icalEscape.Replace(plan.Group))
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// handleCalendar serves an iCalendar feed with the plans, for subscribing
+// from calendar apps. It takes the same tag filter as the pages.
+func (a *App) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(a.calendar(person, parseTagFilter(r.URL.Query()))))
+}
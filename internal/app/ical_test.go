@@ -0,0 +1,125 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestIcalFold(t *testing.T) {
+	var tests = []struct {
+		desc string
+		line string
+		want string
+	}{{
+		desc: "short line",
+		line: "SUMMARY:Lunch",
+		want: "SUMMARY:Lunch\r\n",
+	}, {
+		desc: "long line",
+		line: "SUMMARY:" + strings.Repeat("a", 70),
+		want: "SUMMARY:" + strings.Repeat("a", 67) + "\r\n " + strings.Repeat("a", 3) + "\r\n",
+	}, {
+		desc: "multi-byte characters are not split",
+		line: strings.Repeat("a", 74) + "é",
+		want: strings.Repeat("a", 74) + "\r\n é\r\n",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := app.IcalFold(test.line); got != test.want {
+				t.Errorf("IcalFold(%q) = %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHandleCalendar(t *testing.T) {
+	tz, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := append(testEntries(), app.Entry{
+		Name:  "Bar, Grill; Co",
+		Group: "Uptown",
+		Open:  map[string][]string{"mon": {"dinner"}},
+		Cost:  1,
+	})
+	a, err := app.New(app.Params{
+		Entries:  entries,
+		People:   testPeople(),
+		Timezone: tz,
+		Periods:  testPeriods(),
+		BasePath: "/s/family",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetNowFunc(func() time.Time {
+		return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	})
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Bar, Grill; Co"})
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "lunch", Group: "Downtown", Name: "Pizza Place"})
+
+	var tests = []struct {
+		desc       string
+		token      string
+		wantStatus int
+		want       string
+	}{{
+		desc:       "valid token",
+		token:      "tokenA",
+		wantStatus: http.StatusOK,
+		want: "BEGIN:VCALENDAR\r\n" +
+			"VERSION:2.0\r\n" +
+			"PRODID:-//alnvdl//anything//EN\r\n" +
+			"CALSCALE:GREGORIAN\r\n" +
+			"X-WR-CALNAME:Anything\r\n" +
+			"BEGIN:VEVENT\r\n" +
+			"UID:2024-01-01/lunch@anything/s/family\r\n" +
+			"DTSTAMP:20240101T120000Z\r\n" +
+			"DTSTART:20240101T130000Z\r\n" +
+			"DTEND:20240101T180000Z\r\n" +
+			"SUMMARY:Lunch: Pizza Place\r\n" +
+			"LOCATION:Downtown\r\n" +
+			"END:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\n" +
+			"UID:2024-01-01/dinner@anything/s/family\r\n" +
+			"DTSTAMP:20240101T120000Z\r\n" +
+			"DTSTART:20240101T180000Z\r\n" +
+			"DTEND:20240102T030000Z\r\n" +
+			"SUMMARY:Dinner: Bar\\, Grill\\; Co\r\n" +
+			"LOCATION:Uptown\r\n" +
+			"END:VEVENT\r\n" +
+			"END:VCALENDAR\r\n",
+	}, {
+		desc:       "invalid token",
+		token:      "bad",
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/plans.ics?token="+test.token, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want text/calendar", ct)
+			}
+			if got := w.Body.String(); got != test.want {
+				t.Errorf("body = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
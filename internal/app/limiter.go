@@ -0,0 +1,183 @@
+package app
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default values for AuthLimiterParams.
+const (
+	DefaultAuthMaxFailures      = 5
+	DefaultAuthBlockDuration    = time.Minute
+	DefaultAuthMaxBlockDuration = time.Hour
+)
+
+// AuthLimiterParams contains all parameters needed to create an AuthLimiter.
+// Zero values are replaced by sensible defaults.
+type AuthLimiterParams struct {
+	// MaxFailures is the number of failed authentication attempts after
+	// which a client gets blocked. Successful attempts do not reset the
+	// count, so that a client with a valid token for one App cannot use it
+	// to keep guessing the tokens of others. Defaults to 5.
+	MaxFailures int
+
+	// BlockDuration is how long a client is blocked after exceeding
+	// MaxFailures. It doubles with every further failure. Defaults to 1
+	// minute.
+	BlockDuration time.Duration
+
+	// MaxBlockDuration caps the exponential backoff. A client's failures are
+	// also forgotten after this long without a new failure. Defaults to 1
+	// hour.
+	MaxBlockDuration time.Duration
+
+	// TrustForwardedFor makes the limiter identify clients by the last
+	// address in the X-Forwarded-For header, which should only be enabled
+	// when running behind a reverse proxy that sets it (e.g., Azure App
+	// Service).
+	TrustForwardedFor bool
+
+	// Logger is used for reporting blocked clients. Defaults to
+	// slog.Default().
+	Logger *slog.Logger
+}
+
+// authFailures tracks the failed authentication attempts of a client.
+type authFailures struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// AuthLimiter throttles clients that repeatedly fail to authenticate, using
+// an exponential backoff per IP address. Failures are only forgotten after
+// the maximum block duration without a new one. It is safe for concurrent use and
+// may be shared by several Apps.
+type AuthLimiter struct {
+	maxFailures       int
+	blockDuration     time.Duration
+	maxBlockDuration  time.Duration
+	trustForwardedFor bool
+	logger            *slog.Logger
+	nowFunc           func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*authFailures
+}
+
+// NewAuthLimiter creates a new AuthLimiter with the given parameters.
+func NewAuthLimiter(params AuthLimiterParams) *AuthLimiter {
+	l := &AuthLimiter{
+		maxFailures:       params.MaxFailures,
+		blockDuration:     params.BlockDuration,
+		maxBlockDuration:  params.MaxBlockDuration,
+		trustForwardedFor: params.TrustForwardedFor,
+		logger:            params.Logger,
+		nowFunc:           time.Now,
+		clients:           make(map[string]*authFailures),
+	}
+	if l.maxFailures <= 0 {
+		l.maxFailures = DefaultAuthMaxFailures
+	}
+	if l.blockDuration <= 0 {
+		l.blockDuration = DefaultAuthBlockDuration
+	}
+	if l.maxBlockDuration <= 0 {
+		l.maxBlockDuration = DefaultAuthMaxBlockDuration
+	}
+	if l.logger == nil {
+		l.logger = slog.Default()
+	}
+	return l
+}
+
+// clientIP returns the address identifying the client making the request.
+func (l *AuthLimiter) clientIP(r *http.Request) string {
+	if l.trustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			return stripPort(strings.TrimSpace(parts[len(parts)-1]))
+		}
+	}
+	return stripPort(r.RemoteAddr)
+}
+
+// stripPort removes the port from addr, if there is one.
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// blocked returns how long the client at ip must still wait before being
+// allowed to authenticate again, or zero if it is not blocked.
+func (l *AuthLimiter) blocked(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.clients[ip]
+	if !ok {
+		return 0
+	}
+	return max(f.blockedUntil.Sub(l.nowFunc()), 0)
+}
+
+// fail records a failed authentication attempt for the client at ip. It
+// returns how long the client is now blocked for, or zero if it is not.
+func (l *AuthLimiter) fail(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.nowFunc()
+	l.prune(now)
+
+	f, ok := l.clients[ip]
+	if !ok {
+		f = &authFailures{}
+		l.clients[ip] = f
+	}
+	f.count++
+	f.lastFailure = now
+	if f.count < l.maxFailures {
+		return 0
+	}
+
+	// Double the block duration for every failure beyond the limit.
+	d := l.blockDuration
+	for range f.count - l.maxFailures {
+		if d >= l.maxBlockDuration {
+			break
+		}
+		d *= 2
+	}
+	d = min(d, l.maxBlockDuration)
+	f.blockedUntil = now.Add(d)
+	l.logger.Warn("blocking client after failed authentication attempts",
+		slog.String("ip", ip),
+		slog.Int("failures", f.count),
+		slog.Duration("duration", d))
+	return d
+}
+
+// prune forgets clients that have not failed for longer than the maximum
+// block duration. The caller must hold the lock.
+func (l *AuthLimiter) prune(now time.Time) {
+	for ip, f := range l.clients {
+		if now.Sub(f.lastFailure) > l.maxBlockDuration && !now.Before(f.blockedUntil) {
+			delete(l.clients, ip)
+		}
+	}
+}
+
+// tooManyRequests writes a 429 response telling the client to retry after d.
+func tooManyRequests(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+}
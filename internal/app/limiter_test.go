@@ -0,0 +1,113 @@
+package app_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+// newTestLimiter creates an AuthLimiter with a fixed clock for testing. The
+// returned function advances the clock.
+func newTestLimiter(params app.AuthLimiterParams) (*app.AuthLimiter, func(time.Duration)) {
+	params.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	l := app.NewAuthLimiter(params)
+	now := time.Date(2026, 2, 9, 12, 0, 0, 0, time.UTC)
+	l.SetNowFunc(func() time.Time { return now })
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestAuthLimiterBackoff(t *testing.T) {
+	l, advance := newTestLimiter(app.AuthLimiterParams{
+		MaxFailures:      3,
+		BlockDuration:    time.Minute,
+		MaxBlockDuration: 5 * time.Minute,
+	})
+
+	// The first failures are tolerated.
+	for i := range 2 {
+		if d := l.Fail("1.2.3.4"); d != 0 {
+			t.Fatalf("failure %d blocked client for %v, want 0", i+1, d)
+		}
+	}
+
+	// Reaching the limit blocks the client, doubling the block duration for
+	// every further failure until the maximum.
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if d := l.Fail("1.2.3.4"); d != want {
+			t.Fatalf("Fail() blocked client for %v, want %v", d, want)
+		}
+		if d := l.Blocked("1.2.3.4"); d != want {
+			t.Fatalf("Blocked() = %v, want %v", d, want)
+		}
+		advance(want)
+		if d := l.Blocked("1.2.3.4"); d != 0 {
+			t.Fatalf("Blocked() after waiting = %v, want 0", d)
+		}
+	}
+
+	// Other clients are not affected.
+	if d := l.Blocked("5.6.7.8"); d != 0 {
+		t.Errorf("Blocked() for another client = %v, want 0", d)
+	}
+}
+
+func TestAuthLimiterForgetsOldFailures(t *testing.T) {
+	l, advance := newTestLimiter(app.AuthLimiterParams{
+		MaxFailures:      2,
+		MaxBlockDuration: time.Hour,
+	})
+
+	l.Fail("1.2.3.4")
+	advance(2 * time.Hour)
+	if d := l.Fail("1.2.3.4"); d != 0 {
+		t.Errorf("Fail() after a long pause blocked client for %v, want 0", d)
+	}
+}
+
+func TestAuthLimiterClientIP(t *testing.T) {
+	var tests = []struct {
+		desc              string
+		trustForwardedFor bool
+		remoteAddr        string
+		forwardedFor      string
+		want              string
+	}{{
+		desc:       "remote address",
+		remoteAddr: "1.2.3.4:5678",
+		want:       "1.2.3.4",
+	}, {
+		desc:         "forwarded for is ignored by default",
+		remoteAddr:   "1.2.3.4:5678",
+		forwardedFor: "9.9.9.9",
+		want:         "1.2.3.4",
+	}, {
+		desc:              "last forwarded for address when trusted",
+		trustForwardedFor: true,
+		remoteAddr:        "1.2.3.4:5678",
+		forwardedFor:      "8.8.8.8, 9.9.9.9:1234",
+		want:              "9.9.9.9",
+	}, {
+		desc:              "remote address when trusted but header missing",
+		trustForwardedFor: true,
+		remoteAddr:        "[::1]:5678",
+		want:              "::1",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			l, _ := newTestLimiter(app.AuthLimiterParams{TrustForwardedFor: test.trustForwardedFor})
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			if got := l.ClientIP(req); got != test.want {
+				t.Errorf("ClientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// osmDays lists the weekday abbreviations of the OpenStreetMap opening_hours
+// syntax, in the order it uses, starting on Monday.
+var osmDays = []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"}
+
+// osmHoliday is the selector for public holidays in the opening_hours syntax.
+const osmHoliday = "PH"
+
+// osmAllDay is the interval of entries open all day.
+const osmAllDay = "00:00-24:00"
+
+// osmDayKey returns the Hours key for the day at index i of osmDays.
+func osmDayKey(i int) string {
+	return weekdays[time.Weekday((i+1)%7)].Short
+}
+
+// parseOpeningHours parses a subset of the OpenStreetMap opening_hours syntax
+// into Hours. An expression is a list of rules separated by ";", each with an
+// optional selector of weekdays (e.g., "Mo-Fr,Su") or "PH" for holidays,
+// followed by a comma-separated list of intervals, or "off" if closed (e.g.,
+// "Mo-Fr 11:30-14:30,18:00-22:00; Sa 12:00-23:00; PH off"). Rules without a
+// selector apply to every weekday, and rules without intervals are open all
+// day. Later rules replace earlier ones for the days they select. The
+// expression "24/7" is open all the time.
+func parseOpeningHours(s string) (Hours, error) {
+	hours := make(Hours)
+	var rules int
+	for rule := range strings.SplitSeq(s, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		rules++
+		if rule == "24/7" {
+			for i := range osmDays {
+				hours[osmDayKey(i)] = []string{osmAllDay}
+			}
+			continue
+		}
+		if err := parseOpeningHoursRule(rule, hours); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule, err)
+		}
+	}
+	if rules == 0 {
+		return nil, errors.New("no rules")
+	}
+	return hours, nil
+}
+
+// parseOpeningHoursRule parses a rule of an opening_hours expression into
+// hours, replacing the intervals of the days it selects.
+func parseOpeningHoursRule(rule string, hours Hours) error {
+	var keys []string
+	spec := rule
+	if selector, rest, _ := strings.Cut(rule, " "); isOpeningHoursSelector(selector) {
+		var err error
+		if keys, err = parseOpeningHoursSelector(selector); err != nil {
+			return err
+		}
+		spec = rest
+	} else {
+		for i := range osmDays {
+			keys = append(keys, osmDayKey(i))
+		}
+	}
+
+	// Intervals may be separated by spaces after their commas.
+	spec = strings.Join(strings.Fields(spec), "")
+	var intervals []string
+	switch strings.ToLower(spec) {
+	case "off", "closed":
+		intervals = []string{}
+	case "":
+		intervals = []string{osmAllDay}
+	default:
+		for interval := range strings.SplitSeq(spec, ",") {
+			if _, _, err := parseInterval(interval); err != nil {
+				return err
+			}
+			intervals = append(intervals, interval)
+		}
+	}
+
+	for _, key := range keys {
+		if len(intervals) == 0 && key != holidayKey {
+			// Closed weekdays have no intervals, while holidays keep an
+			// empty list to tell they do not follow the weekday schedule.
+			delete(hours, key)
+			continue
+		}
+		hours[key] = intervals
+	}
+	return nil
+}
+
+// isOpeningHoursSelector reports whether s starts with a weekday or holiday
+// selector rather than with intervals.
+func isOpeningHoursSelector(s string) bool {
+	if s == "" || strings.EqualFold(s, "off") || strings.EqualFold(s, "closed") {
+		return false
+	}
+	return s[0] >= 'A' && s[0] <= 'Z' || s[0] >= 'a' && s[0] <= 'z'
+}
+
+// parseOpeningHoursSelector parses a comma-separated list of weekdays,
+// weekday ranges and holidays into Hours keys.
+func parseOpeningHoursSelector(selector string) ([]string, error) {
+	var keys []string
+	for item := range strings.SplitSeq(selector, ",") {
+		if item == osmHoliday {
+			keys = append(keys, holidayKey)
+			continue
+		}
+		fromStr, toStr, isRange := strings.Cut(item, "-")
+		from := slices.Index(osmDays, fromStr)
+		to := from
+		if isRange {
+			to = slices.Index(osmDays, toStr)
+		}
+		if from < 0 || to < 0 {
+			return nil, fmt.Errorf("invalid day selector %q", item)
+		}
+		// Ranges may wrap around the end of the week (e.g., "Fr-Mo").
+		for i := from; ; i = (i + 1) % len(osmDays) {
+			keys = append(keys, osmDayKey(i))
+			if i == to {
+				break
+			}
+		}
+	}
+	return keys, nil
+}
+
+// formatOpeningHours formats hours in the opening_hours syntax accepted by
+// parseOpeningHours, joining days with the same intervals in a single rule.
+func formatOpeningHours(h Hours) string {
+	allDay := len(h) == len(osmDays)
+	for i := range osmDays {
+		allDay = allDay && slices.Equal(h[osmDayKey(i)], []string{osmAllDay})
+	}
+	if allDay {
+		return "24/7"
+	}
+
+	// Group days by their intervals, in the order of their first day.
+	var specs []string
+	days := make(map[string][]int)
+	for i := range osmDays {
+		intervals := h[osmDayKey(i)]
+		if len(intervals) == 0 {
+			continue
+		}
+		spec := strings.Join(intervals, ",")
+		if _, ok := days[spec]; !ok {
+			specs = append(specs, spec)
+		}
+		days[spec] = append(days[spec], i)
+	}
+
+	var rules []string
+	for _, spec := range specs {
+		rules = append(rules, formatOpeningHoursDays(days[spec])+" "+spec)
+	}
+	if intervals, ok := h[holidayKey]; ok {
+		if len(intervals) == 0 {
+			rules = append(rules, osmHoliday+" off")
+		} else {
+			rules = append(rules, osmHoliday+" "+strings.Join(intervals, ","))
+		}
+	}
+	return strings.Join(rules, "; ")
+}
+
+// formatOpeningHoursDays formats sorted indexes of osmDays as a selector,
+// using ranges for three or more consecutive days (e.g., "Mo-We,Sa,Su").
+func formatOpeningHoursDays(indexes []int) string {
+	var items []string
+	for i := 0; i < len(indexes); {
+		j := i
+		for j+1 < len(indexes) && indexes[j+1] == indexes[j]+1 {
+			j++
+		}
+		switch {
+		case j-i >= 2:
+			items = append(items, osmDays[indexes[i]]+"-"+osmDays[indexes[j]])
+		case j > i:
+			items = append(items, osmDays[indexes[i]], osmDays[indexes[j]])
+		default:
+			items = append(items, osmDays[indexes[i]])
+		}
+		i = j + 1
+	}
+	return strings.Join(items, ",")
+}
+
+// openingHoursResult is the response of the opening hours parsing endpoint.
+type openingHoursResult struct {
+	Hours Hours  `json:"hours,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// handleOpeningHours parses the opening_hours expression in the "expr" query
+// parameter, responding with the resulting hours, or with the parsing error.
+// It is used by the edit page to validate expressions as they are typed.
+func (a *App) handleOpeningHours(w http.ResponseWriter, r *http.Request) {
+	_, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var result openingHoursResult
+	hours, err := parseOpeningHours(r.URL.Query().Get("expr"))
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Hours = hours
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
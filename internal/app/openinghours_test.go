@@ -0,0 +1,244 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestParseOpeningHours(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		expr    string
+		want    app.Hours
+		wantErr string
+	}{{
+		desc: "weekday range",
+		expr: "Mo-Fr 11:30-14:30,18:00-22:00",
+		want: app.Hours{
+			"mon": {"11:30-14:30", "18:00-22:00"},
+			"tue": {"11:30-14:30", "18:00-22:00"},
+			"wed": {"11:30-14:30", "18:00-22:00"},
+			"thu": {"11:30-14:30", "18:00-22:00"},
+			"fri": {"11:30-14:30", "18:00-22:00"},
+		},
+	}, {
+		desc: "several rules",
+		expr: "Mo,We 12:00-15:00; Sa 18:00-02:00; PH off",
+		want: app.Hours{
+			"mon": {"12:00-15:00"},
+			"wed": {"12:00-15:00"},
+			"sat": {"18:00-02:00"},
+			"hol": {},
+		},
+	}, {
+		desc: "range wrapping around the week",
+		expr: "Fr-Mo 19:00-23:00",
+		want: app.Hours{
+			"fri": {"19:00-23:00"},
+			"sat": {"19:00-23:00"},
+			"sun": {"19:00-23:00"},
+			"mon": {"19:00-23:00"},
+		},
+	}, {
+		desc: "later rules override earlier ones",
+		expr: "11:00-22:00; Su off; Sa 12:00-14:00",
+		want: app.Hours{
+			"mon": {"11:00-22:00"},
+			"tue": {"11:00-22:00"},
+			"wed": {"11:00-22:00"},
+			"thu": {"11:00-22:00"},
+			"fri": {"11:00-22:00"},
+			"sat": {"12:00-14:00"},
+		},
+	}, {
+		desc: "spaces between intervals and trailing separator",
+		expr: " Tu 11:00-14:00, 18:00-22:00 ; PH 12:00-16:00; ",
+		want: app.Hours{
+			"tue": {"11:00-14:00", "18:00-22:00"},
+			"hol": {"12:00-16:00"},
+		},
+	}, {
+		desc: "open all day",
+		expr: "Sa,Su",
+		want: app.Hours{
+			"sat": {"00:00-24:00"},
+			"sun": {"00:00-24:00"},
+		},
+	}, {
+		desc: "always open",
+		expr: "24/7",
+		want: app.Hours{
+			"mon": {"00:00-24:00"},
+			"tue": {"00:00-24:00"},
+			"wed": {"00:00-24:00"},
+			"thu": {"00:00-24:00"},
+			"fri": {"00:00-24:00"},
+			"sat": {"00:00-24:00"},
+			"sun": {"00:00-24:00"},
+		},
+	}, {
+		desc:    "empty expression",
+		expr:    " ; ",
+		wantErr: "no rules",
+	}, {
+		desc:    "invalid day",
+		expr:    "Mo-Fx 11:00-14:00",
+		wantErr: `rule "Mo-Fx 11:00-14:00": invalid day selector "Mo-Fx"`,
+	}, {
+		desc:    "invalid interval",
+		expr:    "Mo 11:00-14:00; Tu 11:00",
+		wantErr: `rule "Tu 11:00": interval "11:00" is not in HH:MM-HH:MM format`,
+	}, {
+		desc:    "invalid time",
+		expr:    "Mo 11:00-25:00",
+		wantErr: "has an invalid end time",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := app.ParseOpeningHours(test.expr)
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("ParseOpeningHours(%q) err = %v, wantErr = %q", test.expr, err, test.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseOpeningHours(%q) = %v, want %v", test.expr, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatOpeningHours(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		hours app.Hours
+		want  string
+	}{{
+		desc: "no hours",
+		want: "",
+	}, {
+		desc: "ranges and single days",
+		hours: app.Hours{
+			"mon": {"11:30-14:30"},
+			"tue": {"11:30-14:30"},
+			"wed": {"11:30-14:30"},
+			"fri": {"11:30-14:30"},
+			"sat": {"18:00-02:00"},
+			"sun": {"18:00-02:00"},
+		},
+		want: "Mo-We,Fr 11:30-14:30; Sa,Su 18:00-02:00",
+	}, {
+		desc: "holidays",
+		hours: app.Hours{
+			"sun": {"12:00-15:00", "19:00-23:00"},
+			"hol": {},
+		},
+		want: "Su 12:00-15:00,19:00-23:00; PH off",
+	}, {
+		desc: "holiday hours",
+		hours: app.Hours{
+			"thu": {"12:00-15:00"},
+			"hol": {"12:00-14:00"},
+		},
+		want: "Th 12:00-15:00; PH 12:00-14:00",
+	}, {
+		desc: "always open",
+		hours: app.Hours{
+			"mon": {"00:00-24:00"},
+			"tue": {"00:00-24:00"},
+			"wed": {"00:00-24:00"},
+			"thu": {"00:00-24:00"},
+			"fri": {"00:00-24:00"},
+			"sat": {"00:00-24:00"},
+			"sun": {"00:00-24:00"},
+		},
+		want: "24/7",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := app.FormatOpeningHours(test.hours)
+			if got != test.want {
+				t.Errorf("FormatOpeningHours(%v) = %q, want %q", test.hours, got, test.want)
+			}
+			if test.want == "" {
+				return
+			}
+
+			// Formatted hours are parsed back into the same hours.
+			parsed, err := app.ParseOpeningHours(got)
+			if err != nil {
+				t.Fatalf("ParseOpeningHours(%q) err = %v", got, err)
+			}
+			if !reflect.DeepEqual(parsed, test.hours) {
+				t.Errorf("ParseOpeningHours(%q) = %v, want %v", got, parsed, test.hours)
+			}
+		})
+	}
+}
+
+func TestHandleOpeningHours(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		token      string
+		expr       string
+		wantStatus int
+		wantHours  app.Hours
+		wantErr    string
+	}{{
+		desc:       "valid expression",
+		token:      "tokenA",
+		expr:       "Mo-We 11:00-14:00; PH off",
+		wantStatus: http.StatusOK,
+		wantHours: app.Hours{
+			"mon": {"11:00-14:00"},
+			"tue": {"11:00-14:00"},
+			"wed": {"11:00-14:00"},
+			"hol": {},
+		},
+	}, {
+		desc:       "invalid expression",
+		token:      "tokenA",
+		expr:       "Mo 11:00",
+		wantStatus: http.StatusBadRequest,
+		wantErr:    `rule "Mo 11:00": interval "11:00" is not in HH:MM-HH:MM format`,
+	}, {
+		desc:       "invalid token",
+		token:      "bad",
+		expr:       "Mo 11:00-14:00",
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			u := "/opening-hours.json?token=" + test.token + "&expr=" + url.QueryEscape(test.expr)
+			req := httptest.NewRequest("GET", u, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if w.Code == http.StatusForbidden {
+				return
+			}
+
+			var result struct {
+				Hours app.Hours `json:"hours"`
+				Error string    `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Hours, test.wantHours) || result.Error != test.wantErr {
+				t.Errorf("result = %+v, want hours %v and error %q", result, test.wantHours, test.wantErr)
+			}
+		})
+	}
+}
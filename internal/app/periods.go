@@ -0,0 +1,252 @@
+package app
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// invalidPeriodChars are the characters that cannot be used in period names,
+// as they separate periods in the forms of the edit page.
+const invalidPeriodChars = ",;:=|"
+
+// Validate checks that every period has a valid name and hours, and that no
+// hour is covered by more than one period.
+func (p Periods) Validate() error {
+	if len(p) == 0 {
+		return errors.New("at least one period is required")
+	}
+	seen := make(map[int]string)
+	for _, period := range slices.Sorted(maps.Keys(p)) {
+		if strings.TrimSpace(period) == "" || strings.ContainsAny(period, invalidPeriodChars) {
+			return fmt.Errorf("period name %q must not be empty or contain any of %q", period, invalidPeriodChars)
+		}
+		start, end := p[period][0], p[period][1]
+		if start < 0 || start > 23 || end < 0 || end > 23 {
+			return fmt.Errorf("period %q hours must be between 0 and 23", period)
+		}
+		if start == end {
+			return fmt.Errorf("period %q has equal start and end hour", period)
+		}
+		for _, h := range hoursForPeriod(start, end) {
+			if other, ok := seen[h]; ok {
+				return fmt.Errorf("hour %d overlaps between %q and %q", h, other, period)
+			}
+			seen[h] = period
+		}
+	}
+	return nil
+}
+
+// hoursForPeriod returns the list of hours covered by a period [start, end).
+func hoursForPeriod(start, end int) []int {
+	var hours []int
+	if start < end {
+		for h := start; h < end; h++ {
+			hours = append(hours, h)
+		}
+	} else {
+		// Wraps around midnight.
+		for h := start; h < 24; h++ {
+			hours = append(hours, h)
+		}
+		for h := range end {
+			hours = append(hours, h)
+		}
+	}
+	return hours
+}
+
+// setPeriods replaces the periods and rebuilds the period list, sorted by
+// start time for consistent display. The caller must hold the write lock.
+func (a *App) setPeriods(periods Periods) {
+	a.db.Periods = periods
+	a.periodList = slices.SortedFunc(maps.Keys(periods), func(x, y string) int {
+		return cmp.Compare(periods[x][0], periods[y][0])
+	})
+}
+
+// periodConfig returns the current periods and the period list.
+func (a *App) periodConfig() (Periods, []string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.db.Periods, a.periodList
+}
+
+// updatePeriods validates and replaces the periods. The renames map old period
+// names to new ones, and they are applied to the schedules of every entry and
+// suggestion and to the plans. Periods that no longer exist are removed from the schedules,
+// along with their plans.
+func (a *App) updatePeriods(periods Periods, renames map[string]string) error {
+	if err := periods.Validate(); err != nil {
+		return err
+	}
+
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rename := func(names []string) []string {
+		var result []string
+		for _, name := range names {
+			if newName, ok := renames[name]; ok {
+				name = newName
+			}
+			if _, ok := periods[name]; ok && !slices.Contains(result, name) {
+				result = append(result, name)
+			}
+		}
+		return result
+	}
+	renameEntry := func(e Entry) Entry {
+		open := make(map[string][]string, len(e.Open))
+		for day, names := range e.Open {
+			if names := rename(names); len(names) > 0 {
+				open[day] = names
+			}
+		}
+		e.Open = open
+
+		e.Exceptions = slices.Clone(e.Exceptions)
+		for j, ex := range e.Exceptions {
+			ex.Open = rename(ex.Open)
+			e.Exceptions[j] = ex
+		}
+		return e
+	}
+
+	entries := slices.Clone(a.db.Entries)
+	for i, e := range entries {
+		entries[i] = renameEntry(e)
+	}
+	a.db.Entries = entries
+
+	suggestions := slices.Clone(a.db.Suggestions)
+	for i, s := range suggestions {
+		suggestions[i].Entry = renameEntry(s.Entry)
+	}
+	a.db.Suggestions = suggestions
+
+	var plans []Plan
+	for _, plan := range a.db.Plans {
+		if newName, ok := renames[plan.Period]; ok {
+			plan.Period = newName
+		}
+		if _, ok := periods[plan.Period]; ok {
+			plans = append(plans, plan)
+		}
+	}
+	a.db.Plans = plans
+
+	a.setPeriods(periods)
+	return nil
+}
+
+// periodRow holds a period for rendering the settings page.
+type periodRow struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// handleSettingsGet serves the settings page.
+func (a *App) handleSettingsGet(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	a.renderSettings(w, r, person, "")
+}
+
+// renderSettings renders the settings page, with an optional error message.
+func (a *App) renderSettings(w http.ResponseWriter, r *http.Request, person, message string) {
+	token := r.URL.Query().Get("token")
+	periods, periodList := a.periodConfig()
+
+	var rows []periodRow
+	for _, name := range periodList {
+		rows = append(rows, periodRow{Name: name, Start: periods[name][0], End: periods[name][1]})
+	}
+
+	data := pageData{
+		Title:      "Anything",
+		BasePath:   a.basePath,
+		LoggedIn:   token == "",
+		Token:      token,
+		CSRFToken:  a.csrfToken(r, person),
+		Nonce:      requestNonce(r),
+		Periods:    periodList,
+		PeriodRows: rows,
+		Message:    message,
+		CanEdit:    a.canEdit(person),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := a.settingsTmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleSettingsPost handles settings form submission. Periods are sent as
+// parallel lists of their original names (empty for new periods), new names,
+// and start and end hours.
+func (a *App) handleSettingsPost(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !a.checkCSRF(w, r, person) || !a.checkEditor(w, person) {
+		return
+	}
+
+	oldNames := r.PostForm["period_old"]
+	names := r.PostForm["period_name"]
+	starts := r.PostForm["period_start"]
+	ends := r.PostForm["period_end"]
+	if len(names) != len(oldNames) || len(names) != len(starts) || len(names) != len(ends) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	periods := make(Periods)
+	renames := make(map[string]string)
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		start, errStart := strconv.Atoi(starts[i])
+		end, errEnd := strconv.Atoi(ends[i])
+		if errStart != nil || errEnd != nil {
+			a.renderSettings(w, r, person, fmt.Sprintf("Period %q has invalid hours.", name))
+			return
+		}
+		if _, ok := periods[name]; ok {
+			a.renderSettings(w, r, person, fmt.Sprintf("Period %q is defined more than once.", name))
+			return
+		}
+		periods[name] = [2]int{start, end}
+		if oldNames[i] != "" && oldNames[i] != name {
+			renames[oldNames[i]] = name
+		}
+	}
+
+	if err := a.updatePeriods(periods, renames); err != nil {
+		a.renderSettings(w, r, person, "Invalid periods: "+err.Error()+".")
+		return
+	}
+
+	http.Redirect(w, r, a.basePath+"/settings?token="+token, http.StatusSeeOther)
+}
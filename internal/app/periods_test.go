@@ -0,0 +1,376 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestPeriodsValidate(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		periods app.Periods
+		wantErr string
+	}{{
+		desc:    "valid periods",
+		periods: app.Periods{"lunch": {10, 15}, "dinner": {18, 2}},
+	}, {
+		desc:    "no periods",
+		periods: app.Periods{},
+		wantErr: "at least one period is required",
+	}, {
+		desc:    "overlapping periods",
+		periods: app.Periods{"lunch": {10, 15}, "snack": {14, 16}},
+		wantErr: `hour 14 overlaps between "lunch" and "snack"`,
+	}, {
+		desc:    "wrap-around overlap",
+		periods: app.Periods{"dinner": {18, 2}, "late": {1, 3}},
+		wantErr: "hour 1 overlaps between",
+	}, {
+		desc:    "equal start and end",
+		periods: app.Periods{"lunch": {10, 10}},
+		wantErr: `period "lunch" has equal start and end hour`,
+	}, {
+		desc:    "hour out of range",
+		periods: app.Periods{"lunch": {10, 24}},
+		wantErr: "hours must be between 0 and 23",
+	}, {
+		desc:    "empty name",
+		periods: app.Periods{" ": {10, 15}},
+		wantErr: "must not be empty",
+	}, {
+		desc:    "name with separator",
+		periods: app.Periods{"lunch,dinner": {10, 15}},
+		wantErr: "must not be empty or contain",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.periods.Validate()
+			if !errorContains(err, test.wantErr) {
+				t.Errorf("Validate() err = %v, wantErr = %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHoursForPeriod(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		start int
+		end   int
+		want  []int
+	}{{
+		desc:  "simple range",
+		start: 0,
+		end:   3,
+		want:  []int{0, 1, 2},
+	}, {
+		desc:  "wrap around midnight",
+		start: 22,
+		end:   2,
+		want:  []int{22, 23, 0, 1},
+	}, {
+		desc:  "single hour",
+		start: 5,
+		end:   6,
+		want:  []int{5},
+	}, {
+		desc:  "large range",
+		start: 0,
+		end:   10,
+		want:  []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := app.HoursForPeriod(test.start, test.end)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("HoursForPeriod(%d, %d) = %v, want %v", test.start, test.end, got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePeriods(t *testing.T) {
+	entries := []app.Entry{{
+		Name:  "Diner",
+		Group: "Group",
+		Open: map[string][]string{
+			"mon": {"breakfast", "lunch"},
+			"tue": {"dinner"},
+		},
+		Exceptions: []app.Exception{{
+			From: "2024-12-25",
+			Open: []string{"lunch", "dinner"},
+		}},
+		Cost: 1,
+	}}
+
+	var tests = []struct {
+		desc           string
+		periods        app.Periods
+		renames        map[string]string
+		wantErr        string
+		wantPeriods    []string
+		wantOpen       map[string][]string
+		wantExceptions []string
+	}{{
+		desc:           "rename",
+		periods:        app.Periods{"brunch": {0, 12}, "lunch": {12, 15}, "dinner": {15, 0}},
+		renames:        map[string]string{"breakfast": "brunch"},
+		wantPeriods:    []string{"brunch", "lunch", "dinner"},
+		wantOpen:       map[string][]string{"mon": {"brunch", "lunch"}, "tue": {"dinner"}},
+		wantExceptions: []string{"lunch", "dinner"},
+	}, {
+		desc:           "add",
+		periods:        app.Periods{"breakfast": {0, 10}, "lunch": {10, 15}, "dinner": {15, 22}, "late snack": {22, 0}},
+		wantPeriods:    []string{"breakfast", "lunch", "dinner", "late snack"},
+		wantOpen:       map[string][]string{"mon": {"breakfast", "lunch"}, "tue": {"dinner"}},
+		wantExceptions: []string{"lunch", "dinner"},
+	}, {
+		desc:           "delete",
+		periods:        app.Periods{"breakfast": {0, 10}, "lunch": {10, 0}},
+		wantPeriods:    []string{"breakfast", "lunch"},
+		wantOpen:       map[string][]string{"mon": {"breakfast", "lunch"}},
+		wantExceptions: []string{"lunch"},
+	}, {
+		desc:           "swap names",
+		periods:        app.Periods{"breakfast": {10, 15}, "lunch": {0, 10}, "dinner": {15, 0}},
+		renames:        map[string]string{"breakfast": "lunch", "lunch": "breakfast"},
+		wantPeriods:    []string{"lunch", "breakfast", "dinner"},
+		wantOpen:       map[string][]string{"mon": {"lunch", "breakfast"}, "tue": {"dinner"}},
+		wantExceptions: []string{"breakfast", "dinner"},
+	}, {
+		desc:           "invalid periods",
+		periods:        app.Periods{"breakfast": {0, 12}, "lunch": {10, 15}},
+		wantErr:        "overlaps between",
+		wantPeriods:    []string{"breakfast", "lunch", "dinner"},
+		wantOpen:       map[string][]string{"mon": {"breakfast", "lunch"}, "tue": {"dinner"}},
+		wantExceptions: []string{"lunch", "dinner"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t, entries...)
+
+			err := a.UpdatePeriods(test.periods, test.renames)
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("UpdatePeriods() err = %v, wantErr = %q", err, test.wantErr)
+			}
+
+			if _, periodList := a.PeriodConfig(); !reflect.DeepEqual(periodList, test.wantPeriods) {
+				t.Errorf("periods = %v, want %v", periodList, test.wantPeriods)
+			}
+			got := a.Entries()[0]
+			if !reflect.DeepEqual(got.Open, test.wantOpen) {
+				t.Errorf("Open = %v, want %v", got.Open, test.wantOpen)
+			}
+			if !reflect.DeepEqual(got.Exceptions[0].Open, test.wantExceptions) {
+				t.Errorf("Exceptions[0].Open = %v, want %v", got.Exceptions[0].Open, test.wantExceptions)
+			}
+		})
+	}
+}
+
+func TestUpdatePeriodsSuggestions(t *testing.T) {
+	a := newTestApp(t)
+	postSuggestions(a, "bob", url.Values{
+		"action": {"suggest"},
+		"name":   {"Noodle Cart"},
+		"group":  {"Uptown"},
+		"cost":   {"1"},
+		"open":   {"mon:breakfast", "mon:lunch", "tue:dinner"},
+	})
+
+	periods := app.Periods{"brunch": {0, 12}, "lunch": {12, 15}, "supper": {15, 0}}
+	if err := a.UpdatePeriods(periods, map[string]string{"breakfast": "brunch", "dinner": "supper"}); err != nil {
+		t.Fatal(err)
+	}
+	if w := postSuggestions(a, "alice", url.Values{"action": {"approve"}, "entry": {"Uptown|Noodle Cart"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("approval status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+
+	e, _ := findEntry(a.Entries(), "Uptown", "Noodle Cart")
+	if want := map[string][]string{"mon": {"brunch", "lunch"}, "tue": {"supper"}}; !reflect.DeepEqual(e.Open, want) {
+		t.Errorf("Open = %v, want %v", e.Open, want)
+	}
+}
+
+func TestPeriodsPersisted(t *testing.T) {
+	a := newTestApp(t)
+	if err := a.UpdatePeriods(app.Periods{"all day": {6, 22}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	if err := a.Save(&buf); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	// Periods loaded from the database take precedence over the ones the
+	// App was created with.
+	a2 := newTestApp(t)
+	if err := a2.Load(strings.NewReader(buf.String())); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	periods, periodList := a2.PeriodConfig()
+	if !reflect.DeepEqual(periods, app.Periods{"all day": {6, 22}}) || !reflect.DeepEqual(periodList, []string{"all day"}) {
+		t.Errorf("periods = %v (%v), want only \"all day\"", periods, periodList)
+	}
+}
+
+func TestHandleSettingsGet(t *testing.T) {
+	var tests = []struct {
+		desc         string
+		token        string
+		wantStatus   int
+		wantContains []string
+	}{{
+		desc:       "valid token",
+		token:      "tokenA",
+		wantStatus: http.StatusOK,
+		wantContains: []string{
+			`name="period_name" value="breakfast"`,
+			`name="period_start" value="10"`,
+			`name="period_end" value="15"`,
+		},
+	}, {
+		desc:       "invalid token",
+		token:      "bad",
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			req := httptest.NewRequest("GET", "/settings?token="+test.token, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			for _, want := range test.wantContains {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body does not contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleSettingsPost(t *testing.T) {
+	var tests = []struct {
+		desc         string
+		form         url.Values
+		wantStatus   int
+		wantContains string
+		wantPeriods  []string
+		wantOpen     []string
+	}{{
+		desc: "rename and add periods",
+		form: url.Values{
+			"period_old":   {"breakfast", "lunch", "dinner", ""},
+			"period_name":  {"brunch", "lunch", "dinner", " late snack "},
+			"period_start": {"0", "10", "15", "22"},
+			"period_end":   {"10", "15", "22", "0"},
+		},
+		wantStatus:  http.StatusSeeOther,
+		wantPeriods: []string{"brunch", "lunch", "dinner", "late snack"},
+		wantOpen:    []string{"lunch", "dinner"},
+	}, {
+		desc: "delete period",
+		form: url.Values{
+			"period_old":   {"breakfast", "dinner"},
+			"period_name":  {"breakfast", "dinner"},
+			"period_start": {"0", "15"},
+			"period_end":   {"10", "0"},
+		},
+		wantStatus:  http.StatusSeeOther,
+		wantPeriods: []string{"breakfast", "dinner"},
+		wantOpen:    []string{"dinner"},
+	}, {
+		desc: "overlapping periods",
+		form: url.Values{
+			"period_old":   {"breakfast", "lunch"},
+			"period_name":  {"breakfast", "lunch"},
+			"period_start": {"0", "9"},
+			"period_end":   {"10", "15"},
+		},
+		wantStatus:   http.StatusBadRequest,
+		wantContains: "hour 9 overlaps between",
+		wantPeriods:  []string{"breakfast", "lunch", "dinner"},
+		wantOpen:     []string{"lunch", "dinner"},
+	}, {
+		desc: "duplicate period",
+		form: url.Values{
+			"period_old":   {"breakfast", ""},
+			"period_name":  {"breakfast", "breakfast"},
+			"period_start": {"0", "12"},
+			"period_end":   {"10", "15"},
+		},
+		wantStatus:   http.StatusBadRequest,
+		wantContains: "is defined more than once",
+		wantPeriods:  []string{"breakfast", "lunch", "dinner"},
+		wantOpen:     []string{"lunch", "dinner"},
+	}, {
+		desc: "invalid hours",
+		form: url.Values{
+			"period_old":   {"breakfast"},
+			"period_name":  {"breakfast"},
+			"period_start": {"early"},
+			"period_end":   {"10"},
+		},
+		wantStatus:   http.StatusBadRequest,
+		wantContains: "has invalid hours",
+		wantPeriods:  []string{"breakfast", "lunch", "dinner"},
+		wantOpen:     []string{"lunch", "dinner"},
+	}, {
+		desc: "mismatched fields",
+		form: url.Values{
+			"period_old":  {"breakfast"},
+			"period_name": {"breakfast"},
+		},
+		wantStatus:  http.StatusBadRequest,
+		wantPeriods: []string{"breakfast", "lunch", "dinner"},
+		wantOpen:    []string{"lunch", "dinner"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+
+			test.form.Set("_csrf", a.CSRFToken("alice"))
+			req := httptest.NewRequest("POST", "/settings?token=tokenA", strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if test.wantStatus == http.StatusSeeOther {
+				if loc := w.Header().Get("Location"); loc != "/settings?token=tokenA" {
+					t.Errorf("Location = %q, want %q", loc, "/settings?token=tokenA")
+				}
+			}
+			if test.wantContains != "" && !strings.Contains(w.Body.String(), test.wantContains) {
+				t.Errorf("body does not contain %q", test.wantContains)
+			}
+
+			if _, periodList := a.PeriodConfig(); !reflect.DeepEqual(periodList, test.wantPeriods) {
+				t.Errorf("periods = %v, want %v", periodList, test.wantPeriods)
+			}
+			pizza, _ := findEntry(a.Entries(), "Downtown", "Pizza Place")
+			if !reflect.DeepEqual(pizza.Open["mon"], test.wantOpen) {
+				t.Errorf("Pizza Place Open[mon] = %v, want %v", pizza.Open["mon"], test.wantOpen)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import "slices"
+
+// placeVoteGroup is the group under which votes for places are stored in a
+// PersonVote. No entry can be in it, as group names must not be empty.
+const placeVoteGroup = ""
+
+// dbVersion is the current version of the database format. Databases in
+// older versions are migrated when they are loaded.
+const dbVersion = 2
+
+// voteKey returns the group and name under which votes for the entry are
+// stored. Locations of a place share the votes of the place.
+func (e Entry) voteKey() (string, string) {
+	if e.Place != "" {
+		return placeVoteGroup, e.Place
+	}
+	return e.Group, e.Name
+}
+
+// vote returns the vote of person for the entry, if there is one.
+func (d *db) vote(person string, e Entry) (EntryVote, bool) {
+	group, name := e.voteKey()
+	v, ok := d.Votes[person][group][name]
+	return v, ok
+}
+
+// locations returns the groups of the other locations of the place of e that
+// are visible to person, sorted by the group order.
+func (d *db) locations(person string, e Entry) []string {
+	if e.Place == "" {
+		return nil
+	}
+	var groups []string
+	for _, other := range d.Entries {
+		if other.Place == e.Place && other.Group != e.Group && other.visibleTo(person) && !slices.Contains(groups, other.Group) {
+			groups = append(groups, other.Group)
+		}
+	}
+	sortGroupNames(groups, d.GroupOrder)
+	return groups
+}
+
+// migrate upgrades the database to the current version. The caller must hold
+// the write lock.
+func (a *App) migrate() {
+	if a.db.Version < 1 {
+		a.migratePlaces()
+	}
+	if a.db.Version < 2 {
+		a.cleanEntryNames()
+	}
+	a.db.Version = dbVersion
+}
+
+// migratePlaces turns entries with the same name in several groups, which
+// used to be the only way of having a place with several locations, into the
+// locations of a place named after them. Their votes are merged into a vote
+// for the place, keeping the lowest vote of each person so that strong noes
+// are not lost. The caller must hold the write lock.
+func (a *App) migratePlaces() {
+	groups := make(map[string]int)
+	for _, e := range a.db.Entries {
+		if e.Place == "" {
+			groups[e.Name]++
+		}
+	}
+
+	entries := slices.Clone(a.db.Entries)
+	for i, e := range entries {
+		if e.Place != "" || groups[e.Name] < 2 {
+			continue
+		}
+		for _, pv := range a.db.Votes {
+			v, ok := pv[e.Group][e.Name]
+			if !ok {
+				continue
+			}
+			delete(pv[e.Group], e.Name)
+			if len(pv[e.Group]) == 0 {
+				delete(pv, e.Group)
+			}
+			if pv[placeVoteGroup] == nil {
+				pv[placeVoteGroup] = make(GroupVote)
+			}
+			if prev, ok := pv[placeVoteGroup][e.Name]; !ok || voteScores[v] < voteScores[prev] {
+				pv[placeVoteGroup][e.Name] = v
+			}
+		}
+		entries[i].Place = e.Name
+	}
+	a.db.Entries = entries
+}
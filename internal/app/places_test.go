@@ -0,0 +1,123 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestMigratePlaces(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		input      string
+		wantPlaces map[string]string
+		wantVotes  map[string]app.PersonVote
+	}{{
+		desc: "duplicated entries become a place",
+		input: `{"entries":[` +
+			`{"Name":"Chain","Group":"A","Open":{},"Cost":1},` +
+			`{"Name":"Chain","Group":"B","Open":{},"Cost":2},` +
+			`{"Name":"Solo","Group":"A","Open":{},"Cost":1}],` +
+			`"votes":{` +
+			`"alice":{"A":{"Chain":"strong-yes","Solo":"no"},"B":{"Chain":"no"}},` +
+			`"bob":{"B":{"Chain":"yes"}}}}`,
+		wantPlaces: map[string]string{"A|Chain": "Chain", "B|Chain": "Chain", "A|Solo": ""},
+		wantVotes: map[string]app.PersonVote{
+			"alice": {"A": {"Solo": "no"}, "": {"Chain": "no"}},
+			"bob":   {"": {"Chain": "yes"}},
+		},
+	}, {
+		desc: "current databases are not migrated",
+		input: `{"version":1,"entries":[` +
+			`{"Name":"Chain","Group":"A","Open":{},"Cost":1},` +
+			`{"Name":"Chain","Group":"B","Open":{},"Cost":2}],` +
+			`"votes":{"alice":{"A":{"Chain":"strong-yes"},"B":{"Chain":"no"}}}}`,
+		wantPlaces: map[string]string{"A|Chain": "", "B|Chain": ""},
+		wantVotes: map[string]app.PersonVote{
+			"alice": {"A": {"Chain": "strong-yes"}, "B": {"Chain": "no"}},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			if err := a.Load(strings.NewReader(test.input)); err != nil {
+				t.Fatal(err)
+			}
+
+			places := make(map[string]string)
+			for _, e := range a.Entries() {
+				places[e.Group+"|"+e.Name] = e.Place
+			}
+			if !reflect.DeepEqual(places, test.wantPlaces) {
+				t.Errorf("places = %v, want %v", places, test.wantPlaces)
+			}
+			if votes := a.Votes(); !reflect.DeepEqual(votes, test.wantVotes) {
+				t.Errorf("votes = %v, want %v", votes, test.wantVotes)
+			}
+		})
+	}
+}
+
+func TestPlaceLocations(t *testing.T) {
+	// Noodle House has locations in both groups.
+	a := newTestApp(t, append(testEntries(), app.Entry{
+		Name:  "Noodle House",
+		Group: "Downtown",
+		Place: "Noodle House",
+		Open:  map[string][]string{"mon": {"lunch"}},
+		Cost:  1,
+	}, app.Entry{
+		Name:  "Noodle House Express",
+		Group: "Uptown",
+		Place: "Noodle House",
+		Open:  map[string][]string{"mon": {"lunch"}},
+		Cost:  3,
+	})...)
+	a.UpdateVotes("alice", map[string]string{"Uptown|Noodle House Express": "strong-no"})
+	a.UpdateVotes("bob", map[string]string{"Downtown|Noodle House": "strong-yes"})
+
+	// Both locations get the same votes, but keep their own cost.
+	want := map[string]int{
+		"Noodle House":         (0+3)*3 - 1*2,
+		"Noodle House Express": (0+3)*3 - 3*2,
+	}
+	for _, g := range a.TallyData(testMonday, "lunch", false) {
+		for _, e := range g.Entries {
+			score, ok := want[e.Name]
+			if !ok {
+				continue
+			}
+			if e.Score != score || !e.StrongNo {
+				t.Errorf("%s score = %d, strong no = %v, want %d, true", e.Name, e.Score, e.StrongNo, score)
+			}
+			delete(want, e.Name)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("tally is missing %v", want)
+	}
+
+	req := httptest.NewRequest("GET", "/?token=tokenA", nil)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, s := range []string{
+		`Noodle House <small class="entry-locations">also in Uptown</small>`,
+		`Noodle House Express <small class="entry-locations">also in Downtown</small>`,
+		`name="Downtown|Noodle House" data-place="Noodle House" id="Downtown|Noodle House strong-no" value="strong-no" checked`,
+		`name="Uptown|Noodle House Express" data-place="Noodle House" id="Uptown|Noodle House Express strong-no" value="strong-no" checked`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("body does not contain %q", s)
+		}
+	}
+}
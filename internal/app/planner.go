@@ -0,0 +1,211 @@
+package app
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPlannerTop is the default number of entries shown in each cell of
+// the planner, and maxPlannerTop the largest number that may be requested.
+const (
+	defaultPlannerTop = 3
+	maxPlannerTop     = 10
+)
+
+// Plan pins an entry to a period on a date in the planner.
+type Plan struct {
+	Date   string // In "2006-01-02" format.
+	Period string
+	Group  string
+	Name   string
+}
+
+// plannerDay holds a day of the planner for template rendering.
+type plannerDay struct {
+	Weekday string
+	Date    string
+	Day     string // In "2006-01-02" format.
+	Cells   []plannerCell
+}
+
+// plannerCell holds the entries of a period on a day of the planner for
+// template rendering. Top has the best ranked open entries, and Options all
+// open entries that can be pinned.
+type plannerCell struct {
+	Period  string
+	Top     []entryData
+	Options []entryData
+	Pinned  *Plan
+}
+
+// updatePlan pins the entry in plan to its date and period, replacing any
+// previous plan for them. If plan has no entry name, the plan for the date
+// and period is removed instead. Plans for dates in the past are dropped.
+func (a *App) updatePlan(plan Plan) {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	today := a.today().Format(time.DateOnly)
+	plans := slices.DeleteFunc(slices.Clone(a.db.Plans), func(p Plan) bool {
+		return p.Date < today || (p.Date == plan.Date && p.Period == plan.Period)
+	})
+	if plan.Name != "" {
+		plans = append(plans, plan)
+	}
+	a.db.Plans = plans
+}
+
+// plan returns the plan for period on date that person can see, if there is
+// one.
+func (a *App) plan(person string, date time.Time, period string) (Plan, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	day := date.Format(time.DateOnly)
+	i := slices.IndexFunc(a.db.Plans, func(p Plan) bool {
+		return p.Date == day && p.Period == period
+	})
+	if i < 0 || !a.planVisible(person, a.db.Plans[i]) {
+		return Plan{}, false
+	}
+	return a.db.Plans[i], true
+}
+
+// planVisible reports whether the entry pinned by plan still exists, is not
+// archived and is visible to person. Other plans are kept, in case their
+// entry comes back, but not shown. The caller must hold the read lock.
+func (a *App) planVisible(person string, plan Plan) bool {
+	return slices.ContainsFunc(a.db.Entries, func(e Entry) bool {
+		return e.Group == plan.Group && e.Name == plan.Name && !e.Archived && e.visibleTo(person)
+	})
+}
+
+// plannerData returns the planner of person for the week starting today,
+// with the top entries of each period on each day.
+func (a *App) plannerData(person string, top int) []plannerDay {
+	_, periodList := a.periodConfig()
+	today := a.today()
+
+	var days []plannerDay
+	for i := range 7 {
+		date := a.addDays(today, i)
+		day := plannerDay{
+			Weekday: weekdays[date.Weekday()].Full,
+			Date:    a.dateLabel(date),
+			Day:     date.Format(time.DateOnly),
+		}
+		for _, period := range periodList {
+			cell := plannerCell{Period: period}
+			for _, g := range flattenGroups(a.tallyData(person, date, period, false, tagFilter{})) {
+				for _, e := range g.Entries {
+					if !e.Closed {
+						cell.Options = append(cell.Options, e)
+					}
+				}
+			}
+			slices.SortStableFunc(cell.Options, func(x, y entryData) int {
+				return cmp.Compare(y.Score, x.Score)
+			})
+			cell.Top = cell.Options[:min(top, len(cell.Options))]
+			if plan, ok := a.plan(person, date, period); ok {
+				cell.Pinned = &plan
+			}
+			day.Cells = append(day.Cells, cell)
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// handlePlannerGet serves the weekly planner page. The number of entries
+// shown in each cell may be set with the "top" query parameter.
+func (a *App) handlePlannerGet(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	top := defaultPlannerTop
+	if topParam := r.URL.Query().Get("top"); topParam != "" {
+		n, err := strconv.Atoi(topParam)
+		if err != nil || n < 1 || n > maxPlannerTop {
+			http.Error(w, "Bad Request: invalid top", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+	_, periodList := a.periodConfig()
+
+	data := pageData{
+		Title:     "Anything",
+		BasePath:  a.basePath,
+		LoggedIn:  token == "",
+		Token:     token,
+		CSRFToken: a.csrfToken(r, person),
+		Nonce:     requestNonce(r),
+		Periods:   periodList,
+		Planner:   a.plannerData(person, top),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := a.plannerTmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handlePlannerPost pins an entry, sent as "group|name", to a period on a
+// date, or removes the plan for them if no entry is sent.
+func (a *App) handlePlannerPost(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !a.checkCSRF(w, r, person) {
+		return
+	}
+
+	plan := Plan{Date: r.PostForm.Get("date"), Period: r.PostForm.Get("period")}
+	if _, err := time.Parse(time.DateOnly, plan.Date); err != nil {
+		http.Error(w, "Bad Request: invalid date", http.StatusBadRequest)
+		return
+	}
+	periods, _ := a.periodConfig()
+	if _, ok := periods[plan.Period]; !ok {
+		http.Error(w, "Bad Request: invalid period", http.StatusBadRequest)
+		return
+	}
+	if entry := r.PostForm.Get("entry"); entry != "" {
+		group, name, _ := strings.Cut(entry, "|")
+		if !a.hasEntry(person, group, name) {
+			http.Error(w, "Bad Request: invalid entry", http.StatusBadRequest)
+			return
+		}
+		plan.Group, plan.Name = group, name
+	}
+
+	a.updatePlan(plan)
+
+	http.Redirect(w, r, a.basePath+"/planner?token="+token, http.StatusSeeOther)
+}
+
+// hasEntry reports whether there is an entry with name in group that person
+// can pin.
+func (a *App) hasEntry(person, group, name string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.planVisible(person, Plan{Group: group, Name: name})
+}
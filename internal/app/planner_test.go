@@ -0,0 +1,342 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestPlannerData(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdatePlan(app.Plan{Date: "2024-01-02", Period: "lunch", Group: "Downtown", Name: "Pizza Place"})
+
+	days := a.PlannerData("alice", 2)
+	if len(days) != 7 {
+		t.Fatalf("got %d days, want 7", len(days))
+	}
+	if days[0].Day != "2024-01-01" || days[0].Weekday != "Monday" || days[6].Day != "2024-01-07" {
+		t.Errorf("days = %s (%s) to %s, want 2024-01-01 (Monday) to 2024-01-07", days[0].Day, days[0].Weekday, days[6].Day)
+	}
+
+	names := func(entries []app.EntryData) []string {
+		var result []string
+		for _, e := range entries {
+			result = append(result, e.Name)
+		}
+		return result
+	}
+
+	var tests = []struct {
+		desc        string
+		day         int
+		period      int
+		wantTop     []string
+		wantOptions []string
+		wantPinned  string
+	}{{
+		desc:        "top entries by score",
+		day:         0,
+		period:      1, // Lunch.
+		wantTop:     []string{"Burger Joint", "Taco Stand"},
+		wantOptions: []string{"Burger Joint", "Taco Stand", "Pizza Place"},
+	}, {
+		desc:        "fewer entries than the top",
+		day:         0,
+		period:      0, // Breakfast.
+		wantTop:     []string{"Taco Stand"},
+		wantOptions: []string{"Taco Stand"},
+	}, {
+		desc:        "pinned entry",
+		day:         1,
+		period:      1, // Lunch.
+		wantTop:     []string{"Burger Joint", "Taco Stand"},
+		wantOptions: []string{"Burger Joint", "Taco Stand", "Pizza Place"},
+		wantPinned:  "Pizza Place",
+	}, {
+		desc:   "no open entries",
+		day:    5, // Saturday.
+		period: 2, // Dinner.
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cell := days[test.day].Cells[test.period]
+			if got := names(cell.Top); !reflect.DeepEqual(got, test.wantTop) {
+				t.Errorf("Top = %v, want %v", got, test.wantTop)
+			}
+			if got := names(cell.Options); !reflect.DeepEqual(got, test.wantOptions) {
+				t.Errorf("Options = %v, want %v", got, test.wantOptions)
+			}
+			var pinned string
+			if cell.Pinned != nil {
+				pinned = cell.Pinned.Name
+			}
+			if pinned != test.wantPinned {
+				t.Errorf("Pinned = %q, want %q", pinned, test.wantPinned)
+			}
+		})
+	}
+}
+
+func TestUpdatePlan(t *testing.T) {
+	a := newTestApp(t)
+	err := a.Load(strings.NewReader(`{"plans":[
+		{"Date":"2023-12-31","Period":"lunch","Group":"Downtown","Name":"Pizza Place"},
+		{"Date":"2024-01-01","Period":"lunch","Group":"Downtown","Name":"Pizza Place"},
+		{"Date":"2024-01-01","Period":"dinner","Group":"Uptown","Name":"Sushi Bar"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing a plan drops the plans in the past.
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "lunch", Group: "Downtown", Name: "Burger Joint"})
+	want := []app.Plan{
+		{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"},
+		{Date: "2024-01-01", Period: "lunch", Group: "Downtown", Name: "Burger Joint"},
+	}
+	if got := a.Plans(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Plans() = %v, want %v", got, want)
+	}
+
+	// Updating a plan without an entry removes it.
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "dinner"})
+	want = want[1:]
+	if got := a.Plans(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Plans() = %v, want %v", got, want)
+	}
+}
+
+func TestUpdatePeriodsUpdatesPlans(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "breakfast", Group: "Uptown", Name: "Taco Stand"})
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "lunch", Group: "Downtown", Name: "Pizza Place"})
+
+	err := a.UpdatePeriods(app.Periods{"brunch": {0, 15}, "dinner": {15, 0}}, map[string]string{"breakfast": "brunch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []app.Plan{{Date: "2024-01-01", Period: "brunch", Group: "Uptown", Name: "Taco Stand"}}
+	if got := a.Plans(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Plans() = %v, want %v", got, want)
+	}
+}
+
+func TestHandlePlannerGet(t *testing.T) {
+	var tests = []struct {
+		desc         string
+		query        string
+		wantStatus   int
+		wantContains []string
+	}{{
+		desc:       "default top",
+		query:      "token=tokenA",
+		wantStatus: http.StatusOK,
+		wantContains: []string{
+			"<li>Burger Joint <small>10</small></li>",
+			"<li>Pizza Place <small>8</small></li>",
+			`<option value="Uptown|Taco Stand">`,
+			`name="_csrf" value="`,
+		},
+	}, {
+		desc:         "custom top",
+		query:        "token=tokenA&top=1",
+		wantStatus:   http.StatusOK,
+		wantContains: []string{"<li>Burger Joint <small>10</small></li>"},
+	}, {
+		desc:       "invalid top",
+		query:      "token=tokenA&top=100",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		desc:       "invalid token",
+		query:      "token=bad",
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			req := httptest.NewRequest("GET", "/planner?"+test.query, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			for _, want := range test.wantContains {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body does not contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePlannerPost(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		form       url.Values
+		csrf       string
+		wantStatus int
+		wantPlans  []app.Plan
+	}{{
+		desc:       "pin entry",
+		form:       url.Values{"date": {"2024-01-02"}, "period": {"lunch"}, "entry": {"Downtown|Pizza Place"}},
+		wantStatus: http.StatusSeeOther,
+		wantPlans: []app.Plan{
+			{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"},
+			{Date: "2024-01-02", Period: "lunch", Group: "Downtown", Name: "Pizza Place"},
+		},
+	}, {
+		desc:       "unpin entry",
+		form:       url.Values{"date": {"2024-01-01"}, "period": {"dinner"}, "entry": {""}},
+		wantStatus: http.StatusSeeOther,
+	}, {
+		desc:       "invalid date",
+		form:       url.Values{"date": {"tomorrow"}, "period": {"lunch"}, "entry": {"Downtown|Pizza Place"}},
+		wantStatus: http.StatusBadRequest,
+		wantPlans:  []app.Plan{{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"}},
+	}, {
+		desc:       "invalid period",
+		form:       url.Values{"date": {"2024-01-02"}, "period": {"brunch"}, "entry": {"Downtown|Pizza Place"}},
+		wantStatus: http.StatusBadRequest,
+		wantPlans:  []app.Plan{{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"}},
+	}, {
+		desc:       "invalid entry",
+		form:       url.Values{"date": {"2024-01-02"}, "period": {"lunch"}, "entry": {"Downtown|Sushi Bar"}},
+		wantStatus: http.StatusBadRequest,
+		wantPlans:  []app.Plan{{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"}},
+	}, {
+		desc:       "invalid CSRF token",
+		form:       url.Values{"date": {"2024-01-02"}, "period": {"lunch"}, "entry": {"Downtown|Pizza Place"}},
+		csrf:       "forged",
+		wantStatus: http.StatusForbidden,
+		wantPlans:  []app.Plan{{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"})
+
+			csrf := test.csrf
+			if csrf == "" {
+				csrf = a.CSRFToken("alice")
+			}
+			test.form.Set("_csrf", csrf)
+			req := httptest.NewRequest("POST", "/planner?token=tokenA", strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if w.Code == http.StatusSeeOther {
+				if loc := w.Header().Get("Location"); loc != "/planner?token=tokenA" {
+					t.Errorf("Location = %q, want %q", loc, "/planner?token=tokenA")
+				}
+			}
+			if got := a.Plans(); !slices.Equal(got, test.wantPlans) {
+				t.Errorf("Plans() = %v, want %v", got, test.wantPlans)
+			}
+		})
+	}
+}
+
+func TestHandleTallyGetShowsPlan(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"})
+
+	for _, test := range []struct {
+		query string
+		want  bool
+	}{
+		{"period=dinner&date=2024-01-01", true},
+		{"period=lunch&date=2024-01-01", false},
+		{"period=dinner&date=2024-01-08", false},
+	} {
+		req := httptest.NewRequest("GET", "/votes?token=tokenA&"+test.query, nil)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, req)
+
+		got := strings.Contains(w.Body.String(), "Planned: Sushi Bar (Uptown)")
+		if got != test.want {
+			t.Errorf("GET /votes?%s shows plan = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestHiddenPlans(t *testing.T) {
+	entries := append(testEntries(), app.Entry{Name: "Home Pasta", Group: "Downtown", Owner: "alice", Open: map[string][]string{"mon": {"lunch"}}, Cost: 1})
+	entries[2].Archived = true // Sushi Bar.
+	a := newTestApp(t, entries...)
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "lunch", Group: "Downtown", Name: "Home Pasta"})
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"})
+	a.UpdatePlan(app.Plan{Date: "2024-01-02", Period: "lunch", Group: "Downtown", Name: "Closed Diner"})
+
+	var tests = []struct {
+		desc   string
+		person string
+		query  string
+		plan   string
+		want   bool
+	}{{
+		desc:   "private entry for its owner",
+		person: "alice",
+		query:  "period=lunch&date=2024-01-01",
+		plan:   "Home Pasta",
+		want:   true,
+	}, {
+		desc:   "private entry for someone else",
+		person: "bob",
+		query:  "period=lunch&date=2024-01-01",
+		plan:   "Home Pasta",
+	}, {
+		desc:   "archived entry",
+		person: "alice",
+		query:  "period=dinner&date=2024-01-01",
+		plan:   "Sushi Bar",
+	}, {
+		desc:   "deleted entry",
+		person: "alice",
+		query:  "period=lunch&date=2024-01-02",
+		plan:   "Closed Diner",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			token := testPeople()[test.person]
+			req := httptest.NewRequest("GET", "/votes?token="+token+"&"+test.query, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if got := strings.Contains(w.Body.String(), "Planned: "+test.plan); got != test.want {
+				t.Errorf("tally shows plan = %v, want %v", got, test.want)
+			}
+
+			req = httptest.NewRequest("GET", "/plans.ics?token="+token, nil)
+			w = httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			if got := strings.Contains(w.Body.String(), ": "+test.plan+"\r\n"); got != test.want {
+				t.Errorf("calendar shows plan = %v, want %v", got, test.want)
+			}
+
+			var pinned bool
+			for _, day := range a.PlannerData(test.person, 3) {
+				for _, cell := range day.Cells {
+					pinned = pinned || (cell.Pinned != nil && cell.Pinned.Name == test.plan)
+				}
+			}
+			if pinned != test.want {
+				t.Errorf("planner shows plan = %v, want %v", pinned, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// visibleTo reports whether person can see the entry. Private entries are
+// only visible to their owner.
+func (e Entry) visibleTo(person string) bool {
+	return e.Owner == "" || e.Owner == person
+}
+
+// checkPrivateNames returns an error if an entry visible to person has the
+// same name in its group as a private entry of someone else. Such an entry
+// cannot replace the private one, as person does not know about it.
+func checkPrivateNames(person string, entries []Entry) error {
+	for _, e := range entries {
+		if e.visibleTo(person) {
+			continue
+		}
+		i := slices.IndexFunc(entries, func(other Entry) bool {
+			return other.visibleTo(person) && duplicateOf(e)(other)
+		})
+		if i >= 0 {
+			return fmt.Errorf("%q in %q has the name of a private entry", entries[i].Name, e.Group)
+		}
+	}
+	return nil
+}
+
+// privateVotes reports whether person hides their votes from other people.
+func (d *db) privateVotes(person string) bool {
+	return slices.Contains(d.PrivateVoters, person)
+}
+
+// setPrivateVotes sets whether person hides their votes from other people.
+func (a *App) setPrivateVotes(person string, private bool) {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	voters := slices.DeleteFunc(slices.Clone(a.db.PrivateVoters), func(p string) bool {
+		return p == person
+	})
+	if private {
+		voters = append(voters, person)
+		slices.Sort(voters)
+	}
+	a.db.PrivateVoters = voters
+}
+
+// hasPrivateVotes reports whether person hides their votes from other people.
+func (a *App) hasPrivateVotes(person string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.db.privateVotes(person)
+}
+
+// voterData holds the vote of a person for an entry for template rendering.
+type voterData struct {
+	Person string
+	Vote   string
+}
+
+// voters returns the votes for the entry that viewer can see, sorted by
+// person, and the number of votes that are hidden from viewer. The caller
+// must hold the read lock.
+func (a *App) voters(viewer string, e Entry) ([]voterData, int) {
+	var votes []voterData
+	hidden := 0
+	for _, person := range slices.Sorted(maps.Keys(a.people)) {
+		v, ok := a.db.vote(person, e)
+		if !ok {
+			continue
+		}
+		if person != viewer && a.db.privateVotes(person) {
+			hidden++
+			continue
+		}
+		votes = append(votes, voterData{Person: person, Vote: string(v)})
+	}
+	return votes, hidden
+}
+
+// visibleVoteKeys returns the keys under which votes for the entries visible
+// to person are stored. The caller must hold the read lock.
+func (d *db) visibleVoteKeys(person string) map[[2]string]bool {
+	keys := make(map[[2]string]bool)
+	for _, e := range d.Entries {
+		if e.visibleTo(person) {
+			group, name := e.voteKey()
+			keys[[2]string{group, name}] = true
+		}
+	}
+	return keys
+}
+
+// exportDB returns a copy of the database without what person cannot see:
+// the private entries of other people, the votes for them, and the votes of
+// other people who keep their votes private. Entries not selected by filter
+// are left out along with their votes and plans, and so are revoked
+// sessions. The caller must hold the read lock.
+func (a *App) exportDB(person string, filter tagFilter) db {
+	data := a.db
+	data.RevokedSessions = nil
+	data.Entries = slices.DeleteFunc(slices.Clone(a.db.Entries), func(e Entry) bool {
+		return !e.visibleTo(person) || !filter.match(e.Tags)
+	})
+	data.Plans = slices.DeleteFunc(slices.Clone(a.db.Plans), func(plan Plan) bool {
+		return slices.ContainsFunc(a.db.Entries, func(e Entry) bool {
+			return e.Group == plan.Group && e.Name == plan.Name && !filter.match(e.Tags)
+		})
+	})
+
+	keys := make(map[[2]string]bool)
+	for _, e := range data.Entries {
+		group, name := e.voteKey()
+		keys[[2]string{group, name}] = true
+	}
+	data.Votes = make(map[string]PersonVote)
+	for voter, pv := range a.db.Votes {
+		if voter != person && a.db.privateVotes(voter) {
+			continue
+		}
+		votes := make(PersonVote)
+		for group, gv := range pv {
+			for name, v := range gv {
+				if !keys[[2]string{group, name}] {
+					continue
+				}
+				if votes[group] == nil {
+					votes[group] = make(GroupVote)
+				}
+				votes[group][name] = v
+			}
+		}
+		data.Votes[voter] = votes
+	}
+	return data
+}
+
+// restoreHidden adds back to the database what person could not see in old,
+// after person replaced the database: the private entries of other people,
+// the votes for them, and the votes of other people who keep their votes
+// private. The caller must hold the write lock.
+func (a *App) restoreHidden(person string, old db) {
+	entries := slices.Clone(a.db.Entries)
+	for _, e := range old.Entries {
+		if e.visibleTo(person) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	a.db.Entries = entries
+
+	voters := slices.DeleteFunc(slices.Clone(a.db.PrivateVoters), func(p string) bool {
+		return p != person
+	})
+	for _, p := range old.PrivateVoters {
+		if p != person {
+			voters = append(voters, p)
+		}
+	}
+	slices.Sort(voters)
+	a.db.PrivateVoters = voters
+
+	// Votes are copied, as they may be shared with old.
+	votes := make(map[string]PersonVote)
+	set := func(voter, group, name string, v EntryVote) {
+		if votes[voter] == nil {
+			votes[voter] = make(PersonVote)
+		}
+		if votes[voter][group] == nil {
+			votes[voter][group] = make(GroupVote)
+		}
+		votes[voter][group][name] = v
+	}
+	for voter, pv := range a.db.Votes {
+		for group, gv := range pv {
+			for name, v := range gv {
+				set(voter, group, name, v)
+			}
+		}
+	}
+	keys := old.visibleVoteKeys(person)
+	for voter, pv := range old.Votes {
+		hidden := voter != person && old.privateVotes(voter)
+		for group, gv := range pv {
+			for name, v := range gv {
+				if hidden || !keys[[2]string{group, name}] {
+					set(voter, group, name, v)
+				}
+			}
+		}
+	}
+	a.db.Votes = votes
+}
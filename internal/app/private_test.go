@@ -0,0 +1,264 @@
+package app_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestPrivateEntries(t *testing.T) {
+	var tests = []struct {
+		person   string
+		wantSeen bool
+	}{{
+		person:   "alice",
+		wantSeen: true,
+	}, {
+		person:   "bob",
+		wantSeen: false,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.person, func(t *testing.T) {
+			a := newTestApp(t, append(testEntries(), app.Entry{
+				Name:  "Home Pasta",
+				Group: "Downtown",
+				Owner: "alice",
+				Open:  map[string][]string{"mon": {"lunch"}},
+				Tags:  app.Tags{"homemade"},
+				Cost:  1,
+			})...)
+
+			if seen := slices.Contains(entryNames(a.VotePageData(test.person)), "Home Pasta"); seen != test.wantSeen {
+				t.Errorf("vote page has private entry = %v, want %v", seen, test.wantSeen)
+			}
+			if seen := slices.Contains(entryNames(a.PersonTallyData(test.person, testMonday, "lunch")), "Home Pasta"); seen != test.wantSeen {
+				t.Errorf("tally has private entry = %v, want %v", seen, test.wantSeen)
+			}
+
+			token := testPeople()[test.person]
+			for _, u := range []string{"/?token=" + token, "/entries?token=" + token, "/export.json?token=" + token} {
+				req := httptest.NewRequest("GET", u, nil)
+				w := httptest.NewRecorder()
+				a.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s status = %d, want %d", u, w.Code, http.StatusOK)
+				}
+				if seen := strings.Contains(w.Body.String(), "Home Pasta"); seen != test.wantSeen {
+					t.Errorf("%s has private entry = %v, want %v", u, seen, test.wantSeen)
+				}
+				if seen := strings.Contains(w.Body.String(), "homemade"); seen != test.wantSeen {
+					t.Errorf("%s has private tag = %v, want %v", u, seen, test.wantSeen)
+				}
+			}
+		})
+	}
+}
+
+func TestPrivateEntriesUpdates(t *testing.T) {
+	a := newTestApp(t, append(testEntries(), app.Entry{Name: "Home Pasta", Group: "Downtown", Owner: "alice", Open: map[string][]string{"mon": {"lunch"}}, Cost: 1})...)
+
+	// Votes for entries that cannot be seen are discarded.
+	a.UpdateVotes("bob", map[string]string{"Downtown|Home Pasta": "strong-no"})
+	if votes := a.Votes()["bob"]; len(votes) != 0 {
+		t.Errorf("bob votes = %v, want none", votes)
+	}
+
+	// Entries cannot take the name of private entries of other people.
+	form := url.Values{
+		"_csrf":               {a.CSRFToken("bob")},
+		"Downtown|Home Pasta": {"4;private"},
+		"Uptown|Home Sushi":   {"2;private"},
+	}
+	req := httptest.NewRequest("POST", "/entries?token=tokenB", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if e, ok := findEntry(a.Entries(), "Downtown", "Home Pasta"); !ok || e.Owner != "alice" || e.Cost != 1 {
+		t.Errorf("Downtown|Home Pasta = %+v, want the entry of alice", e)
+	}
+
+	// Saving the entries keeps the private entries of other people.
+	form.Del("Downtown|Home Pasta")
+	req = httptest.NewRequest("POST", "/entries?token=tokenB", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+
+	entries := a.Entries()
+	if len(entries) != 2 {
+		t.Errorf("got %d entries, want 2", len(entries))
+	}
+	if e, ok := findEntry(entries, "Downtown", "Home Pasta"); !ok || e.Owner != "alice" || e.Cost != 1 {
+		t.Errorf("Downtown|Home Pasta = %+v, want the entry of alice", e)
+	}
+	if e, ok := findEntry(entries, "Uptown", "Home Sushi"); !ok || e.Owner != "bob" {
+		t.Errorf("Uptown|Home Sushi = %+v, want an entry of bob", e)
+	}
+
+	// Private entries can only be pinned in the planner by their owner.
+	for _, test := range []struct {
+		person     string
+		wantStatus int
+	}{
+		{"alice", http.StatusBadRequest},
+		{"bob", http.StatusSeeOther},
+	} {
+		form = url.Values{
+			"_csrf":  {a.CSRFToken(test.person)},
+			"date":   {time.Now().Format(time.DateOnly)},
+			"period": {"lunch"},
+			"entry":  {"Uptown|Home Sushi"},
+		}
+		req = httptest.NewRequest("POST", "/planner?token="+testPeople()[test.person], strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		a.ServeHTTP(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("planner status for %s = %d, want %d", test.person, w.Code, test.wantStatus)
+		}
+	}
+}
+
+func TestPrivateVotes(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdateVotes("alice", map[string]string{"Downtown|Pizza Place": "yes"})
+
+	form := url.Values{
+		"_csrf":                {a.CSRFToken("bob")},
+		"_private":             {"on"},
+		"Downtown|Pizza Place": {"strong-no"},
+	}
+	req := httptest.NewRequest("POST", "/votes?token=tokenB", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var tests = []struct {
+		person     string
+		wantVoters []app.VoterData
+		wantHidden int
+	}{{
+		person:     "alice",
+		wantVoters: []app.VoterData{{Person: "alice", Vote: "yes"}},
+		wantHidden: 1,
+	}, {
+		person:     "bob",
+		wantVoters: []app.VoterData{{Person: "alice", Vote: "yes"}, {Person: "bob", Vote: "strong-no"}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.person, func(t *testing.T) {
+			for _, g := range a.PersonTallyData(test.person, testMonday, "lunch") {
+				for _, e := range g.Entries {
+					if e.Name != "Pizza Place" {
+						continue
+					}
+					if !reflect.DeepEqual(e.Voters, test.wantVoters) || e.HiddenVotes != test.wantHidden {
+						t.Errorf("voters = %v, %d hidden, want %v, %d hidden", e.Voters, e.HiddenVotes, test.wantVoters, test.wantHidden)
+					}
+					// Hidden votes still count.
+					if want := (2+0)*3 - 2*2; e.Score != want || !e.StrongNo {
+						t.Errorf("score = %d, strong no = %v, want %d, true", e.Score, e.StrongNo, want)
+					}
+				}
+			}
+		})
+	}
+
+	req = httptest.NewRequest("GET", "/?token=tokenB", nil)
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if want := `name="_private" value="on" checked`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("vote page does not contain %q", want)
+	}
+}
+
+func TestPrivateExportImport(t *testing.T) {
+	a := newTestApp(t, append(testEntries(), app.Entry{Name: "Home Pasta", Group: "Downtown", Owner: "alice", Open: map[string][]string{"mon": {"lunch"}}, Cost: 1})...)
+	a.UpdateVotes("alice", map[string]string{
+		"Downtown|Home Pasta":  "strong-yes",
+		"Downtown|Pizza Place": "no",
+	})
+	form := url.Values{
+		"_csrf":            {a.CSRFToken("bob")},
+		"_private":         {"on"},
+		"Uptown|Sushi Bar": {"strong-no"},
+	}
+	req := httptest.NewRequest("POST", "/votes?token=tokenB", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("vote status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var before bytes.Buffer
+	if err := a.Save(&before); err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest("GET", "/export.json?token=tokenA", nil)
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), "Sushi Bar\":\"strong-no") {
+		t.Errorf("export of alice has the private votes of bob")
+	}
+
+	req = httptest.NewRequest("GET", "/export.json?token=tokenB", nil)
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	export := w.Body.String()
+	if strings.Contains(export, "Home Pasta") {
+		t.Errorf("export of bob has the private entry of alice")
+	}
+
+	// Importing the export of bob keeps what bob could not see.
+	req = httptest.NewRequest("POST", "/import.json?token=tokenB", strings.NewReader(export))
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("import status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	b := newTestApp(t)
+	if err := b.Load(&before); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.Votes(), b.Votes()) {
+		t.Errorf("votes after import = %v, want %v", a.Votes(), b.Votes())
+	}
+	if len(a.Entries()) != len(b.Entries()) {
+		t.Errorf("got %d entries after import, want %d", len(a.Entries()), len(b.Entries()))
+	}
+
+	// Imported entries cannot take the name of private entries of other
+	// people.
+	dump := strings.Replace(export, `"Name":"Pizza Place"`, `"Name":"Home Pasta"`, 1)
+	req = httptest.NewRequest("POST", "/import.json?token=tokenB", strings.NewReader(dump))
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("import status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if e, ok := findEntry(a.Entries(), "Downtown", "Home Pasta"); !ok || e.Owner != "alice" {
+		t.Errorf("Downtown|Home Pasta = %+v, want the entry of alice", e)
+	}
+}
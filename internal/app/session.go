@@ -0,0 +1,229 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alnvdl/anything/internal/oidc"
+)
+
+const (
+	sessionCookie          = "anything_session"
+	loginCookie            = "anything_login"
+	loginTimeout           = 10 * time.Minute
+	defaultSessionDuration = 30 * 24 * time.Hour
+)
+
+// signValue returns value followed by its signature under key. The signature
+// also covers scope, so that values cannot be replayed in a different scope.
+// The value must not contain "~".
+func signValue(key []byte, scope, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return value + "~" + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyValue returns the value signed by signValue under key and scope, and
+// whether its signature is valid.
+func verifyValue(key []byte, scope, signed string) (string, bool) {
+	value, _, ok := strings.Cut(signed, "~")
+	if !ok || !hmac.Equal([]byte(signed), []byte(signValue(key, scope, value))) {
+		return "", false
+	}
+	return value, true
+}
+
+// readSignedCookie returns the dot-separated fields of the signed cookie with
+// the given name, which must carry an expiry Unix timestamp as last field. It
+// returns false if the cookie is missing, forged, expired or was set by
+// another space.
+func (a *App) readSignedCookie(r *http.Request, name string) ([]string, bool) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return nil, false
+	}
+	value, ok := verifyValue(a.sessionKey, a.cookieScope(name), c.Value)
+	if !ok {
+		return nil, false
+	}
+	fields := strings.Split(value, ".")
+	expiry, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil || a.clock.Now().Unix() >= expiry {
+		return nil, false
+	}
+	return fields[:len(fields)-1], true
+}
+
+// setSignedCookie sets a signed cookie with the given dot-separated fields,
+// valid for d under path.
+func (a *App) setSignedCookie(w http.ResponseWriter, name, path string, d time.Duration, fields ...string) {
+	expiry := strconv.FormatInt(a.clock.Now().Add(d).Unix(), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    signValue(a.sessionKey, a.cookieScope(name), strings.Join(append(fields, expiry), ".")),
+		Path:     path,
+		MaxAge:   int(d.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(a.publicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// cookieScope returns the scope of signed cookies with the given name, binding
+// them to the space the App serves.
+func (a *App) cookieScope(name string) string {
+	return name + "|" + a.basePath
+}
+
+// clearCookie removes the cookie with the given name and path.
+func clearCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Path: path, MaxAge: -1})
+}
+
+// session returns the person logged in with the session cookie of the
+// request, if any, along with the ID of the session.
+func (a *App) session(r *http.Request) (person, id string, ok bool) {
+	fields, ok := a.readSignedCookie(r, sessionCookie)
+	if !ok || len(fields) != 2 {
+		return "", "", false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(fields[0])
+	if err != nil {
+		return "", "", false
+	}
+	person, id = string(b), fields[1]
+	if _, ok := a.people[person]; !ok || a.sessionRevoked(id) {
+		return "", "", false
+	}
+	return person, id, true
+}
+
+// sessionPerson returns the person logged in with the session cookie of the
+// request, if any.
+func (a *App) sessionPerson(r *http.Request) (string, bool) {
+	person, _, ok := a.session(r)
+	return person, ok
+}
+
+// sessionRevoked reports whether the session with the given ID was ended by
+// logging out.
+func (a *App) sessionRevoked(id string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.db.RevokedSessions[id]
+	return ok
+}
+
+// revokeSession ends the session with the given ID, so that its cookie is
+// rejected even if it was copied before logging out. Sessions are only kept
+// in the revocation list until their cookies expire.
+func (a *App) revokeSession(id string) {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock.Now().Unix()
+	revoked := make(map[string]int64)
+	for other, expiry := range a.db.RevokedSessions {
+		if expiry > now {
+			revoked[other] = expiry
+		}
+	}
+	revoked[id] = a.clock.Now().Add(a.sessionDuration).Unix()
+	a.db.RevokedSessions = revoked
+}
+
+// personForIdentity returns the person an OIDC identity maps to, matching its
+// subject first and then its email address, if verified.
+func (a *App) personForIdentity(id oidc.Identity) (string, bool) {
+	if person, ok := a.identities[id.Subject]; ok {
+		return person, true
+	}
+	if id.EmailVerified && id.Email != "" {
+		for identity, person := range a.identities {
+			if strings.EqualFold(identity, id.Email) {
+				return person, true
+			}
+		}
+	}
+	return "", false
+}
+
+// redirectURI returns the URL the OIDC provider redirects users back to.
+func (a *App) redirectURI() string {
+	return a.publicURL + a.basePath + "/auth/callback"
+}
+
+// handleLogin redirects the user to the OIDC provider to log in.
+func (a *App) handleLogin(w http.ResponseWriter, r *http.Request) {
+	state, nonce, verifier := oidc.RandomString(), oidc.RandomString(), oidc.RandomString()
+	a.setSignedCookie(w, loginCookie, a.basePath+"/auth/", loginTimeout, state, nonce, verifier)
+	http.Redirect(w, r, a.oidc.AuthURL(a.redirectURI(), state, nonce, verifier), http.StatusSeeOther)
+}
+
+// handleAuthCallback completes an OIDC login and starts a session for the
+// person the identity maps to.
+func (a *App) handleAuthCallback(w http.ResponseWriter, r *http.Request) {
+	fields, ok := a.readSignedCookie(r, loginCookie)
+	clearCookie(w, loginCookie, a.basePath+"/auth/")
+	q := r.URL.Query()
+	if !ok || len(fields) != 3 || q.Get("state") != fields[0] {
+		http.Error(w, "Bad Request: invalid or expired login, please try again", http.StatusBadRequest)
+		return
+	}
+	nonce, verifier := fields[1], fields[2]
+
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "Forbidden: login failed: "+e, http.StatusForbidden)
+		return
+	}
+
+	id, err := a.oidc.Exchange(r.Context(), a.redirectURI(), q.Get("code"), verifier, nonce)
+	if err != nil {
+		a.limiter.logger.Warn("OIDC login failed", slog.String("error", err.Error()))
+		http.Error(w, "Forbidden: login failed", http.StatusForbidden)
+		return
+	}
+	person, ok := a.personForIdentity(id)
+	if !ok {
+		a.limiter.logger.Warn("OIDC identity is not mapped to a person",
+			slog.String("subject", id.Subject),
+			slog.String("email", id.Email))
+		http.Error(w, "Forbidden: this account has no access", http.StatusForbidden)
+		return
+	}
+
+	a.setSignedCookie(w, sessionCookie, a.basePath+"/", a.sessionDuration,
+		base64.RawURLEncoding.EncodeToString([]byte(person)), oidc.RandomString())
+	http.Redirect(w, r, a.basePath+"/", http.StatusSeeOther)
+}
+
+// handleLogout ends the session of the person, revoking it so that copies of
+// its cookie stop working too.
+func (a *App) handleLogout(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !a.checkCSRF(w, r, person) {
+		return
+	}
+
+	if _, id, ok := a.session(r); ok {
+		a.revokeSession(id)
+	}
+	clearCookie(w, sessionCookie, a.basePath+"/")
+	http.Error(w, "You have been logged out.", http.StatusOK)
+}
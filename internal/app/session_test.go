@@ -0,0 +1,386 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+	"github.com/alnvdl/anything/internal/oidc"
+	"github.com/alnvdl/anything/internal/oidc/oidctest"
+)
+
+// newOIDCTestApp returns an App that accepts logins from a stand-in identity
+// provider, along with the provider.
+func newOIDCTestApp(t *testing.T) (*app.App, *oidctest.Provider) {
+	t.Helper()
+	idp := oidctest.NewProvider("anything", "secret")
+	t.Cleanup(idp.Close)
+
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "anything",
+		ClientSecret: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.New(app.Params{
+		Entries:  testEntries(),
+		People:   testPeople(),
+		Timezone: time.UTC,
+		Periods:  testPeriods(),
+		OIDC:     provider,
+		Identities: map[string]string{
+			"alice-subject":   "alice",
+			"Bob@example.com": "bob",
+		},
+		PublicURL: "https://anything.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, idp
+}
+
+// login goes through the login flow of a with the given claims, returning the
+// final response.
+func login(t *testing.T, a *app.App, idp *oidctest.Provider, claims oidctest.Claims) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/login", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("login: expected status %d, got %d", http.StatusSeeOther, rec.Code)
+	}
+	loginCookies := rec.Result().Cookies()
+
+	callback, err := idp.Authorize(rec.Header().Get("Location"), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/auth/callback" {
+		t.Fatalf("expected redirect URI path /auth/callback, got %q", u.Path)
+	}
+
+	req = httptest.NewRequest("GET", u.RequestURI(), nil)
+	for _, c := range loginCookies {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+// sessionCookie returns the session cookie set by res, if any.
+func sessionCookie(res *http.Response) *http.Cookie {
+	for _, c := range res.Cookies() {
+		if c.Name == "anything_session" && c.MaxAge > 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestOIDCLogin(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		claims     oidctest.Claims
+		wantStatus int
+		wantPerson string
+	}{{
+		desc:       "login by subject",
+		claims:     oidctest.Claims{Subject: "alice-subject"},
+		wantStatus: http.StatusSeeOther,
+		wantPerson: "alice",
+	}, {
+		desc:       "login by verified email",
+		claims:     oidctest.Claims{Subject: "other", Email: "bob@example.com", EmailVerified: true},
+		wantStatus: http.StatusSeeOther,
+		wantPerson: "bob",
+	}, {
+		desc:       "unverified email",
+		claims:     oidctest.Claims{Subject: "other", Email: "bob@example.com"},
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "unknown identity",
+		claims:     oidctest.Claims{Subject: "mallory"},
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a, idp := newOIDCTestApp(t)
+			res := login(t, a, idp, test.claims)
+			if res.StatusCode != test.wantStatus {
+				t.Fatalf("expected status %d, got %d", test.wantStatus, res.StatusCode)
+			}
+			cookie := sessionCookie(res)
+			if test.wantPerson == "" {
+				if cookie != nil {
+					t.Fatal("expected no session cookie")
+				}
+				return
+			}
+			if cookie == nil {
+				t.Fatal("expected a session cookie")
+			}
+			if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+				t.Errorf("expected an HttpOnly, Secure, SameSite=Lax cookie, got %+v", cookie)
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), "("+test.wantPerson) {
+				t.Errorf("expected page for %s", test.wantPerson)
+			}
+			if !strings.Contains(rec.Body.String(), `action="/logout"`) {
+				t.Error("expected a logout form")
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackInvalidState(t *testing.T) {
+	a, idp := newOIDCTestApp(t)
+
+	req := httptest.NewRequest("GET", "/login", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	callback, err := idp.Authorize(rec.Header().Get("Location"), oidctest.Claims{Subject: "alice-subject"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(callback)
+
+	var tests = []struct {
+		desc    string
+		cookies []*http.Cookie
+	}{{
+		desc: "no login cookie",
+	}, {
+		desc:    "forged login cookie",
+		cookies: []*http.Cookie{{Name: "anything_login", Value: "state.nonce.verifier.9999999999~sig"}},
+	}, {
+		desc:    "login cookie from another attempt",
+		cookies: rec.Result().Cookies(),
+	}}
+
+	// Use the state of a different login attempt for the last case.
+	q := u.Query()
+	q.Set("state", "other")
+	u.RawQuery = q.Encode()
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", u.RequestURI(), nil)
+			for _, c := range test.cookies {
+				req.AddCookie(c)
+			}
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+		})
+	}
+}
+
+func TestOIDCRedirectsToLogin(t *testing.T) {
+	a, _ := newOIDCTestApp(t)
+
+	var tests = []struct {
+		desc         string
+		method       string
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{{
+		desc:         "page without credentials",
+		method:       "GET",
+		path:         "/",
+		wantStatus:   http.StatusSeeOther,
+		wantLocation: "/login",
+	}, {
+		desc:       "page with a token",
+		method:     "GET",
+		path:       "/?token=tokenA",
+		wantStatus: http.StatusOK,
+	}, {
+		desc:       "page with an invalid token",
+		method:     "GET",
+		path:       "/?token=invalid",
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "form submission without credentials",
+		method:     "POST",
+		path:       "/votes",
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, nil)
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, req)
+			if rec.Code != test.wantStatus {
+				t.Fatalf("expected status %d, got %d", test.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != test.wantLocation {
+				t.Errorf("expected location %q, got %q", test.wantLocation, got)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	a, idp := newOIDCTestApp(t)
+	cookie := sessionCookie(login(t, a, idp, oidctest.Claims{Subject: "alice-subject"}))
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	form := url.Values{"_csrf": {a.SessionCSRFToken(req, "alice")}}
+	req = httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var cleared bool
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "anything_session" && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Error("expected the session cookie to be cleared")
+	}
+
+	// A copy of the cookie no longer works after logging out.
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected status %d with the revoked cookie, got %d", http.StatusSeeOther, rec.Code)
+	}
+
+	// Other sessions of the same person are not affected.
+	other := sessionCookie(login(t, a, idp, oidctest.Claims{Subject: "alice-subject"}))
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(other)
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d with another session, got %d", http.StatusOK, rec.Code)
+	}
+
+	// Revoked sessions are kept across restarts, but not exported.
+	var buf bytes.Buffer
+	if err := a.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "revokedSessions") {
+		t.Error("expected the revoked session to be saved")
+	}
+	req = httptest.NewRequest("GET", "/export.json?token=tokenA", nil)
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), "revokedSessions") {
+		t.Error("expected the revoked session not to be exported")
+	}
+}
+
+func TestSessionCSRF(t *testing.T) {
+	a, idp := newOIDCTestApp(t)
+	first := sessionCookie(login(t, a, idp, oidctest.Claims{Subject: "alice-subject"}))
+	second := sessionCookie(login(t, a, idp, oidctest.Claims{Subject: "alice-subject"}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(first)
+	firstToken := a.SessionCSRFToken(req, "alice")
+
+	var tests = []struct {
+		desc       string
+		cookie     *http.Cookie
+		token      string
+		wantStatus int
+	}{{
+		desc:       "token of the session",
+		cookie:     first,
+		token:      firstToken,
+		wantStatus: http.StatusOK,
+	}, {
+		desc:       "token of another session",
+		cookie:     second,
+		token:      firstToken,
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "token for the URL token",
+		cookie:     first,
+		token:      a.CSRFToken("alice"),
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			form := url.Values{"_csrf": {test.token}, "Downtown|Pizza Place": {"yes"}}
+			req := httptest.NewRequest("POST", "/votes", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(test.cookie)
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, req)
+			if rec.Code != test.wantStatus {
+				t.Errorf("expected status %d, got %d", test.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	a, idp := newOIDCTestApp(t)
+	cookie := sessionCookie(login(t, a, idp, oidctest.Claims{Subject: "alice-subject"}))
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	a.SetNowFunc(func() time.Time { return time.Now().Add(31 * 24 * time.Hour) })
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected status %d, got %d", http.StatusSeeOther, rec.Code)
+	}
+}
+
+func TestNewUnknownIdentityPerson(t *testing.T) {
+	_, err := app.New(app.Params{
+		People:     testPeople(),
+		Timezone:   time.UTC,
+		Periods:    testPeriods(),
+		Identities: map[string]string{"subject": "mallory"},
+	})
+	if !errorContains(err, `maps to unknown person "mallory"`) {
+		t.Errorf("expected unknown person error, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/alnvdl/anything/internal/version"
+)
+
+// spacePrefix is the path prefix under which every space is served.
+const spacePrefix = "/s/"
+
+// Spaces serves several independent Apps from a single server. Each App is a
+// space with its own database, people, periods and timezone, and it is routed
+// by the "/s/{name}/" path prefix. Tokens are only valid within their space.
+type Spaces struct {
+	apps    map[string]*App
+	mux     *http.ServeMux
+	handler http.Handler
+}
+
+// NewSpaces creates one App per entry in params, keyed by space name. The
+// BasePath of each Params is overwritten with the space prefix. The headers
+// override the DefaultSecurityHeaders on the responses that are not served
+// by a space, such as those for unknown spaces.
+func NewSpaces(params map[string]Params, headers map[string]string) (*Spaces, error) {
+	s := &Spaces{
+		apps: make(map[string]*App),
+		mux:  http.NewServeMux(),
+	}
+	s.handler = withSecurityHeaders(securityHeaders(headers), s.mux)
+
+	for name, p := range params {
+		if name == "" || url.PathEscape(name) != name {
+			s.Close()
+			return nil, fmt.Errorf("invalid space name %q", name)
+		}
+
+		prefix := spacePrefix + name
+		p.BasePath = prefix
+		a, err := New(p)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("cannot create space %q: %w", name, err)
+		}
+		s.apps[name] = a
+		s.mux.Handle(prefix+"/", http.StripPrefix(prefix, a))
+	}
+	s.mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, version.Version(), http.StatusOK)
+	})
+
+	return s, nil
+}
+
+// Close stops the auto-save mechanism of every space.
+func (s *Spaces) Close() {
+	for _, a := range s.apps {
+		a.Close()
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Spaces) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
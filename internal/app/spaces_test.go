@@ -0,0 +1,214 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+// newTestSpaces creates Spaces with two spaces for testing: "family", with the
+// default test data, and "friends", with a single entry and person.
+func newTestSpaces(t *testing.T) *app.Spaces {
+	t.Helper()
+	s, err := app.NewSpaces(map[string]app.Params{
+		"family": {
+			Entries:  testEntries(),
+			People:   testPeople(),
+			Timezone: time.UTC,
+			Periods:  testPeriods(),
+		},
+		"friends": {
+			Entries: []app.Entry{{
+				Name:  "Noodle Bar",
+				Group: "Midtown",
+				Open:  map[string][]string{"mon": {"lunch"}},
+				Cost:  1,
+			}},
+			People:   map[string]string{"carol": "tokenC"},
+			Timezone: time.UTC,
+			Periods:  app.Periods{"lunch": {11, 15}},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestSpacesRouting(t *testing.T) {
+	s := newTestSpaces(t)
+
+	var tests = []struct {
+		desc       string
+		path       string
+		wantStatus int
+		wantBody   []string
+		wantNot    []string
+	}{{
+		desc:       "family vote page",
+		path:       "/s/family/?token=tokenA",
+		wantStatus: http.StatusOK,
+		wantBody:   []string{"alice", "Pizza Place", `href="/s/family/entries?token=tokenA"`, `href="/s/family/static/anything.css"`, `href="/s/family/manifest.json?token=tokenA"`},
+		wantNot:    []string{"Noodle Bar"},
+	}, {
+		desc:       "friends vote page",
+		path:       "/s/friends/?token=tokenC",
+		wantStatus: http.StatusOK,
+		wantBody:   []string{"carol", "Noodle Bar", `action="/s/friends/votes?token=tokenC"`},
+		wantNot:    []string{"Pizza Place"},
+	}, {
+		desc:       "token from another space is rejected",
+		path:       "/s/friends/?token=tokenA",
+		wantStatus: http.StatusForbidden,
+	}, {
+		desc:       "static files are served per space",
+		path:       "/s/friends/static/anything.css",
+		wantStatus: http.StatusOK,
+		wantBody:   []string{".entry-list"},
+	}, {
+		desc:       "manifest uses the space prefix",
+		path:       "/s/family/manifest.json?token=tokenB",
+		wantStatus: http.StatusOK,
+		wantBody:   []string{`"start_url": "/s/family/?token=tokenB"`, `"src": "/s/family/static/icon16.png"`},
+	}, {
+		desc:       "unknown space",
+		path:       "/s/other/?token=tokenA",
+		wantStatus: http.StatusNotFound,
+	}, {
+		desc:       "root is not a space",
+		path:       "/?token=tokenA",
+		wantStatus: http.StatusNotFound,
+	}, {
+		desc:       "status",
+		path:       "/status",
+		wantStatus: http.StatusOK,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", test.path, nil)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+
+			body := w.Body.String()
+			for _, want := range test.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q", want)
+				}
+			}
+			for _, notWant := range test.wantNot {
+				if strings.Contains(body, notWant) {
+					t.Errorf("body contains %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestSpacesEntriesPostRedirectsWithinSpace(t *testing.T) {
+	s := newTestSpaces(t)
+
+	form := url.Values{"G|Entry": {"1;mon:lunch"}, "_csrf": {csrfToken(t, s, "/s/friends/entries?token=tokenC")}}
+	req := httptest.NewRequest("POST", "/s/friends/entries?token=tokenC", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	if loc := w.Header().Get("Location"); loc != "/s/friends/?token=tokenC" {
+		t.Errorf("Location = %q, want %q", loc, "/s/friends/?token=tokenC")
+	}
+
+	// The family space must be left untouched.
+	req = httptest.NewRequest("GET", "/s/family/?token=tokenA", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "Pizza Place") {
+		t.Error("family space lost its entries after editing friends space")
+	}
+}
+
+func TestSpacesExportImport(t *testing.T) {
+	s := newTestSpaces(t)
+
+	req := httptest.NewRequest("GET", "/s/family/export.json?token=tokenA", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export status = %d, want %d", w.Code, http.StatusOK)
+	}
+	dump := w.Body.String()
+	if strings.Contains(dump, "Noodle Bar") {
+		t.Error("family export contains entries from the friends space")
+	}
+
+	req = httptest.NewRequest("POST", "/s/friends/import.json?token=tokenC", strings.NewReader(dump))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("import status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	req = httptest.NewRequest("GET", "/s/friends/?token=tokenC", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	body := w.Body.String()
+	if !strings.Contains(body, "Pizza Place") || strings.Contains(body, "Noodle Bar") {
+		t.Error("friends space does not reflect the imported entries")
+	}
+}
+
+// csrfToken fetches the page at path and returns the CSRF token in its form.
+func csrfToken(t *testing.T, h http.Handler, path string) string {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	_, after, ok := strings.Cut(w.Body.String(), `name="_csrf" value="`)
+	if !ok {
+		t.Fatalf("GET %s has no CSRF token", path)
+	}
+	token, _, _ := strings.Cut(after, `"`)
+	return token
+}
+
+func TestSpacesCSRFTokensAreScoped(t *testing.T) {
+	s := newTestSpaces(t)
+
+	// A CSRF token from one space is not accepted by another space.
+	familyCSRF := csrfToken(t, s, "/s/family/?token=tokenA")
+	friendsCSRF := csrfToken(t, s, "/s/friends/?token=tokenC")
+	if familyCSRF == friendsCSRF {
+		t.Fatal("CSRF tokens of different spaces are equal")
+	}
+
+	form := url.Values{"G|Entry": {"1;mon:lunch"}, "_csrf": {familyCSRF}}
+	req := httptest.NewRequest("POST", "/s/friends/entries?token=tokenC", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestNewSpacesInvalidName(t *testing.T) {
+	_, err := app.NewSpaces(map[string]app.Params{
+		"a/b": {People: testPeople(), Timezone: time.UTC, Periods: testPeriods()},
+	}, nil)
+	if !errorContains(err, "invalid space name") {
+		t.Errorf("NewSpaces() err = %v, want invalid space name", err)
+	}
+}
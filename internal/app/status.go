@@ -0,0 +1,80 @@
+package app
+
+import "time"
+
+// Statuses of the entries that are open in the tally of the current period.
+const (
+	statusOpenNow     = "open-now"
+	statusClosingSoon = "closing-soon"
+	statusOpensLater  = "opens-later"
+)
+
+// statusRank orders the statuses of open entries in the tally. Entries open
+// now come first, as there is more time left to go to them.
+var statusRank = map[string]int{
+	statusOpenNow:     0,
+	statusClosingSoon: 1,
+	statusOpensLater:  2,
+}
+
+// defaultClosingSoonWindow is the default value for
+// Params.ClosingSoonWindow.
+const defaultClosingSoonWindow = 30 * time.Minute
+
+// entryStatus returns the status of an entry that is open on date during the
+// current period, along with the time at which it closes if it is closing
+// soon, or at which it opens if it opens later. Entries without opening hours
+// are open during the whole period. The caller must hold the read lock.
+func (a *App) entryStatus(e Entry, date time.Time, period string) (string, string) {
+	date, minute, end := a.currentWindow(date, period)
+	if s := a.entrySchedule(e, date); !s.byHours {
+		return statusOpenNow, ""
+	}
+
+	if until, ok := a.entryOpenUntil(e, date, minute); ok {
+		// Follow intervals that start as soon as the previous one ends.
+		for {
+			next, ok := a.entryOpenUntil(e, date, until)
+			if !ok || next <= until {
+				break
+			}
+			until = next
+		}
+		if until-minute <= int(a.closingSoonWindow/time.Minute) {
+			return statusClosingSoon, formatClock(until)
+		}
+		return statusOpenNow, ""
+	}
+
+	opening, opens := 0, false
+	for offset := -1; offset <= 1; offset++ {
+		s := a.entrySchedule(e, a.addDays(date, offset))
+		if !s.byHours {
+			continue
+		}
+		if o, ok := nextOpening(s.hours, offset*minutesPerDay, minute, end); ok && (!opens || o < opening) {
+			opening, opens = o, true
+		}
+	}
+	if opens {
+		return statusOpensLater, formatClock(opening)
+	}
+	return statusOpenNow, ""
+}
+
+// entryOpenUntil returns the minute, relative to the start of date, until
+// which the opening hours of the entry are open from minute, and false if
+// they are not open at that minute. The caller must hold the read lock.
+func (a *App) entryOpenUntil(e Entry, date time.Time, minute int) (int, bool) {
+	until, open := 0, false
+	for offset := -1; offset <= 1; offset++ {
+		s := a.entrySchedule(e, a.addDays(date, offset))
+		if !s.byHours {
+			continue
+		}
+		if u, ok := openUntil(s.hours, offset*minutesPerDay, minute); ok {
+			until, open = max(until, u), true
+		}
+	}
+	return until, open
+}
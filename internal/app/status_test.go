@@ -0,0 +1,151 @@
+package app_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestTallyDataStatus(t *testing.T) {
+	entries := []app.Entry{{
+		Name:  "Later",
+		Group: "Group",
+		Hours: app.Hours{"mon": {"14:00-16:00"}},
+		Cost:  1,
+	}, {
+		Name:  "Closing",
+		Group: "Group",
+		Hours: app.Hours{"mon": {"11:00-14:00"}},
+		Cost:  1,
+	}, {
+		Name:  "Chained",
+		Group: "Group",
+		Hours: app.Hours{"mon": {"11:00-14:00", "14:00-18:00"}},
+		Cost:  1,
+	}, {
+		Name:  "All Day",
+		Group: "Group",
+		Open:  map[string][]string{"mon": {"lunch"}},
+		Cost:  1,
+	}, {
+		Name:  "Night Owl",
+		Group: "Group",
+		Hours: app.Hours{"sun": {"20:00-00:10"}},
+		Cost:  1,
+	}}
+
+	type status struct {
+		name, status, time string
+	}
+
+	var tests = []struct {
+		desc    string
+		now     time.Time
+		window  time.Duration
+		period  string
+		current bool
+		want    []status
+	}{{
+		desc:    "closing soon and opening later",
+		now:     time.Date(2024, 1, 1, 13, 45, 0, 0, time.UTC), // Monday.
+		period:  "lunch",
+		current: true,
+		want: []status{
+			{"All Day", "open-now", ""},
+			{"Chained", "open-now", ""},
+			{"Closing", "closing-soon", "14:00"},
+			{"Later", "opens-later", "14:00"},
+			{"Night Owl", "", ""},
+		},
+	}, {
+		desc:    "custom window",
+		now:     time.Date(2024, 1, 1, 13, 45, 0, 0, time.UTC), // Monday.
+		window:  10 * time.Minute,
+		period:  "lunch",
+		current: true,
+		want: []status{
+			{"All Day", "open-now", ""},
+			{"Chained", "open-now", ""},
+			{"Closing", "open-now", ""},
+			{"Later", "opens-later", "14:00"},
+			{"Night Owl", "", ""},
+		},
+	}, {
+		desc:    "closing soon past midnight",
+		now:     time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC), // Monday.
+		period:  "breakfast",
+		current: true,
+		want: []status{
+			{"Night Owl", "closing-soon", "00:10"},
+			{"All Day", "", ""},
+			{"Chained", "", ""},
+			{"Closing", "", ""},
+			{"Later", "", ""},
+		},
+	}, {
+		desc:   "not the current period",
+		now:    time.Date(2024, 1, 1, 13, 45, 0, 0, time.UTC), // Monday.
+		period: "lunch",
+		want: []status{
+			{"All Day", "", ""},
+			{"Chained", "", ""},
+			{"Closing", "", ""},
+			{"Later", "", ""},
+			{"Night Owl", "", ""},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a, err := app.New(app.Params{
+				Entries:           entries,
+				People:            map[string]string{"alice": "t1"},
+				Timezone:          time.UTC,
+				Periods:           testPeriods(),
+				ClosingSoonWindow: test.window,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.SetNowFunc(func() time.Time { return test.now })
+
+			groups := a.TallyData(testMonday, test.period, test.current)
+			got := groups[0].Entries
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d entries, want %d", len(got), len(test.want))
+			}
+			for i, want := range test.want {
+				if got[i].Name != want.name || got[i].Status != want.status || got[i].StatusTime != want.time {
+					t.Errorf("entry %d = (%q, %q, %q), want (%q, %q, %q)", i,
+						got[i].Name, got[i].Status, got[i].StatusTime, want.name, want.status, want.time)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleTallyGetStatus(t *testing.T) {
+	a := newTestApp(t, app.Entry{
+		Name:  "Closing",
+		Group: "Group",
+		Hours: app.Hours{"mon": {"11:00-14:00"}},
+		Cost:  1,
+	})
+	a.SetNowFunc(func() time.Time {
+		return time.Date(2026, 2, 9, 13, 45, 0, 0, time.UTC) // Monday.
+	})
+
+	req := httptest.NewRequest("GET", "/votes?period=lunch&token=tokenA", nil)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, want := range []string{`class="entry closing-soon"`, "closes at 14:00"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Suggestion is an entry suggested by a person, which is only added to the
+// entries once an editor approves it. Reactions map the people who reacted to
+// the suggestion to "up" or "down".
+type Suggestion struct {
+	Entry     Entry
+	By        string
+	Reactions map[string]string `json:",omitempty"`
+}
+
+// suggestionData holds a suggestion for template rendering.
+type suggestionData struct {
+	Name        string
+	Group       string
+	CostDisplay string
+	Open        map[string][]string
+	By          string
+	Up          int
+	Down        int
+	Reaction    string
+	CanRemove   bool
+}
+
+// canEdit reports whether person is an editor, who can change the entries
+// directly and approve or reject suggestions.
+func (a *App) canEdit(person string) bool {
+	return len(a.editors) == 0 || slices.Contains(a.editors, person)
+}
+
+// checkEditor reports whether person is an editor, writing a 403 response if
+// not.
+func (a *App) checkEditor(w http.ResponseWriter, person string) bool {
+	if !a.canEdit(person) {
+		http.Error(w, "Forbidden: only editors can change entries", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// suggestionIndex returns the index of the suggestion with name in group, or
+// -1 if there is none. The caller must hold the read lock.
+func (d *db) suggestionIndex(group, name string) int {
+	return slices.IndexFunc(d.Suggestions, func(s Suggestion) bool {
+		return s.Entry.Group == group && s.Entry.Name == name
+	})
+}
+
+// addSuggestion adds the entry suggested by person to the suggestions. Entries
+// that are duplicates of an entry person can see or of a suggestion are
+// rejected.
+func (a *App) addSuggestion(person string, e Entry) error {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	isDuplicate := duplicateOf(e)
+	if slices.ContainsFunc(a.db.Entries, func(other Entry) bool { return other.visibleTo(person) && isDuplicate(other) }) {
+		return fmt.Errorf("%q already exists in %q", e.Name, e.Group)
+	}
+	if slices.ContainsFunc(a.db.Suggestions, func(s Suggestion) bool { return isDuplicate(s.Entry) }) {
+		return fmt.Errorf("%q was already suggested in %q", e.Name, e.Group)
+	}
+	a.db.Suggestions = append(slices.Clone(a.db.Suggestions), Suggestion{Entry: e, By: person})
+	return nil
+}
+
+// reactToSuggestion sets the reaction of person to the suggestion with name in
+// group, or removes it if person had already reacted the same way. It reports
+// whether there was such a suggestion.
+func (a *App) reactToSuggestion(person, group, name, reaction string) bool {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := a.db.suggestionIndex(group, name)
+	if i < 0 {
+		return false
+	}
+	suggestions := slices.Clone(a.db.Suggestions)
+	reactions := maps.Clone(suggestions[i].Reactions)
+	if reactions == nil {
+		reactions = make(map[string]string)
+	}
+	if reactions[person] == reaction {
+		delete(reactions, person)
+	} else {
+		reactions[person] = reaction
+	}
+	suggestions[i].Reactions = reactions
+	a.db.Suggestions = suggestions
+	return true
+}
+
+// approveSuggestion adds the suggestion with name in group to the entries on
+// behalf of person and removes it from the suggestions. Suggestions that are
+// duplicates of an entry person can see, or that have the name of a private
+// entry, are rejected.
+func (a *App) approveSuggestion(person, group, name string) error {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := a.db.suggestionIndex(group, name)
+	if i < 0 {
+		return fmt.Errorf("%q was not suggested in %q", name, group)
+	}
+	e := a.db.Suggestions[i].Entry
+	isDuplicate := duplicateOf(e)
+	if slices.ContainsFunc(a.db.Entries, func(other Entry) bool { return other.visibleTo(person) && isDuplicate(other) }) {
+		return fmt.Errorf("%q already exists in %q", e.Name, e.Group)
+	}
+	entries := append(slices.Clone(a.db.Entries), e)
+	if err := checkPrivateNames(person, entries); err != nil {
+		return err
+	}
+	a.db.Entries = entries
+	a.db.Suggestions = slices.Delete(slices.Clone(a.db.Suggestions), i, i+1)
+	return nil
+}
+
+// removeSuggestion removes the suggestion with name in group, reporting
+// whether there was such a suggestion that person could remove: editors can
+// reject any suggestion, and other people can withdraw their own.
+func (a *App) removeSuggestion(person, group, name string) bool {
+	defer a.delayAutoSave()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := a.db.suggestionIndex(group, name)
+	if i < 0 || !a.canEdit(person) && a.db.Suggestions[i].By != person {
+		return false
+	}
+	a.db.Suggestions = slices.Delete(slices.Clone(a.db.Suggestions), i, i+1)
+	return true
+}
+
+// suggestionsData returns the suggestions for the page of person, with the
+// most upvoted ones first.
+func (a *App) suggestionsData(person string) []suggestionData {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var result []suggestionData
+	for _, s := range a.db.Suggestions {
+		data := suggestionData{
+			Name:        s.Entry.Name,
+			Group:       s.Entry.Group,
+			CostDisplay: strings.Repeat("$", s.Entry.Cost),
+			Open:        s.Entry.Open,
+			By:          s.By,
+			Reaction:    s.Reactions[person],
+			CanRemove:   a.canEdit(person) || s.By == person,
+		}
+		for _, reaction := range s.Reactions {
+			switch reaction {
+			case "up":
+				data.Up++
+			case "down":
+				data.Down++
+			}
+		}
+		result = append(result, data)
+	}
+	slices.SortStableFunc(result, func(x, y suggestionData) int {
+		return cmp.Compare(y.Up-y.Down, x.Up-x.Down)
+	})
+	return result
+}
+
+// handleSuggestionsGet serves the page listing the suggestions.
+func (a *App) handleSuggestionsGet(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	a.renderSuggestions(w, r, person, "")
+}
+
+// renderSuggestions renders the suggestions page, with an optional error
+// message.
+func (a *App) renderSuggestions(w http.ResponseWriter, r *http.Request, person, message string) {
+	token := r.URL.Query().Get("token")
+	_, periodList := a.periodConfig()
+
+	wds := make([]weekdayInfo, 7)
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		wds[wd] = weekdays[wd]
+	}
+
+	data := pageData{
+		Title:     "Anything",
+		BasePath:  a.basePath,
+		LoggedIn:  token == "",
+		Token:     token,
+		CSRFToken: a.csrfToken(r, person),
+		Nonce:     requestNonce(r),
+		Periods:   periodList,
+		Weekdays:  wds,
+		Groups: flattenGroups(a.entriesData("", func(e Entry) bool {
+			return e.visibleTo(person)
+		})),
+		Message:     message,
+		CanEdit:     a.canEdit(person),
+		Suggestions: a.suggestionsData(person),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := a.suggestTmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleSuggestionsPost handles the actions of the suggestions page. New
+// entries are suggested with their name, group, cost and the "day:period"
+// pairs they are open for, and the other actions are applied to the
+// suggestion sent as "Group|Name".
+func (a *App) handleSuggestionsPost(w http.ResponseWriter, r *http.Request) {
+	person, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !a.checkCSRF(w, r, person) {
+		return
+	}
+
+	group, name, _ := strings.Cut(r.PostForm.Get("entry"), "|")
+	switch action := r.PostForm.Get("action"); action {
+	case "suggest":
+		e, ok := a.parseSuggestion(person, r)
+		if !ok {
+			a.renderSuggestions(w, r, person, "The suggestion must have a name, a group and a cost.")
+			return
+		}
+		if err := a.addSuggestion(person, e); err != nil {
+			a.renderSuggestions(w, r, person, "Cannot suggest the entry: "+err.Error()+".")
+			return
+		}
+	case "up", "down":
+		if !a.reactToSuggestion(person, group, name, action) {
+			http.Error(w, "Bad Request: invalid suggestion", http.StatusBadRequest)
+			return
+		}
+	case "approve":
+		if !a.checkEditor(w, person) {
+			return
+		}
+		if err := a.approveSuggestion(person, group, name); err != nil {
+			a.renderSuggestions(w, r, person, "Cannot approve the suggestion: "+err.Error()+".")
+			return
+		}
+	case "reject":
+		if !a.removeSuggestion(person, group, name) {
+			http.Error(w, "Bad Request: invalid suggestion", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Bad Request: invalid action", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, a.basePath+"/suggestions?token="+token, http.StatusSeeOther)
+}
+
+// parseSuggestion parses the entry suggested in the form, encoding it like the
+// edit form does so that it goes through the same validation. The open
+// periods are sent as "day:period" pairs, and unknown ones are ignored.
+func (a *App) parseSuggestion(person string, r *http.Request) (Entry, bool) {
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+	group := strings.TrimSpace(r.PostForm.Get("group"))
+
+	// The cost is converted back to a string so that it cannot carry other
+	// fields.
+	cost, _ := strconv.Atoi(r.PostForm.Get("cost"))
+	value := strconv.Itoa(cost)
+	_, periodList := a.periodConfig()
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		day := weekdays[wd].Short
+		var names []string
+		for _, period := range periodList {
+			if slices.Contains(r.PostForm["open"], day+":"+period) {
+				names = append(names, period)
+			}
+		}
+		if len(names) > 0 {
+			value += ";" + day + ":" + strings.Join(names, ",")
+		}
+	}
+	e, ok, err := parseEntry(person, group+"|"+name, value)
+	return e, ok && err == nil
+}
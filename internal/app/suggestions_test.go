@@ -0,0 +1,328 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+// postSuggestions posts the form to the suggestions page on behalf of person.
+func postSuggestions(a *app.App, person string, form url.Values) *httptest.ResponseRecorder {
+	form.Set("_csrf", a.CSRFToken(person))
+	req := httptest.NewRequest("POST", "/suggestions?token="+testPeople()[person], strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	return w
+}
+
+func TestSuggest(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		form     url.Values
+		wantCode int
+		want     *app.Entry
+	}{{
+		desc: "valid suggestion",
+		form: url.Values{
+			"name":  {" Noodle Cart "},
+			"group": {"Uptown"},
+			"cost":  {"2"},
+			"open":  {"mon:dinner", "mon:lunch", "mon:brunch", "hol:lunch", "fri:lunch"},
+		},
+		wantCode: http.StatusSeeOther,
+		want: &app.Entry{
+			Name:  "Noodle Cart",
+			Group: "Uptown",
+			Cost:  2,
+			Open:  map[string][]string{"mon": {"lunch", "dinner"}, "fri": {"lunch"}},
+		},
+	}, {
+		desc:     "missing name",
+		form:     url.Values{"group": {"Uptown"}, "cost": {"2"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "invalid cost",
+		form:     url.Values{"name": {"Noodle Cart"}, "group": {"Uptown"}, "cost": {"2;private"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "group with separator",
+		form:     url.Values{"name": {"Noodle Cart"}, "group": {"Up|town"}, "cost": {"2"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "name with separator",
+		form:     url.Values{"name": {"Noodle|Cart"}, "group": {"Uptown"}, "cost": {"2"}},
+		wantCode: http.StatusBadRequest,
+	}, {
+		desc:     "existing entry",
+		form:     url.Values{"name": {"Sushi Bar"}, "group": {"Uptown"}, "cost": {"2"}},
+		wantCode: http.StatusBadRequest,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			test.form.Set("action", "suggest")
+			w := postSuggestions(a, "bob", test.form)
+			if w.Code != test.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantCode)
+			}
+			if len(a.Entries()) != len(testEntries()) {
+				t.Errorf("got %d entries, want %d", len(a.Entries()), len(testEntries()))
+			}
+
+			suggestions := a.Suggestions()
+			if test.want == nil {
+				if len(suggestions) != 0 {
+					t.Errorf("suggestions = %+v, want none", suggestions)
+				}
+				return
+			}
+			if len(suggestions) != 1 {
+				t.Fatalf("got %d suggestions, want 1", len(suggestions))
+			}
+			if s := suggestions[0]; !entryMatches(s.Entry, *test.want) || s.By != "bob" {
+				t.Errorf("suggestion = %+v by %q, want %+v by bob", s.Entry, s.By, *test.want)
+			}
+		})
+	}
+}
+
+func TestSuggestionReactions(t *testing.T) {
+	a := newTestApp(t)
+	postSuggestions(a, "bob", url.Values{"action": {"suggest"}, "name": {"Noodle Cart"}, "group": {"Uptown"}, "cost": {"1"}})
+
+	for _, step := range []struct {
+		person string
+		action string
+	}{
+		{"alice", "up"},
+		{"bob", "down"},
+		{"bob", "down"},
+		{"bob", "up"},
+	} {
+		form := url.Values{"action": {step.action}, "entry": {"Uptown|Noodle Cart"}}
+		if w := postSuggestions(a, step.person, form); w.Code != http.StatusSeeOther {
+			t.Fatalf("%s %s status = %d, want %d", step.person, step.action, w.Code, http.StatusSeeOther)
+		}
+	}
+	want := map[string]string{"alice": "up", "bob": "up"}
+	if got := a.Suggestions()[0].Reactions; !reflect.DeepEqual(got, want) {
+		t.Errorf("reactions = %v, want %v", got, want)
+	}
+
+	w := postSuggestions(a, "bob", url.Values{"action": {"up"}, "entry": {"Uptown|Nothing"}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("reaction to unknown suggestion status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	req := httptest.NewRequest("GET", "/suggestions?token=tokenA", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if want := "👍 2"; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("suggestions page does not contain %q", want)
+	}
+}
+
+func TestSuggestionReview(t *testing.T) {
+	var tests = []struct {
+		desc        string
+		person      string
+		action      string
+		wantCode    int
+		wantEntries int
+	}{{
+		desc:        "editor approves",
+		person:      "alice",
+		action:      "approve",
+		wantCode:    http.StatusSeeOther,
+		wantEntries: len(testEntries()) + 1,
+	}, {
+		desc:        "editor rejects",
+		person:      "alice",
+		action:      "reject",
+		wantCode:    http.StatusSeeOther,
+		wantEntries: len(testEntries()),
+	}, {
+		desc:        "author withdraws",
+		person:      "bob",
+		action:      "reject",
+		wantCode:    http.StatusSeeOther,
+		wantEntries: len(testEntries()),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a := newTestApp(t)
+			postSuggestions(a, "bob", url.Values{
+				"action": {"suggest"},
+				"name":   {"Noodle Cart"},
+				"group":  {"Uptown"},
+				"cost":   {"3"},
+				"open":   {"tue:dinner"},
+			})
+
+			w := postSuggestions(a, test.person, url.Values{"action": {test.action}, "entry": {"Uptown|Noodle Cart"}})
+			if w.Code != test.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantCode)
+			}
+			entries := a.Entries()
+			if len(entries) != test.wantEntries {
+				t.Errorf("got %d entries, want %d", len(entries), test.wantEntries)
+			}
+			if wantPending := test.wantCode != http.StatusSeeOther; (len(a.Suggestions()) == 1) != wantPending {
+				t.Errorf("suggestions = %+v, want pending = %v", a.Suggestions(), wantPending)
+			}
+			if test.action == "approve" && test.wantCode == http.StatusSeeOther {
+				want := app.Entry{Name: "Noodle Cart", Group: "Uptown", Cost: 3, Open: map[string][]string{"tue": {"dinner"}}}
+				if e, ok := findEntry(entries, "Uptown", "Noodle Cart"); !ok || !entryMatches(e, want) {
+					t.Errorf("approved entry = %+v, want %+v", e, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSuggestionPrivateEntries(t *testing.T) {
+	a := newTestApp(t, append(testEntries(), app.Entry{Name: "Home Pasta", Group: "Downtown", Owner: "alice", Open: map[string][]string{"mon": {"lunch"}}, Cost: 1})...)
+
+	// Suggestions are only checked against the entries their author can
+	// see, so that private entries are not revealed.
+	form := url.Values{"action": {"suggest"}, "name": {"Home Pasta"}, "group": {"Downtown"}, "cost": {"2"}}
+	if w := postSuggestions(a, "alice", form); w.Code != http.StatusBadRequest {
+		t.Errorf("suggestion by the owner status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := postSuggestions(a, "bob", form); w.Code != http.StatusSeeOther {
+		t.Fatalf("suggestion by bob status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+
+	// It cannot be approved over the private entry, though.
+	for _, person := range []string{"alice", "bob"} {
+		w := postSuggestions(a, person, url.Values{"action": {"approve"}, "entry": {"Downtown|Home Pasta"}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("approval by %s status = %d, want %d", person, w.Code, http.StatusBadRequest)
+		}
+		if person == "bob" && strings.Contains(w.Body.String(), "already exists") {
+			t.Errorf("approval by bob reveals the private entry: %s", w.Body.String())
+		}
+	}
+	if e, ok := findEntry(a.Entries(), "Downtown", "Home Pasta"); !ok || e.Owner != "alice" {
+		t.Errorf("Downtown|Home Pasta = %+v, want the entry of alice", e)
+	}
+}
+
+func TestEditors(t *testing.T) {
+	var tests = []struct {
+		person     string
+		other      string
+		wantCode   int
+		wantReject int
+	}{{
+		person:     "alice",
+		other:      "bob",
+		wantCode:   http.StatusSeeOther,
+		wantReject: http.StatusSeeOther,
+	}, {
+		person:     "bob",
+		other:      "alice",
+		wantCode:   http.StatusForbidden,
+		wantReject: http.StatusBadRequest,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.person, func(t *testing.T) {
+			a, err := app.New(app.Params{
+				Entries:  testEntries(),
+				People:   testPeople(),
+				Editors:  []string{"alice"},
+				Timezone: time.UTC,
+				Periods:  testPeriods(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := testPeople()[test.person]
+			post := func(path string, form url.Values) int {
+				form.Set("_csrf", a.CSRFToken(test.person))
+				req := httptest.NewRequest("POST", path+"?token="+token, strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				w := httptest.NewRecorder()
+				a.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			if code := post("/entries", url.Values{"Uptown|Sushi Bar": {"1"}}); code != test.wantCode {
+				t.Errorf("entries status = %d, want %d", code, test.wantCode)
+			}
+			if code := post("/entries/bulk", url.Values{"action": {"archive"}, "entry": {"Uptown|Taco Stand"}}); test.wantCode == http.StatusForbidden && code != http.StatusForbidden {
+				t.Errorf("bulk status = %d, want %d", code, http.StatusForbidden)
+			}
+			if code := post("/archive", url.Values{"entry": {"Uptown|Sushi Bar"}}); test.wantCode == http.StatusForbidden && code != http.StatusForbidden {
+				t.Errorf("archive status = %d, want %d", code, http.StatusForbidden)
+			}
+			// Suggestions of other people can only be reviewed by editors.
+			postSuggestions(a, test.other, url.Values{"action": {"suggest"}, "name": {"Noodle Cart"}, "group": {"Uptown"}, "cost": {"1"}})
+			postSuggestions(a, test.other, url.Values{"action": {"suggest"}, "name": {"Fish Cart"}, "group": {"Uptown"}, "cost": {"1"}})
+			if code := post("/suggestions", url.Values{"action": {"approve"}, "entry": {"Uptown|Noodle Cart"}}); code != test.wantCode {
+				t.Errorf("suggestion approval status = %d, want %d", code, test.wantCode)
+			}
+			if code := post("/suggestions", url.Values{"action": {"reject"}, "entry": {"Uptown|Fish Cart"}}); code != test.wantReject {
+				t.Errorf("suggestion rejection status = %d, want %d", code, test.wantReject)
+			}
+
+			for path, body := range map[string]string{
+				"/import.json": `{"entries":[]}`,
+				"/entries.csv": "group,name,cost\nDowntown,Pizza Place,1\n",
+			} {
+				req := httptest.NewRequest("POST", path+"?token="+token, strings.NewReader(body))
+				w := httptest.NewRecorder()
+				a.ServeHTTP(w, req)
+				if test.wantCode == http.StatusForbidden && w.Code != http.StatusForbidden {
+					t.Errorf("%s status = %d, want %d", path, w.Code, http.StatusForbidden)
+				}
+			}
+
+			code := post("/settings", url.Values{
+				"period_old":   {"lunch"},
+				"period_name":  {"lunch"},
+				"period_start": {"10"},
+				"period_end":   {"15"},
+			})
+			if code != test.wantCode {
+				t.Errorf("settings status = %d, want %d", code, test.wantCode)
+			}
+			if _, periods := a.PeriodConfig(); test.wantCode == http.StatusForbidden && len(periods) != len(testPeriods()) {
+				t.Errorf("settings changed periods to %v", periods)
+			}
+			if test.wantCode == http.StatusForbidden && len(a.Entries()) != len(testEntries()) {
+				t.Errorf("got %d entries, want %d", len(a.Entries()), len(testEntries()))
+			}
+
+			req := httptest.NewRequest("GET", "/entries?token="+token, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+			canSave := strings.Contains(w.Body.String(), ">Save</button>")
+			if wantSave := test.wantCode != http.StatusForbidden; canSave != wantSave {
+				t.Errorf("edit page has save button = %v, want %v", canSave, wantSave)
+			}
+		})
+	}
+}
+
+func TestUnknownEditor(t *testing.T) {
+	_, err := app.New(app.Params{
+		People:   testPeople(),
+		Editors:  []string{"mallory"},
+		Timezone: time.UTC,
+		Periods:  testPeriods(),
+	})
+	if !errorContains(err, `editor "mallory" is an unknown person`) {
+		t.Errorf("expected unknown editor error, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Tags are free-form labels of an entry (e.g., "vegetarian", "delivery"),
+// which can be used to filter the entries in the vote and tally pages.
+type Tags []string
+
+// Validate checks that no tag is empty or contains a comma, which separates
+// tags in the edit page.
+func (t Tags) Validate() error {
+	for _, tag := range t {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("tags must not be empty")
+		}
+		if strings.Contains(tag, ",") {
+			return fmt.Errorf("tag %q must not contain ','", tag)
+		}
+	}
+	return nil
+}
+
+// Normalize returns the tags trimmed, in lower case, sorted and without
+// duplicates or empty tags.
+func (t Tags) Normalize() Tags {
+	var result Tags
+	for _, tag := range t {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// parseTags parses a comma-separated list of tags, as sent by the edit page.
+func parseTags(s string) Tags {
+	return Tags(strings.Split(s, ",")).Normalize()
+}
+
+// tagFilter selects entries by their tags. Entries must have all included
+// tags and none of the excluded ones.
+type tagFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// parseTagFilter reads a tag filter from the "include" and "exclude" query
+// parameters, which may be repeated.
+func parseTagFilter(q url.Values) tagFilter {
+	return tagFilter{
+		Include: Tags(q["include"]).Normalize(),
+		Exclude: Tags(q["exclude"]).Normalize(),
+	}
+}
+
+// match reports whether an entry with tags is selected by the filter.
+func (f tagFilter) match(tags Tags) bool {
+	for _, tag := range f.Include {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	for _, tag := range f.Exclude {
+		if slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// query returns the filter as query parameters to be appended to a URL that
+// already has a query, starting with "&". It is empty for an empty filter.
+func (f tagFilter) query() string {
+	q := url.Values{}
+	if len(f.Include) > 0 {
+		q["include"] = f.Include
+	}
+	if len(f.Exclude) > 0 {
+		q["exclude"] = f.Exclude
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "&" + q.Encode()
+}
+
+// Tag filter states of a tag option.
+const (
+	tagIncluded = "include"
+	tagExcluded = "exclude"
+)
+
+// tagOption holds a tag for rendering the tag filter, along with its state in
+// the current filter and the filter to switch to when it is clicked. Clicking
+// a tag cycles it between included, excluded and not filtered.
+type tagOption struct {
+	Name   string
+	State  string
+	Filter tagFilter
+}
+
+// tagOptions returns the options for filtering by every tag in use, and by the
+// tags in f even if no entry has them anymore.
+func (a *App) tagOptions(person string, f tagFilter) []tagOption {
+	a.mu.RLock()
+	var tags Tags
+	for _, e := range a.db.Entries {
+		if e.visibleTo(person) {
+			tags = append(tags, e.Tags...)
+		}
+	}
+	a.mu.RUnlock()
+	tags = append(tags, f.Include...)
+	tags = append(tags, f.Exclude...)
+
+	without := func(tags []string, tag string) []string {
+		return slices.DeleteFunc(slices.Clone(tags), func(t string) bool { return t == tag })
+	}
+
+	var options []tagOption
+	for _, tag := range tags.Normalize() {
+		option := tagOption{Name: tag}
+		switch {
+		case slices.Contains(f.Include, tag):
+			option.State = tagIncluded
+			option.Filter = tagFilter{
+				Include: without(f.Include, tag),
+				Exclude: Tags(append(slices.Clone(f.Exclude), tag)).Normalize(),
+			}
+		case slices.Contains(f.Exclude, tag):
+			option.State = tagExcluded
+			option.Filter = tagFilter{Include: f.Include, Exclude: without(f.Exclude, tag)}
+		default:
+			option.Filter = tagFilter{
+				Include: Tags(append(slices.Clone(f.Include), tag)).Normalize(),
+				Exclude: f.Exclude,
+			}
+		}
+		options = append(options, option)
+	}
+	return options
+}
@@ -0,0 +1,247 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/app"
+)
+
+func TestTagsValidate(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		tags    app.Tags
+		wantErr string
+	}{{
+		desc: "valid tags",
+		tags: app.Tags{"vegetarian friendly", "delivery"},
+	}, {
+		desc: "no tags",
+	}, {
+		desc:    "empty tag",
+		tags:    app.Tags{"delivery", " "},
+		wantErr: "tags must not be empty",
+	}, {
+		desc:    "tag with comma",
+		tags:    app.Tags{"a,b"},
+		wantErr: `tag "a,b" must not contain ','`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := test.tags.Validate(); !errorContains(err, test.wantErr) {
+				t.Errorf("Validate() = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTagsNormalize(t *testing.T) {
+	got := app.Tags{" Vegetarian ", "delivery", "", "DELIVERY", "brunch"}.Normalize()
+	want := app.Tags{"brunch", "delivery", "vegetarian"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Normalize() = %v, want %v", got, want)
+	}
+}
+
+func TestTagFilterMatch(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		filter app.TagFilter
+		tags   app.Tags
+		want   bool
+	}{{
+		desc: "empty filter matches everything",
+		tags: app.Tags{"delivery"},
+		want: true,
+	}, {
+		desc:   "included tag",
+		filter: app.TagFilter{Include: []string{"delivery"}},
+		tags:   app.Tags{"delivery", "italian"},
+		want:   true,
+	}, {
+		desc:   "every included tag is required",
+		filter: app.TagFilter{Include: []string{"delivery", "japanese"}},
+		tags:   app.Tags{"delivery", "italian"},
+		want:   false,
+	}, {
+		desc:   "excluded tag",
+		filter: app.TagFilter{Exclude: []string{"italian"}},
+		tags:   app.Tags{"delivery", "italian"},
+		want:   false,
+	}, {
+		desc:   "entry without tags is not excluded",
+		filter: app.TagFilter{Exclude: []string{"italian"}},
+		want:   true,
+	}, {
+		desc:   "included and excluded tags",
+		filter: app.TagFilter{Include: []string{"delivery"}, Exclude: []string{"italian"}},
+		tags:   app.Tags{"delivery"},
+		want:   true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := test.filter.Match(test.tags); got != test.want {
+				t.Errorf("Match(%v) = %v, want %v", test.tags, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilteredTallyData(t *testing.T) {
+	a := newTestApp(t)
+
+	var tests = []struct {
+		desc   string
+		filter app.TagFilter
+		want   []string
+	}{{
+		desc: "no filter",
+		want: []string{"Pizza Place", "Burger Joint", "Sushi Bar", "Taco Stand"},
+	}, {
+		desc:   "include",
+		filter: app.TagFilter{Include: []string{"delivery"}},
+		want:   []string{"Pizza Place", "Burger Joint"},
+	}, {
+		desc:   "exclude",
+		filter: app.TagFilter{Exclude: []string{"delivery"}},
+		want:   []string{"Sushi Bar", "Taco Stand"},
+	}, {
+		desc:   "include and exclude",
+		filter: app.TagFilter{Include: []string{"delivery"}, Exclude: []string{"italian"}},
+		want:   []string{"Burger Joint"},
+	}, {
+		desc:   "no matches",
+		filter: app.TagFilter{Include: []string{"vegan"}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var got []string
+			for _, g := range a.FilteredTallyData(testMonday, "lunch", false, test.filter) {
+				for _, e := range g.Entries {
+					got = append(got, e.Name)
+				}
+			}
+			slices.Sort(got)
+			slices.Sort(test.want)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("FilteredTallyData() entries = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateFilteredVotes(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdateVotes("alice", map[string]string{
+		"Downtown|Pizza Place":  "no",
+		"Downtown|Burger Joint": "no",
+		"Uptown|Sushi Bar":      "no",
+	})
+
+	// Only the entries with delivery were shown, and the vote for Burger
+	// Joint was cleared.
+	form := url.Values{
+		"_csrf":                {a.CSRFToken("alice")},
+		"Downtown|Pizza Place": {"strong-yes"},
+	}
+	req := httptest.NewRequest("POST", "/votes?token=tokenA&include=delivery", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	got := a.Votes()["alice"]
+	want := app.PersonVote{
+		"Downtown": {"Pizza Place": "strong-yes"},
+		"Uptown":   {"Sushi Bar": "no"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("votes = %v, want %v", got, want)
+	}
+}
+
+func TestTagFilterPages(t *testing.T) {
+	a := newTestApp(t)
+	a.UpdatePlan(app.Plan{Date: "2024-01-02", Period: "lunch", Group: "Downtown", Name: "Pizza Place"})
+	a.UpdatePlan(app.Plan{Date: "2024-01-01", Period: "dinner", Group: "Uptown", Name: "Sushi Bar"})
+
+	var tests = []struct {
+		desc        string
+		url         string
+		wantBody    []string
+		notWantBody []string
+	}{{
+		desc: "vote page without filter",
+		url:  "/?token=tokenA",
+		wantBody: []string{
+			"Pizza Place", "Sushi Bar",
+			`<a class="tag" href="/?token=tokenA&amp;include=delivery">delivery</a>`,
+			`<small class="entry-tags">delivery, italian</small>`,
+		},
+	}, {
+		desc: "vote page with included tag",
+		url:  "/?token=tokenA&include=delivery",
+		wantBody: []string{
+			"Pizza Place", "Burger Joint",
+			`<a class="tag include" href="/?token=tokenA&amp;exclude=delivery">delivery</a>`,
+			`action="/votes?token=tokenA&amp;include=delivery"`,
+		},
+		notWantBody: []string{"Sushi Bar", "Taco Stand"},
+	}, {
+		desc: "tally page with excluded tag",
+		url:  "/votes?period=lunch&date=2024-01-01&token=tokenA&exclude=delivery",
+		wantBody: []string{
+			"Taco Stand",
+			`<a class="tag exclude" href="/votes?period=lunch&amp;date=2024-01-01&amp;token=tokenA">not delivery</a>`,
+			`date=2023-12-31&amp;token=tokenA&amp;exclude=delivery`,
+		},
+		notWantBody: []string{"Pizza Place", "Burger Joint"},
+	}, {
+		desc:        "export with included tag",
+		url:         "/export.json?token=tokenA&include=japanese",
+		wantBody:    []string{"Sushi Bar"},
+		notWantBody: []string{"Pizza Place", "Burger Joint", "Taco Stand"},
+	}, {
+		desc:        "calendar with excluded tag",
+		url:         "/plans.ics?token=tokenA&exclude=japanese",
+		wantBody:    []string{"SUMMARY:Lunch: Pizza Place"},
+		notWantBody: []string{"Sushi Bar"},
+	}, {
+		desc:     "calendar without filter",
+		url:      "/plans.ics?token=tokenA",
+		wantBody: []string{"SUMMARY:Lunch: Pizza Place", "SUMMARY:Dinner: Sushi Bar"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", test.url, nil)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, s := range test.wantBody {
+				if !strings.Contains(body, s) {
+					t.Errorf("body does not contain %q", s)
+				}
+			}
+			for _, s := range test.notWantBody {
+				if strings.Contains(body, s) {
+					t.Errorf("body contains %q", s)
+				}
+			}
+		})
+	}
+}
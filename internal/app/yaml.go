@@ -0,0 +1,493 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The app avoids external dependencies, so catalogs in YAML are handled by
+// converting them to and from JSON. Only the subset of YAML written by
+// jsonToYAML is read, along with comments:
+//   - block mappings, with plain or double-quoted keys;
+//   - block sequences indented under their key, whose items are scalars or
+//     block mappings starting on the line of the dash;
+//   - flow sequences of scalars, and empty flow mappings;
+//   - plain scalars, read as null, booleans and numbers as in JSON, and
+//     double-quoted scalars, with the escapes of JSON strings.
+// Anything else, such as single-quoted scalars, flow mappings, anchors, tags,
+// block scalars and multiple documents, is rejected.
+
+// yamlLine is a line of a YAML document without its indentation and comment.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// yamlParser parses the lines of a YAML document into JSON values.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlToJSON converts a YAML document in the supported subset to JSON.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var p yamlParser
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		text := strings.TrimLeft(line, " ")
+		indent := len(line) - len(text)
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		text, err := stripYAMLComment(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "...") || strings.HasPrefix(line, "%") {
+			return nil, fmt.Errorf("line %d: document markers and directives are not supported", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: indent, text: text})
+	}
+
+	var v any
+	if len(p.lines) > 0 {
+		var err error
+		v, err = p.parseBlock(p.lines[0].indent)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.lines) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+		}
+	}
+	return json.Marshal(v)
+}
+
+// stripYAMLComment returns text without its comment, if any, and without
+// trailing spaces. Comments start with a "#" at the start of text or after a
+// space, outside of double-quoted scalars.
+func stripYAMLComment(text string) (string, error) {
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimRight(text[:i], " "), nil
+		}
+	}
+	if quoted {
+		return "", errors.New("unterminated quoted scalar")
+	}
+	return strings.TrimRight(text, " "), nil
+}
+
+// isYAMLSequenceItem reports whether text is an item of a block sequence.
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the block mapping or sequence starting at the current
+// line, with the given indentation.
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+// parseSequence parses a block sequence with the given indentation.
+func (p *yamlParser) parseSequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			return nil, fmt.Errorf("line %d: expected a value after \"-\"", line.num)
+		}
+		if _, _, ok := splitYAMLKey(rest); ok {
+			// The item is a block mapping indented by the dash.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+			item, err := p.parseMapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		p.pos++
+		item, err := parseYAMLValue(rest, line.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseMapping parses a block mapping with the given indentation.
+func (p *yamlParser) parseMapping(indent int) (any, error) {
+	m := make(map[string]any)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: key %q is used more than once", line.num, key)
+		}
+		p.pos++
+		var v any
+		var err error
+		if value != "" {
+			v, err = parseYAMLValue(value, line.num)
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			v, err = p.parseBlock(p.lines[p.pos].indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// splitYAMLKey splits a line of a block mapping into its key and value,
+// reporting whether the line is a mapping entry.
+func splitYAMLKey(text string) (string, string, bool) {
+	var key, rest string
+	if text[0] == '"' {
+		f := yamlFlowParser{s: text}
+		v, err := f.quoted()
+		if err != nil {
+			return "", "", false
+		}
+		var ok bool
+		if rest, ok = strings.CutPrefix(text[f.i:], ":"); !ok {
+			return "", "", false
+		}
+		key = v
+	} else {
+		if isYAMLIndicator(text[0]) {
+			return "", "", false
+		}
+		i := strings.Index(text+" ", ": ")
+		if i < 0 {
+			return "", "", false
+		}
+		key, rest = text[:i], text[i+1:]
+	}
+	if rest != "" && rest[0] != ' ' {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(rest), true
+}
+
+// isYAMLIndicator reports whether c cannot start a plain scalar.
+func isYAMLIndicator(c byte) bool {
+	return strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", c) >= 0
+}
+
+// parseYAMLValue parses s, which is a scalar, a flow sequence or an empty
+// flow mapping, as the whole value of a line.
+func parseYAMLValue(s string, num int) (any, error) {
+	f := yamlFlowParser{s: s}
+	var v any
+	var err error
+	switch {
+	case s == "{}":
+		return map[string]any{}, nil
+	case s[0] == '[':
+		v, err = f.sequence()
+	case s[0] == '"':
+		v, err = f.quoted()
+	default:
+		v, err = yamlPlainScalar(s)
+		f.i = len(s)
+	}
+	if err == nil && f.i < len(s) {
+		err = fmt.Errorf("unexpected %q", s[f.i:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", num, err)
+	}
+	return v, nil
+}
+
+// yamlFlowParser parses flow sequences and double-quoted scalars.
+type yamlFlowParser struct {
+	s string
+	i int
+}
+
+// skipSpaces advances past spaces.
+func (f *yamlFlowParser) skipSpaces() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+// sequence parses a flow sequence of scalars.
+func (f *yamlFlowParser) sequence() (any, error) {
+	f.i++ // '['
+	items := []any{}
+	for {
+		f.skipSpaces()
+		if f.i >= len(f.s) {
+			return nil, errors.New(`expected "]"`)
+		}
+		if f.s[f.i] == ']' && len(items) == 0 {
+			f.i++
+			return items, nil
+		}
+		var item any
+		var err error
+		if f.s[f.i] == '"' {
+			item, err = f.quoted()
+		} else {
+			start := f.i
+			for f.i < len(f.s) && f.s[f.i] != ',' && f.s[f.i] != ']' {
+				f.i++
+			}
+			item, err = yamlPlainScalar(strings.TrimSpace(f.s[start:f.i]))
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		f.skipSpaces()
+		if f.i >= len(f.s) {
+			return nil, errors.New(`expected "]"`)
+		}
+		switch f.s[f.i] {
+		case ',':
+			f.i++
+		case ']':
+			f.i++
+			return items, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q", f.s[f.i:])
+		}
+	}
+}
+
+// quoted parses a double-quoted scalar.
+func (f *yamlFlowParser) quoted() (string, error) {
+	start := f.i
+	for f.i++; f.i < len(f.s); f.i++ {
+		switch f.s[f.i] {
+		case '\\':
+			f.i++
+		case '"':
+			f.i++
+			var v string
+			if err := json.Unmarshal([]byte(f.s[start:f.i]), &v); err != nil {
+				return "", fmt.Errorf("invalid quoted scalar %s", f.s[start:f.i])
+			}
+			return v, nil
+		}
+	}
+	return "", errors.New("unterminated quoted scalar")
+}
+
+// yamlPlainScalar returns the value of the plain scalar s, which is null, a
+// boolean, a number or a string.
+func yamlPlainScalar(s string) (any, error) {
+	if s == "" || s == "null" || s == "~" {
+		return nil, nil
+	}
+	if isYAMLIndicator(s[0]) && !isYAMLNumber(s) {
+		return nil, fmt.Errorf("unsupported YAML syntax %q", s)
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return nil, fmt.Errorf("unsupported YAML syntax %q", s)
+	}
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if isYAMLNumber(s) {
+		return json.Number(s), nil
+	}
+	return s, nil
+}
+
+// isYAMLNumber reports whether the plain scalar s is a number that can be
+// used as is in JSON.
+func isYAMLNumber(s string) bool {
+	if !json.Valid([]byte(s)) {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// jsonToYAML converts a JSON document to YAML in the supported subset, keeping
+// the order of the keys in objects. Objects are written as block mappings,
+// sequences with objects as block sequences, and other sequences as flow
+// sequences, so sequences of sequences are not supported.
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	if m, ok := v.(*orderedObject); ok && len(m.keys) > 0 {
+		writeYAMLMapping(&b, m, 0, "")
+	} else {
+		b.WriteString(yamlFlow(v) + "\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// orderedObject is a JSON object with the order of its keys.
+type orderedObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// decodeOrdered decodes the next JSON value from dec, with objects as
+// orderedObject values.
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := &orderedObject{values: make(map[string]any)}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			m.keys = append(m.keys, key.(string))
+			m.values[key.(string)] = v
+		}
+		_, err := dec.Token() // '}'
+		return m, err
+	case json.Delim('['):
+		items := []any{}
+		for dec.More() {
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		_, err := dec.Token() // ']'
+		return items, err
+	}
+	return tok, nil
+}
+
+// writeYAMLMapping writes m as a block mapping with the given indentation,
+// with the first line starting with prefix instead of the indentation.
+func writeYAMLMapping(b *strings.Builder, m *orderedObject, indent int, prefix string) {
+	pad := strings.Repeat(" ", indent)
+	for i, key := range m.keys {
+		if i == 0 {
+			b.WriteString(prefix)
+		} else {
+			b.WriteString(pad)
+		}
+		b.WriteString(yamlScalar(key) + ":")
+
+		switch v := m.values[key].(type) {
+		case *orderedObject:
+			if len(v.keys) > 0 {
+				b.WriteString("\n")
+				writeYAMLMapping(b, v, indent+2, pad+"  ")
+				continue
+			}
+		case []any:
+			if slices.ContainsFunc(v, isYAMLMapping) {
+				b.WriteString("\n")
+				for _, item := range v {
+					if isYAMLMapping(item) {
+						writeYAMLMapping(b, item.(*orderedObject), indent+4, pad+"  - ")
+					} else {
+						b.WriteString(pad + "  - " + yamlFlow(item) + "\n")
+					}
+				}
+				continue
+			}
+		}
+		b.WriteString(" " + yamlFlow(m.values[key]) + "\n")
+	}
+}
+
+// isYAMLMapping reports whether v is written as a block mapping.
+func isYAMLMapping(v any) bool {
+	m, ok := v.(*orderedObject)
+	return ok && len(m.keys) > 0
+}
+
+// yamlFlow returns v, which is a scalar, an empty object or a sequence of
+// scalars, in flow style.
+func yamlFlow(v any) string {
+	switch v := v.(type) {
+	case *orderedObject:
+		return "{}"
+	case []any:
+		var items []string
+		for _, item := range v {
+			items = append(items, yamlFlow(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case string:
+		return yamlScalar(v)
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlReservedWords are the words read as other values than strings by other
+// YAML parsers, which are quoted to be read back as strings.
+var yamlReservedWords = []string{"true", "false", "null", "y", "n", "yes", "no", "on", "off"}
+
+// yamlScalar returns s as a plain scalar if it would be read back as the same
+// string in both block and flow styles, or as a double-quoted scalar
+// otherwise.
+func yamlScalar(s string) string {
+	plain := s != "" && unicode.IsLetter([]rune(s)[0]) && !strings.HasSuffix(s, " ")
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" _-.'&()/+", r) {
+			plain = false
+		}
+	}
+	if slices.Contains(yamlReservedWords, strings.ToLower(s)) {
+		plain = false
+	}
+	if plain {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
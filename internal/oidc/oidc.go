@@ -0,0 +1,318 @@
+// Package oidc implements a minimal OpenID Connect relying party using the
+// authorization code flow with PKCE, verifying RS256-signed ID tokens.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is the leeway allowed when checking token expiry.
+const clockSkew = time.Minute
+
+// Config contains all parameters needed to create a Provider.
+type Config struct {
+	// Issuer is the issuer URL of the identity provider, used for discovery.
+	Issuer string
+
+	// ClientID and ClientSecret are the credentials of this application at
+	// the identity provider.
+	ClientID     string
+	ClientSecret string
+
+	// HTTPClient is used for requests to the identity provider. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// Identity is the verified identity of a logged in user.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// discovery holds the relevant fields of the provider metadata document.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect identity provider.
+type Provider struct {
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	meta         discovery
+	nowFunc      func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+}
+
+// NewProvider discovers the endpoints of the identity provider at
+// cfg.Issuer and returns a Provider for it.
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	p := &Provider{
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		client:       cfg.HTTPClient,
+		nowFunc:      time.Now,
+	}
+	if p.client == nil {
+		p.client = http.DefaultClient
+	}
+
+	u := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, u, &p.meta); err != nil {
+		return nil, fmt.Errorf("cannot discover provider: %w", err)
+	}
+	if p.meta.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("provider issuer %q does not match %q", p.meta.Issuer, cfg.Issuer)
+	}
+	if p.meta.AuthorizationEndpoint == "" || p.meta.TokenEndpoint == "" || p.meta.JWKSURI == "" {
+		return nil, errors.New("provider metadata is missing endpoints")
+	}
+	return p, nil
+}
+
+// RandomString returns a random URL-safe string suitable for use as a state,
+// nonce or PKCE code verifier.
+func RandomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// AuthURL returns the URL to which users should be redirected to log in. The
+// verifier is the PKCE code verifier, which must later be passed to Exchange
+// along with the nonce.
+func (p *Provider) AuthURL(redirectURI, state, nonce, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid email"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.meta.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code for an ID token, verifies it and
+// returns the identity it asserts.
+func (p *Provider) Exchange(ctx context.Context, redirectURI, code, verifier, nonce string) (Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("cannot redeem code: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return Identity{}, fmt.Errorf("cannot redeem code: status %d: %s", res.StatusCode, body)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		return Identity{}, fmt.Errorf("cannot decode token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, errors.New("token response has no ID token")
+	}
+	return p.verify(ctx, tokens.IDToken, nonce)
+}
+
+// claims holds the relevant claims of an ID token.
+type claims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      json.RawMessage `json:"aud"`
+	Expiry        int64           `json:"exp"`
+	Nonce         string          `json:"nonce"`
+	Email         string          `json:"email"`
+	EmailVerified any             `json:"email_verified"`
+}
+
+// verify checks the signature and claims of an ID token.
+func (p *Provider) verify(ctx context.Context, token, nonce string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, errors.New("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, fmt.Errorf("malformed ID token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return Identity{}, fmt.Errorf("unsupported ID token algorithm %q", header.Alg)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return Identity{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("malformed ID token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return Identity{}, errors.New("invalid ID token signature")
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Identity{}, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	if c.Issuer != p.meta.Issuer {
+		return Identity{}, fmt.Errorf("ID token issuer %q is not %q", c.Issuer, p.meta.Issuer)
+	}
+	if !audienceContains(c.Audience, p.clientID) {
+		return Identity{}, errors.New("ID token audience does not contain the client ID")
+	}
+	if p.nowFunc().Add(-clockSkew).Unix() >= c.Expiry {
+		return Identity{}, errors.New("ID token is expired")
+	}
+	if c.Nonce != nonce {
+		return Identity{}, errors.New("ID token nonce does not match")
+	}
+	if c.Subject == "" {
+		return Identity{}, errors.New("ID token has no subject")
+	}
+
+	verified := false
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		// Some providers send the claim as a string.
+		verified = v == "true"
+	}
+	return Identity{Subject: c.Subject, Email: c.Email, EmailVerified: verified}, nil
+}
+
+// key returns the public key with the given ID, fetching the provider's key
+// set if the key is not known yet.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	// The key may have been rotated, so refresh the key set.
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("cannot fetch provider keys: %w", err)
+	}
+	p.keys = make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown ID token key %q", kid)
+	}
+	return key, nil
+}
+
+// getJSON fetches the JSON document at u into v.
+func (p *Provider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a JWT into v.
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// audienceContains reports whether the aud claim, which may be a string or an
+// array of strings, contains clientID.
+func audienceContains(aud json.RawMessage, clientID string) bool {
+	var single string
+	if err := json.Unmarshal(aud, &single); err == nil {
+		return single == clientID
+	}
+	var multiple []string
+	if err := json.Unmarshal(aud, &multiple); err == nil {
+		return slices.Contains(multiple, clientID)
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alnvdl/anything/internal/oidc"
+	"github.com/alnvdl/anything/internal/oidc/oidctest"
+)
+
+// errorContains checks that err contains the substring want. If want is empty,
+// it checks that err is nil.
+func errorContains(err error, want string) bool {
+	if want == "" {
+		return err == nil
+	}
+	return err != nil && strings.Contains(err.Error(), want)
+}
+
+const redirectURI = "https://anything.example/auth/callback"
+
+func TestNewProvider(t *testing.T) {
+	idp := oidctest.NewProvider("client", "secret")
+	defer idp.Close()
+
+	var tests = []struct {
+		desc    string
+		issuer  string
+		wantErr string
+	}{{
+		desc:   "valid issuer",
+		issuer: idp.Issuer(),
+	}, {
+		desc:    "issuer mismatch",
+		issuer:  idp.Issuer() + "/",
+		wantErr: "does not match",
+	}, {
+		desc:    "no provider",
+		issuer:  idp.Issuer() + "/nothing",
+		wantErr: "cannot discover provider",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := oidc.NewProvider(context.Background(), oidc.Config{
+				Issuer:       test.issuer,
+				ClientID:     "client",
+				ClientSecret: "secret",
+			})
+			if !errorContains(err, test.wantErr) {
+				t.Errorf("NewProvider() err = %v, wantErr = %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := oidctest.NewProvider("client", "secret")
+	defer idp.Close()
+
+	var tests = []struct {
+		desc          string
+		clientSecret  string
+		claims        oidctest.Claims
+		wrongVerifier bool
+		wrongNonce    bool
+		want          oidc.Identity
+		wantErr       string
+	}{{
+		desc:         "valid login",
+		clientSecret: "secret",
+		claims:       oidctest.Claims{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true},
+		want:         oidc.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true},
+	}, {
+		desc:         "unverified email",
+		clientSecret: "secret",
+		claims:       oidctest.Claims{Subject: "sub-2", Email: "bob@example.com"},
+		want:         oidc.Identity{Subject: "sub-2", Email: "bob@example.com"},
+	}, {
+		desc:         "wrong client secret",
+		clientSecret: "wrong",
+		claims:       oidctest.Claims{Subject: "sub-1"},
+		wantErr:      "status 401",
+	}, {
+		desc:          "wrong code verifier",
+		clientSecret:  "secret",
+		claims:        oidctest.Claims{Subject: "sub-1"},
+		wrongVerifier: true,
+		wantErr:       "status 400",
+	}, {
+		desc:         "wrong nonce",
+		clientSecret: "secret",
+		claims:       oidctest.Claims{Subject: "sub-1"},
+		wrongNonce:   true,
+		wantErr:      "nonce does not match",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			p, err := oidc.NewProvider(ctx, oidc.Config{
+				Issuer:       idp.Issuer(),
+				ClientID:     "client",
+				ClientSecret: test.clientSecret,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, nonce, verifier := oidc.RandomString(), oidc.RandomString(), oidc.RandomString()
+			callback, err := idp.Authorize(p.AuthURL(redirectURI, state, nonce, verifier), test.claims)
+			if err != nil {
+				t.Fatalf("Authorize() error: %v", err)
+			}
+			u, _ := url.Parse(callback)
+			if got := u.Query().Get("state"); got != state {
+				t.Fatalf("callback state = %q, want %q", got, state)
+			}
+
+			if test.wrongVerifier {
+				verifier = oidc.RandomString()
+			}
+			if test.wrongNonce {
+				nonce = oidc.RandomString()
+			}
+			got, err := p.Exchange(ctx, redirectURI, u.Query().Get("code"), verifier, nonce)
+			if !errorContains(err, test.wantErr) {
+				t.Fatalf("Exchange() err = %v, wantErr = %q", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("Exchange() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}